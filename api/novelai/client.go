@@ -1,6 +1,8 @@
 package novelai
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -11,8 +13,9 @@ import (
 )
 
 type Client struct {
-	token token
-	host  url.URL
+	token   token
+	host    url.URL
+	upscale url.URL
 }
 
 func NewNovelAIClient(key string) *Client {
@@ -23,6 +26,11 @@ func NewNovelAIClient(key string) *Client {
 			Host:   "image.novelai.net",
 			Path:   "/ai/generate-image",
 		},
+		upscale: url.URL{
+			Scheme: "https",
+			Host:   "api.novelai.net",
+			Path:   "/ai/upscale",
+		},
 	}
 }
 
@@ -44,8 +52,31 @@ func (c *Client) Inference(request *entities.NovelAIRequest) (*entities.NovelAIR
 	return &entities.NovelAIResponse{Images: response}, nil
 }
 
+// Upscale sends the image to the NovelAI upscaler. The response contains a single image.
+func (c *Client) Upscale(request *entities.NovelAIUpscaleRequest) (*entities.NovelAIResponse, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(request); err != nil {
+		return nil, err
+	}
+
+	response, err := c.post(c.upscale, &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.NovelAIResponse{Images: response}, nil
+}
+
 func (c *Client) POST(bin io.Reader) ([]io.Reader, error) {
-	request, err := http.NewRequest(http.MethodPost, c.host.String(), bin)
+	return c.post(c.host, bin)
+}
+
+func (c *Client) post(host url.URL, bin io.Reader) ([]io.Reader, error) {
+	request, err := http.NewRequest(http.MethodPost, host.String(), bin)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,166 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+)
+
+// ToolRole is the role of the messages answering the model's tool calls.
+const ToolRole llm.Role = "tool"
+
+// Tool is a function the model can call, described by a JSON schema of its parameters.
+type Tool struct {
+	Type     string   `json:"type"`
+	Function Function `json:"function"`
+}
+
+type Function struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+// ToolCall is a call the model wants to make, with the arguments as a JSON object in a string.
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Message extends llm.Message with the fields used to call tools and answer them.
+// When Parts is set, it's sent as the content instead of Content, for vision models.
+type Message struct {
+	Role       llm.Role      `json:"role"`
+	Content    string        `json:"content"`
+	Parts      []ContentPart `json:"-"`
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// ContentPart is either text or an image, of which the content of a message can be a list.
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL is a link to an image, or the image itself as a data URL, see ImageDataURL.
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: "text", Text: text}
+}
+
+func ImagePart(url string) ContentPart {
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+// ImageDataURL embeds a base64 encoded image in a URL, since most servers can't download images themselves.
+func ImageDataURL(contentType, encoded string) string {
+	return fmt.Sprintf("data:%s;base64,%s", contentType, encoded)
+}
+
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(message(m))
+	}
+
+	// the content of the embedded message is shadowed by the parts
+	return json.Marshal(struct {
+		message
+		Content []ContentPart `json:"content"`
+	}{message(m), m.Parts})
+}
+
+// ChatRequest is an llm.Request with tools and images. It's answered in full, unless it's sent with StreamChat.
+type ChatRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+	MaxTokens   int64     `json:"max_tokens"`
+	Stream      bool      `json:"stream,omitempty"`
+	Tools       []Tool    `json:"tools,omitempty"`
+}
+
+type ChatResponse struct {
+	ID      string    `json:"id"`
+	Model   string    `json:"model"`
+	Choices []Choice  `json:"choices"`
+	Usage   llm.Usage `json:"usage"`
+}
+
+type Choice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// Messages converts the messages of an llm.Request.
+func Messages(messages []llm.Message) []Message {
+	out := make([]Message, len(messages))
+	for i, message := range messages {
+		out[i] = Message{Role: message.Role, Content: message.Content}
+	}
+	return out
+}
+
+// Chat sends the request to an OpenAI-compatible chat completions endpoint and waits for the full response.
+func Chat(ctx context.Context, config *llm.Config, request *ChatRequest) (ChatResponse, error) {
+	if config == nil {
+		return ChatResponse{}, errors.New("config is nil")
+	}
+	if request == nil {
+		return ChatResponse{}, errors.New("request is nil")
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errorString := "(unknown error)"
+		if body, err := io.ReadAll(response.Body); err == nil && len(body) > 0 {
+			errorString = fmt.Sprintf("\n```json\n%v\n```", string(body))
+		}
+		return ChatResponse{}, fmt.Errorf("unexpected status code: %d %s", response.StatusCode, errorString)
+	}
+
+	var out ChatResponse
+	if err := json.NewDecoder(response.Body).Decode(&out); err != nil {
+		return ChatResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return out, nil
+}
@@ -0,0 +1,27 @@
+package openai
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessageParts(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "hello"},
+		{Role: "user", Content: "what is this?", Parts: []ContentPart{
+			TextPart("what is this?"),
+			ImagePart(ImageDataURL("image/png", "iVBORw0KGgo=")),
+		}},
+	}
+
+	body, err := json.Marshal(messages)
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+
+	want := `[{"role":"user","content":"hello"},` +
+		`{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}]`
+	if string(body) != want {
+		t.Errorf("Expected %s, got %s", want, body)
+	}
+}
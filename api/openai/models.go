@@ -0,0 +1,82 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+)
+
+// Models lists the model IDs served by an OpenAI-compatible /v1/models endpoint.
+//
+//	{"object":"list","data":[{"id":"llama3","object":"model","owned_by":"organization_owner"}]}
+func Models(ctx context.Context, config *llm.Config) ([]string, error) {
+	if config == nil {
+		return nil, errors.New("config is nil")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ModelsURL(config.Endpoint).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errorString := "(unknown error)"
+		if body, err := io.ReadAll(response.Body); err == nil && len(body) > 0 {
+			errorString = fmt.Sprintf("\n```json\n%v\n```", string(body))
+		}
+		return nil, fmt.Errorf("unexpected status code: %d %s", response.StatusCode, errorString)
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode models: %w", err)
+	}
+
+	models := make([]string, 0, len(list.Data))
+	for _, model := range list.Data {
+		if model.ID != "" {
+			models = append(models, model.ID)
+		}
+	}
+
+	return models, nil
+}
+
+// ModelsURL returns the models endpoint next to the chat completions endpoint,
+// e.g. http://localhost:7869/v1/chat/completions becomes http://localhost:7869/v1/models.
+// Endpoints without the chat completions path are assumed to be the host root.
+func ModelsURL(endpoint url.URL) *url.URL {
+	path := strings.TrimSuffix(endpoint.Path, "/")
+	if base, ok := strings.CutSuffix(path, "/chat/completions"); ok {
+		path = base + "/models"
+	} else {
+		path += "/v1/models"
+	}
+
+	endpoint.Path = path
+	endpoint.RawPath = ""
+	endpoint.RawQuery = ""
+	return &endpoint
+}
@@ -0,0 +1,71 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+)
+
+func fakeServer(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+apiKey {
+			http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"llama3","object":"model"},{"id":"mistral","object":"model"},{"id":""}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestModels(t *testing.T) {
+	server := fakeServer(t, "secret")
+	endpoint, err := url.Parse(server.URL + "/v1/chat/completions")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	models, err := Models(context.Background(), &llm.Config{APIKey: "secret", Endpoint: *endpoint})
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if want := []string{"llama3", "mistral"}; !slices.Equal(models, want) {
+		t.Errorf("Expected %v, got %v", want, models)
+	}
+
+	_, err = Models(context.Background(), &llm.Config{APIKey: "wrong", Endpoint: *endpoint})
+	if err == nil {
+		t.Error("Expected an error for an invalid API key")
+	}
+}
+
+func TestModelsURL(t *testing.T) {
+	tests := map[string]string{
+		"http://localhost:7869/v1/chat/completions":  "http://localhost:7869/v1/models",
+		"http://localhost:7869/v1/chat/completions/": "http://localhost:7869/v1/models",
+		"https://example.com/api/chat/completions":   "https://example.com/api/models",
+		"http://localhost:7869":                      "http://localhost:7869/v1/models",
+		"http://localhost:7869/":                     "http://localhost:7869/v1/models",
+	}
+
+	for endpoint, want := range tests {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ModelsURL(*u).String(); got != want {
+			t.Errorf("ModelsURL(%s): expected %s, got %s", endpoint, want, got)
+		}
+	}
+}
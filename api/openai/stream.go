@@ -0,0 +1,192 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+)
+
+// Stream sends the request to an OpenAI-compatible chat completions endpoint with stream enabled.
+// onDelta is called with the content accumulated so far every time a chunk arrives.
+// Cancelling ctx closes the connection, and the content received until then is returned along with the context error.
+func Stream(ctx context.Context, config *llm.Config, request *llm.Request, onDelta func(content string)) (llm.Response, error) {
+	if config == nil {
+		return llm.Response{}, errors.New("config is nil")
+	}
+	if request == nil {
+		return llm.Response{}, errors.New("request is nil")
+	}
+
+	streamed := *request
+	streamed.Stream = true
+	streamed.StreamChannel = nil
+
+	body, err := json.Marshal(&streamed)
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	return stream(ctx, config, body, onDelta)
+}
+
+// StreamChat streams a ChatRequest like Stream, for requests with images. Tools are not supported when streaming.
+func StreamChat(ctx context.Context, config *llm.Config, request *ChatRequest, onDelta func(content string)) (llm.Response, error) {
+	if config == nil {
+		return llm.Response{}, errors.New("config is nil")
+	}
+	if request == nil {
+		return llm.Response{}, errors.New("request is nil")
+	}
+
+	streamed := *request
+	streamed.Stream = true
+	streamed.Tools = nil
+
+	body, err := json.Marshal(&streamed)
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	return stream(ctx, config, body, onDelta)
+}
+
+func stream(ctx context.Context, config *llm.Config, body []byte, onDelta func(content string)) (llm.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errorString := "(unknown error)"
+		if body, err := io.ReadAll(response.Body); err == nil && len(body) > 0 {
+			errorString = fmt.Sprintf("\n```json\n%v\n```", string(body))
+		}
+		return llm.Response{}, fmt.Errorf("unexpected status code: %d %s", response.StatusCode, errorString)
+	}
+
+	out, err := readStream(response.Body, onDelta)
+	if ctx.Err() != nil {
+		return out, ctx.Err()
+	}
+
+	return out, err
+}
+
+// readStream reads the server-sent events of a streamed response, where each event is a chunk prefixed with "data: ".
+// The data lines of an event are joined with newlines until the blank line that ends it.
+//
+//	data: {"id":"chatcmpl-123","object":"chat.completion.chunk","model":"llama3","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"},"finish_reason":null}]}
+//
+//	data: [DONE]
+//
+// A stream that ends before [DONE] or a finish reason returns the content so far with io.ErrUnexpectedEOF.
+func readStream(body io.Reader, onDelta func(content string)) (llm.Response, error) {
+	var out llm.Response
+	var content strings.Builder
+	var finishReason string
+	var event [][]byte
+	var done bool
+
+	dispatch := func() error {
+		data := bytes.Join(event, []byte("\n"))
+		event = nil
+		if len(data) == 0 {
+			return nil
+		}
+		if bytes.Equal(data, []byte("[DONE]")) {
+			done = true
+			return nil
+		}
+
+		var chunk llm.Response
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("failed to unmarshal chunk: %w", err)
+		}
+
+		out.ID, out.Object, out.Created, out.Model = chunk.ID, chunk.Object, chunk.Created, chunk.Model
+		if chunk.Usage.TotalTokens > 0 {
+			out.Usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+
+		if chunk.Choices[0].FinishReason != "" {
+			finishReason = chunk.Choices[0].FinishReason
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			content.WriteString(delta)
+			if onDelta != nil {
+				onDelta(content.String())
+			}
+		}
+		return nil
+	}
+
+	result := func(err error) (llm.Response, error) {
+		out.Choices = []llm.Choice{{
+			Message: llm.Message{
+				Role:    llm.AssistantRole,
+				Content: content.String(),
+			},
+			FinishReason: finishReason,
+		}}
+		return out, err
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for !done && scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err := dispatch(); err != nil {
+				return result(err)
+			}
+			continue
+		}
+
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			// comments and the other fields of an event are ignored
+			continue
+		}
+		event = append(event, bytes.TrimPrefix(bytes.TrimRight(data, "\r"), []byte(" ")))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return result(err)
+	}
+
+	if !done && len(event) > 0 {
+		// the last event may not be followed by a blank line, but it's incomplete if it can't be read
+		if err := dispatch(); err != nil {
+			return result(fmt.Errorf("%w: %w", io.ErrUnexpectedEOF, err))
+		}
+	}
+
+	if !done && finishReason == "" {
+		return result(io.ErrUnexpectedEOF)
+	}
+
+	return result(nil)
+}
@@ -0,0 +1,93 @@
+package openai
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		content string
+		finish  string
+		deltas  int
+		wantErr error
+	}{
+		{
+			name: "done",
+			stream: `data: {"id":"chatcmpl-1","model":"llama3","choices":[{"index":0,"delta":{"role":"assistant","content":"Hel"}}]}
+
+: keep-alive
+
+data: {"id":"chatcmpl-1","model":"llama3","choices":[{"index":0,"delta":{"content":"lo"},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+data: {"id":"chatcmpl-1","model":"llama3","choices":[{"index":0,"delta":{"content":" ignored"}}]}
+
+`,
+			content: "Hello",
+			finish:  "stop",
+			deltas:  2,
+		},
+		{
+			name: "multi-line data",
+			stream: "data: {\"id\":\"chatcmpl-2\",\r\n" +
+				"data: \"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\r\n" +
+				"\r\n" +
+				"data:[DONE]\r\n\r\n",
+			content: "Hi",
+			deltas:  1,
+		},
+		{
+			name: "finish reason without done",
+			stream: `data: {"id":"chatcmpl-3","choices":[{"index":0,"delta":{"content":"Bye"},"finish_reason":"length"}]}
+`,
+			content: "Bye",
+			finish:  "length",
+			deltas:  1,
+		},
+		{
+			name: "ends between events",
+			stream: `data: {"id":"chatcmpl-4","choices":[{"index":0,"delta":{"content":"Par"}}]}
+
+`,
+			content: "Par",
+			deltas:  1,
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name: "ends within an event",
+			stream: `data: {"id":"chatcmpl-5","choices":[{"index":0,"delta":{"content":"Par"}}]}
+
+data: {"id":"chatcmpl-5","choices":[{"index":0,"delta":{"cont`,
+			content: "Par",
+			deltas:  1,
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas int
+			response, err := readStream(strings.NewReader(tt.stream), func(string) { deltas++ })
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expected %v, got %v", tt.wantErr, err)
+			}
+			if len(response.Choices) != 1 {
+				t.Fatalf("Expected a single choice, got %d", len(response.Choices))
+			}
+
+			choice := response.Choices[0]
+			if choice.Message.Content != tt.content || choice.FinishReason != tt.finish {
+				t.Errorf("Expected %q with finish reason %q, got %q with %q", tt.content, tt.finish, choice.Message.Content, choice.FinishReason)
+			}
+			if deltas != tt.deltas {
+				t.Errorf("Expected %d deltas, got %d", tt.deltas, deltas)
+			}
+		})
+	}
+}
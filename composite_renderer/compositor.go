@@ -3,6 +3,7 @@ package composite_renderer
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"image"
 	"image/draw"
 	"image/png"
@@ -69,19 +70,54 @@ func determineLayout(numImages int, images []image.Image) (rows, cols int) {
 		return 1, 1
 	}
 
+	// Adjust for aspect ratios
+	portraitCount, landscapeCount, squareCount := countImageTypes(images)
+	return layout(numImages, landscapeCount > portraitCount && landscapeCount > squareCount)
+}
+
+func layout(numImages int, landscape bool) (rows, cols int) {
 	// Basic heuristic: prefer more columns than rows to minimize empty space
 	cols = int(math.Ceil(math.Sqrt(float64(numImages))))
 	rows = int(math.Ceil(float64(numImages) / float64(cols)))
 
-	// Adjust for aspect ratios
-	portraitCount, landscapeCount, squareCount := countImageTypes(images)
-	if landscapeCount > portraitCount && landscapeCount > squareCount {
+	if landscape {
 		rows, cols = cols, rows // Prefer wider layout for mostly landscape images
 	}
 
 	return
 }
 
+// TileCell cuts out the image at index from a tile made by TileImages of count images of the same size.
+// landscape is whether the images are wider than they are tall, as the layout of the tile depends on it.
+func TileCell(tile io.Reader, index, count int, landscape bool) (io.Reader, error) {
+	if index < 0 || index >= count {
+		return nil, fmt.Errorf("image %d is not part of a tile of %d images", index+1, count)
+	}
+	if count == 1 {
+		return tile, nil
+	}
+
+	img, _, err := image.Decode(tile)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, cols := layout(count, landscape)
+	bounds := img.Bounds()
+	width, height := bounds.Dx()/cols, bounds.Dy()/rows
+	x, y := bounds.Min.X+index%cols*width, bounds.Min.Y+index/cols*height
+
+	cell := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(cell, cell.Bounds(), img, image.Pt(x, y), draw.Src)
+
+	imageBuf := new(bytes.Buffer)
+	if err := png.Encode(imageBuf, cell); err != nil {
+		return nil, err
+	}
+
+	return imageBuf, nil
+}
+
 func calculateCanvasSize(images []image.Image, rows, cols int) (width, height int) {
 	maxWidthPerColumn := make([]int, cols)
 	maxHeightPerRow := make([]int, rows)
@@ -0,0 +1,56 @@
+package composite_renderer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"testing"
+)
+
+func TestTileCell(t *testing.T) {
+	for _, size := range []image.Point{{X: 8, Y: 12}, {X: 12, Y: 8}} {
+		const count = 6
+		images := make([]io.Reader, count)
+		for i := range images {
+			img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+			for x := range size.X {
+				for y := range size.Y {
+					img.Set(x, y, color.RGBA{R: uint8(i * 40), A: 255})
+				}
+			}
+			buf := new(bytes.Buffer)
+			if err := png.Encode(buf, img); err != nil {
+				t.Fatal(err)
+			}
+			images[i] = buf
+		}
+
+		tile, err := Compositor().TileImages(images)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tileBytes, err := io.ReadAll(tile)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range count {
+			cell, err := TileCell(bytes.NewReader(tileBytes), i, count, size.X > size.Y)
+			if err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+			img, _, err := image.Decode(cell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := img.Bounds().Size(); got != size {
+				t.Errorf("Expected image %d to be %v, got %v", i+1, size, got)
+			}
+			if r, _, _, _ := img.At(size.X/2, size.Y/2).RGBA(); uint8(r>>8) != uint8(i*40) {
+				t.Errorf("Expected image %d of the %v tile, got the color of another image", i+1, size)
+			}
+		}
+	}
+}
@@ -106,6 +106,226 @@ const addHypernetworkQuery string = `
 ALTER TABLE image_generations ADD COLUMN hypernetwork TEXT;
 `
 
+const createVibeSetTablesIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS vibe_sets (
+id INTEGER NOT NULL PRIMARY KEY,
+member_id TEXT NOT NULL,
+name TEXT NOT NULL,
+created_at DATETIME NOT NULL,
+UNIQUE (member_id, name)
+);
+CREATE TABLE IF NOT EXISTS vibe_set_images (
+id INTEGER NOT NULL PRIMARY KEY,
+vibe_set_id INTEGER NOT NULL,
+sort_order INTEGER NOT NULL,
+image BLOB NOT NULL,
+information_extracted REAL NOT NULL,
+reference_strength REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS vibe_set_images_set_index
+ON vibe_set_images(vibe_set_id);
+`
+
+const createNovelAIGenerationTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS novelai_generations (
+id INTEGER NOT NULL PRIMARY KEY,
+interaction_id TEXT NOT NULL,
+message_id TEXT NOT NULL,
+member_id TEXT NOT NULL,
+sort_order INTEGER NOT NULL,
+request TEXT NOT NULL,
+metadata TEXT NOT NULL DEFAULT 'null',
+created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS novelai_generation_message_index
+ON novelai_generations(message_id);
+`
+
+const createNovelAISettingsTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS novelai_settings (
+member_id TEXT NOT NULL PRIMARY KEY,
+model TEXT NOT NULL DEFAULT '',
+size TEXT NOT NULL DEFAULT '',
+sampler TEXT NOT NULL DEFAULT '',
+schedule TEXT NOT NULL DEFAULT '',
+uc_preset INTEGER,
+quality_toggle INTEGER,
+scale REAL,
+steps INTEGER
+);`
+
+const createLLMConversationTablesIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS llm_conversations (
+id INTEGER NOT NULL PRIMARY KEY,
+thread_id TEXT NOT NULL UNIQUE,
+member_id TEXT NOT NULL,
+model TEXT NOT NULL,
+max_tokens INTEGER NOT NULL,
+created_at DATETIME NOT NULL
+);
+CREATE TABLE IF NOT EXISTS llm_conversation_messages (
+id INTEGER NOT NULL PRIMARY KEY,
+conversation_id INTEGER NOT NULL,
+role TEXT NOT NULL,
+content TEXT NOT NULL,
+created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS llm_conversation_messages_conversation_index
+ON llm_conversation_messages(conversation_id);
+`
+
+const addOriginalPromptQuery string = `
+ALTER TABLE image_generations ADD COLUMN original_prompt TEXT NOT NULL DEFAULT '';
+`
+
+const addParentGenerationQuery string = `
+ALTER TABLE image_generations ADD COLUMN parent_id INTEGER NOT NULL DEFAULT 0;
+`
+
+const createLLMSettingsTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS llm_settings (
+member_id TEXT NOT NULL PRIMARY KEY,
+model TEXT NOT NULL DEFAULT ''
+);`
+
+const addLLMEndpointColumnsQuery string = `
+ALTER TABLE llm_settings ADD COLUMN endpoint TEXT NOT NULL DEFAULT '';
+ALTER TABLE llm_conversations ADD COLUMN endpoint TEXT NOT NULL DEFAULT '';
+`
+
+const addLLMConversationToolsColumnQuery string = `
+ALTER TABLE llm_conversations ADD COLUMN tools INTEGER NOT NULL DEFAULT 0;
+`
+
+// addLLMConversationTemperatureColumnQuery leaves the temperature of the conversations stored before it NULL,
+// which continue with the default temperature.
+const addLLMConversationTemperatureColumnQuery string = `
+ALTER TABLE llm_conversations ADD COLUMN temperature REAL;
+`
+
+const createLLMPersonasTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS llm_personas (
+id INTEGER NOT NULL PRIMARY KEY,
+guild_id TEXT NOT NULL,
+name TEXT NOT NULL,
+system_prompt TEXT NOT NULL,
+temperature REAL NOT NULL,
+max_tokens INTEGER NOT NULL,
+model TEXT NOT NULL DEFAULT '',
+is_default INTEGER NOT NULL DEFAULT 0,
+created_by TEXT NOT NULL,
+created_at DATETIME NOT NULL,
+UNIQUE (guild_id, name)
+);`
+
+const addGenerationLocationQuery string = `
+ALTER TABLE image_generations ADD COLUMN guild_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE image_generations ADD COLUMN channel_id TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS generation_member_index ON image_generations (member_id, created_at);
+`
+
+const createFavoritesTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS favorites (
+id INTEGER NOT NULL PRIMARY KEY,
+member_id TEXT NOT NULL,
+guild_id TEXT NOT NULL,
+channel_id TEXT NOT NULL,
+message_id TEXT NOT NULL,
+sort_order INTEGER NOT NULL,
+attachment_url TEXT NOT NULL,
+created_at DATETIME NOT NULL,
+UNIQUE (member_id, message_id, sort_order)
+);
+CREATE INDEX IF NOT EXISTS favorites_guild_index ON favorites (guild_id);
+`
+
+// createGenerationSearchQuery indexes the prompts of the generations for full-text search,
+// kept in sync with image_generations by the triggers.
+const createGenerationSearchQuery string = `
+CREATE VIRTUAL TABLE IF NOT EXISTS image_generations_fts USING fts5(
+prompt, negative_prompt, content='image_generations', content_rowid='id'
+);
+INSERT INTO image_generations_fts (image_generations_fts) VALUES ('rebuild');
+CREATE TRIGGER IF NOT EXISTS image_generations_fts_insert AFTER INSERT ON image_generations BEGIN
+    INSERT INTO image_generations_fts (rowid, prompt, negative_prompt) VALUES (new.id, new.prompt, new.negative_prompt);
+END;
+CREATE TRIGGER IF NOT EXISTS image_generations_fts_delete AFTER DELETE ON image_generations BEGIN
+    INSERT INTO image_generations_fts (image_generations_fts, rowid, prompt, negative_prompt) VALUES ('delete', old.id, old.prompt, old.negative_prompt);
+END;
+CREATE TRIGGER IF NOT EXISTS image_generations_fts_update AFTER UPDATE ON image_generations BEGIN
+    INSERT INTO image_generations_fts (image_generations_fts, rowid, prompt, negative_prompt) VALUES ('delete', old.id, old.prompt, old.negative_prompt);
+    INSERT INTO image_generations_fts (rowid, prompt, negative_prompt) VALUES (new.id, new.prompt, new.negative_prompt);
+END;
+`
+
+const createGuildSettingsTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS guild_settings (
+guild_id TEXT NOT NULL PRIMARY KEY,
+allowed_commands TEXT NOT NULL DEFAULT '',
+default_checkpoint TEXT NOT NULL DEFAULT '',
+max_width INTEGER NOT NULL DEFAULT 0,
+max_height INTEGER NOT NULL DEFAULT 0,
+nsfw_policy TEXT NOT NULL DEFAULT '',
+queue_limit INTEGER NOT NULL DEFAULT 0,
+announcement_channel_id TEXT NOT NULL DEFAULT ''
+);`
+
+const addDefaultSettingsProfileQuery string = `
+ALTER TABLE default_settings ADD COLUMN sampler_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE default_settings ADD COLUMN steps INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE default_settings ADD COLUMN cfg_scale REAL NOT NULL DEFAULT 0;
+ALTER TABLE default_settings ADD COLUMN negative_prompt TEXT NOT NULL DEFAULT '';
+ALTER TABLE default_settings ADD COLUMN checkpoint TEXT NOT NULL DEFAULT '';
+ALTER TABLE default_settings ADD COLUMN vae TEXT NOT NULL DEFAULT '';
+ALTER TABLE default_settings ADD COLUMN clip_skip INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE default_settings ADD COLUMN enable_hr INTEGER;
+ALTER TABLE default_settings ADD COLUMN hr_scale REAL NOT NULL DEFAULT 0;
+ALTER TABLE default_settings ADD COLUMN hr_upscaler TEXT NOT NULL DEFAULT '';
+ALTER TABLE default_settings ADD COLUMN hr_steps INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE default_settings ADD COLUMN denoising_strength REAL NOT NULL DEFAULT 0;
+ALTER TABLE default_settings ADD COLUMN adetailer TEXT NOT NULL DEFAULT '';
+`
+
+// createStylesTableIfNotExistsQuery keeps the names of the personal styles unique per member,
+// and the names of the shared styles unique per guild.
+const createStylesTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS styles (
+id INTEGER NOT NULL PRIMARY KEY,
+name TEXT NOT NULL,
+member_id TEXT NOT NULL,
+guild_id TEXT NOT NULL DEFAULT '',
+prompt TEXT NOT NULL,
+negative_prompt TEXT NOT NULL DEFAULT '',
+sampler_name TEXT NOT NULL DEFAULT '',
+steps INTEGER NOT NULL DEFAULT 0,
+cfg_scale REAL NOT NULL DEFAULT 0,
+width INTEGER NOT NULL DEFAULT 0,
+height INTEGER NOT NULL DEFAULT 0,
+loras TEXT NOT NULL DEFAULT '',
+created_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS style_member_name_index ON styles (member_id, name) WHERE guild_id = '';
+CREATE UNIQUE INDEX IF NOT EXISTS style_guild_name_index ON styles (guild_id, name) WHERE guild_id != '';
+`
+
+const addGenerationStyleQuery string = `
+ALTER TABLE image_generations ADD COLUMN style TEXT NOT NULL DEFAULT '';
+`
+
+// createWildcardsTableIfNotExistsQuery stores the values of a wildcard separated by newlines.
+const createWildcardsTableIfNotExistsQuery string = `
+CREATE TABLE IF NOT EXISTS wildcards (
+id INTEGER NOT NULL PRIMARY KEY,
+name TEXT NOT NULL,
+guild_id TEXT NOT NULL,
+wildcard_values TEXT NOT NULL,
+member_id TEXT NOT NULL,
+created_at DATETIME NOT NULL,
+updated_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS wildcard_guild_name_index ON wildcards (guild_id, name);
+`
+
 type migration struct {
 	migrationName  string
 	migrationQuery string
@@ -126,6 +346,25 @@ var migrations = []migration{
 	{migrationName: "add checkpoint column", migrationQuery: addCheckpointQuery},
 	{migrationName: "add vae column", migrationQuery: addVAEQuery},
 	{migrationName: "add hypernetwork column", migrationQuery: addHypernetworkQuery},
+	{migrationName: "create vibe set tables", migrationQuery: createVibeSetTablesIfNotExistsQuery},
+	{migrationName: "create novelai generation table", migrationQuery: createNovelAIGenerationTableIfNotExistsQuery},
+	{migrationName: "create novelai settings table", migrationQuery: createNovelAISettingsTableIfNotExistsQuery},
+	{migrationName: "create llm conversation tables", migrationQuery: createLLMConversationTablesIfNotExistsQuery},
+	{migrationName: "add original prompt column", migrationQuery: addOriginalPromptQuery},
+	{migrationName: "create llm settings table", migrationQuery: createLLMSettingsTableIfNotExistsQuery},
+	{migrationName: "add llm endpoint columns", migrationQuery: addLLMEndpointColumnsQuery},
+	{migrationName: "add llm conversation tools column", migrationQuery: addLLMConversationToolsColumnQuery},
+	{migrationName: "create llm personas table", migrationQuery: createLLMPersonasTableIfNotExistsQuery},
+	{migrationName: "add parent generation column", migrationQuery: addParentGenerationQuery},
+	{migrationName: "create favorites table", migrationQuery: createFavoritesTableIfNotExistsQuery},
+	{migrationName: "add generation location columns", migrationQuery: addGenerationLocationQuery},
+	{migrationName: "create generation search table", migrationQuery: createGenerationSearchQuery},
+	{migrationName: "create guild settings table", migrationQuery: createGuildSettingsTableIfNotExistsQuery},
+	{migrationName: "add default settings profile columns", migrationQuery: addDefaultSettingsProfileQuery},
+	{migrationName: "create styles table", migrationQuery: createStylesTableIfNotExistsQuery},
+	{migrationName: "add generation style column", migrationQuery: addGenerationStyleQuery},
+	{migrationName: "create wildcards table", migrationQuery: createWildcardsTableIfNotExistsQuery},
+	{migrationName: "add llm conversation temperature column", migrationQuery: addLLMConversationTemperatureColumnQuery},
 }
 
 func New(ctx context.Context) (*sql.DB, error) {
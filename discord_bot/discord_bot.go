@@ -16,6 +16,7 @@ import (
 	"stable_diffusion_bot/queue/llm"
 	"stable_diffusion_bot/queue/novelai"
 	"stable_diffusion_bot/queue/stable_diffusion"
+	"stable_diffusion_bot/repositories/guild_settings"
 	"stable_diffusion_bot/utils"
 
 	"github.com/bwmarrin/discordgo"
@@ -40,6 +41,8 @@ type Config struct {
 	NovelAIQueue   queue.Queue[*novelai.NAIQueueItem]
 	LLMQueue       queue.Queue[*llm.LLMItem]
 	RemoveCommands bool
+	// GuildSettingsRepo enables /server_settings and the commands each server allows. It's optional.
+	GuildSettingsRepo guild_settings.Repository
 }
 
 func New(cfg *Config) (Bot, error) {
@@ -70,6 +73,11 @@ func New(cfg *Config) (Bot, error) {
 	}
 	queues = slices.DeleteFunc(queues, func(q queue.HandlerStartStopper) bool { return q == nil })
 
+	if slices.ContainsFunc(queues, isMessageHandler) {
+		// Reading the content of replies requires the privileged message content intent to be enabled for the bot.
+		botSession.Identify.Intents |= discordgo.IntentsGuildMessages | discordgo.IntentMessageContent
+	}
+
 	bot := &botImpl{
 		botSession:         botSession,
 		registeredCommands: make(map[handlers.Command]*discordgo.ApplicationCommand),
@@ -84,16 +92,25 @@ func New(cfg *Config) (Bot, error) {
 
 func (b *botImpl) registerHandlers() {
 	for _, q := range b.queues {
-		handlers := q.Handlers()
-		for interactionType, commandHandlers := range handlers {
+		for interactionType, commandHandlers := range q.Handlers() {
 			if _, ok := b.handlers[interactionType]; !ok {
-				maps.Copy(b.handlers, handlers)
-			} else {
-				maps.Copy(b.handlers[interactionType], commandHandlers)
+				b.handlers[interactionType] = make(map[queue.Command]queue.Handler)
 			}
+			maps.Copy(b.handlers[interactionType], commandHandlers)
 		}
 
 		maps.Copy(b.components, q.Components())
+
+		if isMessageHandler(q) {
+			b.botSession.AddHandler(q.(queue.MessageHandler).MessageCreate)
+		}
+	}
+
+	if b.config.GuildSettingsRepo != nil {
+		if _, ok := b.handlers[discordgo.InteractionApplicationCommand]; !ok {
+			b.handlers[discordgo.InteractionApplicationCommand] = make(map[queue.Command]queue.Handler)
+		}
+		b.handlers[discordgo.InteractionApplicationCommand][ServerSettingsCommand] = b.processServerSettingsCommand
 	}
 
 	b.botSession.AddHandler(func(session *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -102,6 +119,10 @@ func (b *botImpl) registerHandlers() {
 		if i.Type == discordgo.InteractionMessageComponent {
 			log.Printf("Component with customID `%v` was pressed, attempting to respond\n", i.MessageComponentData().CustomID)
 			handler, ok = b.components[i.MessageComponentData().CustomID]
+			if !ok {
+				customID, _ := handlers.Arguments(i.MessageComponentData().CustomID)
+				handler, ok = b.components[customID]
+			}
 		} else {
 			handles, exist := b.handlers[i.Type]
 			if !exist {
@@ -109,7 +130,15 @@ func (b *botImpl) registerHandlers() {
 				return
 			}
 
-			handler, ok = handles[i.ApplicationCommandData().Name]
+			if i.Type == discordgo.InteractionModalSubmit {
+				handler, ok = handles[i.ModalSubmitData().CustomID]
+				if !ok {
+					customID, _ := handlers.Arguments(i.ModalSubmitData().CustomID)
+					handler, ok = handles[customID]
+				}
+			} else {
+				handler, ok = handles[i.ApplicationCommandData().Name]
+			}
 		}
 
 		if !ok || handler == nil {
@@ -141,6 +170,14 @@ func (b *botImpl) registerHandlers() {
 			return
 		}
 
+		if command, ok := b.allowed(i); !ok {
+			err := handlers.ErrorEphemeral(session, i.Interaction, fmt.Sprintf("`%s` is not allowed in this server.", command))
+			if err != nil {
+				log.Printf("Error showing error message to user %s: %v", utils.GetUsername(i.Interaction), err)
+			}
+			return
+		}
+
 		err := handler(session, i)
 
 		if err != nil {
@@ -157,28 +194,72 @@ func (b *botImpl) registerHandlers() {
 	})
 }
 
+// maxMessageCommands is the number of message commands Discord allows an application to have.
+const maxMessageCommands = 5
+
 func (b *botImpl) registerCommands() error {
 	b.registeredCommands = make(map[handlers.Command]*discordgo.ApplicationCommand)
 
+	var messageCommands [][]*discordgo.ApplicationCommand
+	var rounds int
 	for _, q := range b.queues {
 		if q == nil {
 			continue
 		}
 
+		var queueMessageCommands []*discordgo.ApplicationCommand
 		for _, command := range q.Commands() {
-			cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.config.GuildID, command)
-			if err != nil {
-				return fmt.Errorf("cannot create '%s' command: %w", command.Name, err)
+			if command.Type == discordgo.MessageApplicationCommand {
+				queueMessageCommands = append(queueMessageCommands, command)
+				continue
+			}
+			if err := b.registerCommand(command); err != nil {
+				return err
 			}
+		}
+		messageCommands = append(messageCommands, queueMessageCommands)
+		rounds = max(rounds, len(queueMessageCommands))
+	}
 
-			b.registeredCommands[command.Name] = cmd
-			log.Printf("Registered %v command as: /%v", command.Name, cmd.Name)
+	if b.config.GuildSettingsRepo != nil {
+		if err := b.registerCommand(serverSettingsCommand()); err != nil {
+			return err
+		}
+	}
+
+	// the message commands are taken from each queue in turn, so that every queue keeps its first ones
+	// when there are more than Discord allows
+	var registered int
+	for round := range rounds {
+		for _, commands := range messageCommands {
+			if round >= len(commands) {
+				continue
+			}
+			if registered == maxMessageCommands {
+				log.Printf("WARNING: Skipping the '%v' message command, only %d message commands are allowed", commands[round].Name, maxMessageCommands)
+				continue
+			}
+			if err := b.registerCommand(commands[round]); err != nil {
+				return err
+			}
+			registered++
 		}
 	}
 
 	return nil
 }
 
+func (b *botImpl) registerCommand(command *discordgo.ApplicationCommand) error {
+	cmd, err := b.botSession.ApplicationCommandCreate(b.botSession.State.User.ID, b.config.GuildID, command)
+	if err != nil {
+		return fmt.Errorf("cannot create '%s' command: %w", command.Name, err)
+	}
+
+	b.registeredCommands[command.Name] = cmd
+	log.Printf("Registered %v command as: /%v", command.Name, cmd.Name)
+	return nil
+}
+
 func (b *botImpl) Start() error {
 	b.botSession.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
 		log.Printf("Logged in as: %v#%v", s.State.User.Username, s.State.User.Discriminator)
@@ -233,6 +314,11 @@ func (b *botImpl) Start() error {
 	return nil
 }
 
+func isMessageHandler(q queue.HandlerStartStopper) bool {
+	handler, ok := q.(queue.MessageHandler)
+	return ok && handler.HandlesMessages()
+}
+
 func IsNil(q queue.StartStop) bool {
 	return q == nil
 }
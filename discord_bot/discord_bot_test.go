@@ -0,0 +1,147 @@
+package discord_bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/api/stable_diffusion_api"
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/queue/llm"
+	"stable_diffusion_bot/queue/novelai"
+	"stable_diffusion_bot/queue/stable_diffusion"
+	"stable_diffusion_bot/repositories/default_settings"
+	"stable_diffusion_bot/repositories/favorites"
+	"stable_diffusion_bot/repositories/image_generations"
+)
+
+// The commands are only registered, so the dependencies of the queues are never called.
+type fakeAPI struct {
+	stable_diffusion_api.StableDiffusionAPI
+}
+
+type fakeGenerations struct{ image_generations.Repository }
+
+type fakeDefaultSettings struct{ default_settings.Repository }
+
+type fakeFavorites struct{ favorites.Repository }
+
+// fakeCommands answers the commands registered in the guild, recording the message commands.
+func fakeCommands(t *testing.T) *[]string {
+	t.Helper()
+	var messageCommands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var command discordgo.ApplicationCommand
+		if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+			t.Errorf("Expected a command, got %v", err)
+		}
+		if command.Type == discordgo.MessageApplicationCommand {
+			messageCommands = append(messageCommands, command.Name)
+		}
+
+		command.ID = command.Name
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(command)
+	}))
+	t.Cleanup(server.Close)
+
+	endpoint := discordgo.EndpointApplicationGuildCommands
+	discordgo.EndpointApplicationGuildCommands = func(appID, guildID string) string {
+		return server.URL + "/applications/" + appID + "/guilds/" + guildID + "/commands"
+	}
+	t.Cleanup(func() { discordgo.EndpointApplicationGuildCommands = endpoint })
+
+	return &messageCommands
+}
+
+// newBot creates a bot with all the queues.
+func newBot(t *testing.T) *botImpl {
+	t.Helper()
+	imagineQueue, err := stable_diffusion.New(stable_diffusion.Config{
+		StableDiffusionAPI:  fakeAPI{},
+		ImageGenerationRepo: fakeGenerations{},
+		DefaultSettingsRepo: fakeDefaultSettings{},
+		FavoriteRepo:        fakeFavorites{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	llmQueue, err := llm.New(llm.Config{Endpoints: []*llm.Endpoint{{Name: "local", URL: "http://localhost"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := "token"
+
+	bot, err := New(&Config{
+		BotToken:     token,
+		GuildID:      "guild",
+		ImagineQueue: imagineQueue,
+		NovelAIQueue: novelai.New(novelai.Config{Token: &token}),
+		LLMQueue:     llmQueue,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bot.(*botImpl)
+	b.botSession.State.User = &discordgo.User{ID: "bot"}
+	return b
+}
+
+func TestRegisterMessageCommands(t *testing.T) {
+	b := newBot(t)
+
+	messageCommands := fakeCommands(t)
+	if err := b.registerCommands(); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+
+	var want int
+	for _, q := range b.queues {
+		for _, command := range q.Commands() {
+			if command.Type == discordgo.MessageApplicationCommand {
+				want++
+			}
+		}
+	}
+	if want > maxMessageCommands {
+		t.Errorf("Expected the queues to have at most %d message commands, got %d", maxMessageCommands, want)
+	}
+	if len(*messageCommands) != want {
+		t.Errorf("Expected all %d message commands to be registered, got %v", want, *messageCommands)
+	}
+}
+
+func TestCommandOf(t *testing.T) {
+	b := newBot(t)
+
+	for customID, want := range map[string]string{
+		stable_diffusion.RerollButton:                                      stable_diffusion.ImagineCommand,
+		stable_diffusion.UpscaleButton + "_2":                              stable_diffusion.ImagineCommand,
+		stable_diffusion.EditModal:                                         stable_diffusion.ImagineCommand,
+		stable_diffusion.GalleryReimagineButton + "_1":                     stable_diffusion.GalleryCommand,
+		stable_diffusion.CheckpointSelect:                                  stable_diffusion.ImagineSettingsCommand,
+		stable_diffusion.UseImageSelect:                                    stable_diffusion.UseImageMessage,
+		handlers.WithArguments(stable_diffusion.FavoriteSelect, "message"): stable_diffusion.ImagineCommand,
+		stable_diffusion.JSONInput:                                         stable_diffusion.RawCommand,
+		"novelai_reroll":                                                   novelai.NovelAICommand,
+		"novelai_raw_modal":                                                novelai.NovelAIRawCommand,
+		"llm_ask_modal":                                                    llm.LLMAskMessage,
+		handlers.DeleteButton:                                              "",
+		handlers.PaginationNext:                                            "",
+	} {
+		if command := b.commandOf(customID); command != want {
+			t.Errorf("Expected %s to belong to %q, got %q", customID, want, command)
+		}
+	}
+}
+
+func TestMessageContentIntent(t *testing.T) {
+	b := newBot(t)
+
+	if b.botSession.Identify.Intents&discordgo.IntentMessageContent != 0 {
+		t.Error("Expected the message content intent to only be requested when conversations are enabled")
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// altTextLength is Discord's limit for the description of an attachment.
+const altTextLength = 1024
+
+// describedAttachment is an attachment with alt text, which discordgo.MessageAttachment has no field for.
+type describedAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	Description string `json:"description,omitempty"`
+}
+
+// describedEdit replaces the attachments of the edit with the described ones.
+type describedEdit struct {
+	*discordgo.WebhookEdit
+	Attachments []describedAttachment `json:"attachments"`
+}
+
+// EditInteractionResponseAltText edits the response like EditInteractionResponse, with the files of webhookEdit
+// uploaded along with the alt text under their name in altText, for screen readers.
+// discordgo can't send the descriptions of attachments, so the multipart request is built here instead.
+func EditInteractionResponseAltText(bot *discordgo.Session, i *discordgo.Interaction, webhookEdit *discordgo.WebhookEdit, altText map[string]string) (*discordgo.Message, error) {
+	if len(webhookEdit.Files) == 0 || len(altText) == 0 {
+		return EditInteractionResponse(bot, i, webhookEdit)
+	}
+
+	edit := describedEdit{WebhookEdit: webhookEdit}
+	for index, file := range webhookEdit.Files {
+		description := []rune(altText[file.Name])
+		if len(description) > altTextLength {
+			description = description[:altTextLength]
+		}
+		edit.Attachments = append(edit.Attachments, describedAttachment{
+			ID:          strconv.Itoa(index),
+			Filename:    file.Name,
+			Description: string(description),
+		})
+	}
+
+	contentType, body, err := discordgo.MultipartBodyWithJSON(edit, webhookEdit.Files)
+	if err != nil {
+		return nil, Wrap(err)
+	}
+
+	uri := discordgo.EndpointWebhookMessage(i.AppID, i.Token, "@original")
+	bucket := uri
+	if isMessageInteraction(i) {
+		uri = discordgo.EndpointChannelMessage(i.ChannelID, i.Message.ID)
+		bucket = discordgo.EndpointChannelMessage(i.ChannelID, "")
+	}
+
+	response, err := bot.RequestWithLockedBucket(http.MethodPatch, uri, contentType, body, bot.Ratelimiter.LockBucket(bucket), 0)
+	if err != nil {
+		return nil, Wrap(err)
+	}
+
+	var msg *discordgo.Message
+	if err := json.Unmarshal(response, &msg); err != nil {
+		return nil, Wrap(err)
+	}
+
+	return msg, nil
+}
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/utils"
 )
 
 type (
@@ -25,6 +27,35 @@ var Commands = map[Command]*discordgo.ApplicationCommand{
 	},
 }
 
+// CanManageGuild reports whether the member can manage the guild, which lets them manage what other members made,
+// such as their LLM personas, or look at their history.
+func CanManageGuild(i *discordgo.Interaction) bool {
+	return i.Member != nil && i.Member.Permissions&(discordgo.PermissionManageGuild|discordgo.PermissionAdministrator) != 0
+}
+
+// IsBotOwner reports whether the user of i owns the bot application, or is in the team that owns it,
+// which lets them change what the bot does in every server.
+func IsBotOwner(s *discordgo.Session, i *discordgo.Interaction) (bool, error) {
+	user := utils.GetUser(i)
+	if user == nil {
+		return false, nil
+	}
+
+	application, err := s.Application("@me")
+	if err != nil {
+		return false, err
+	}
+	if application.Team != nil {
+		for _, member := range application.Team.Members {
+			if member.User != nil && member.User.ID == user.ID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return application.Owner != nil && application.Owner.ID == user.ID, nil
+}
+
 const (
 	maskedUser    = "user"
 	maskedChannel = "channel"
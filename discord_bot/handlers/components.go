@@ -17,8 +17,10 @@ const (
 
 	readmoreDismiss Component = "readmore_dismiss"
 
-	paginationButtons Component = "pagination_button"
-	okCancelButtons   Component = "ok_cancel_buttons"
+	paginationButtons  Component = "pagination_button"
+	PaginationPrevious Component = paginationButtons + "_previous"
+	PaginationNext     Component = paginationButtons + "_next"
+	okCancelButtons    Component = "ok_cancel_buttons"
 
 	Cancel    Component = "cancel"
 	Interrupt Component = "interrupt"
@@ -122,12 +124,12 @@ var Components = map[Component]discordgo.MessageComponent{
 			discordgo.Button{
 				Label:    "Previous",
 				Style:    discordgo.SecondaryButton,
-				CustomID: paginationButtons + "_previous",
+				CustomID: PaginationPrevious,
 			},
 			discordgo.Button{
 				Label:    "Next",
 				Style:    discordgo.SecondaryButton,
-				CustomID: paginationButtons + "_next",
+				CustomID: PaginationNext,
 			},
 		},
 	},
@@ -200,3 +202,17 @@ var Components = map[Component]discordgo.MessageComponent{
 		},
 	},
 }
+
+// Pagination returns the pagination buttons for page out of pages, starting from 0,
+// with the buttons disabled on the first and last page.
+func Pagination(page, pages int) discordgo.ActionsRow {
+	row := Components[paginationButtons].(discordgo.ActionsRow)
+	previous := row.Components[0].(discordgo.Button)
+	next := row.Components[1].(discordgo.Button)
+	previous.Disabled = page <= 0
+	next.Disabled = page >= pages-1
+
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{previous, next},
+	}
+}
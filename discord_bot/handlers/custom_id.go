@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"strings"
+)
+
+// argumentSeparator separates the arguments carried by the custom ID of a component or modal, such as the
+// message an ephemeral component acts on, so that the component holds its own state instead of the queue.
+const argumentSeparator = ":"
+
+// WithArguments appends the arguments to the custom ID. The handler is still found by the custom ID alone.
+func WithArguments(customID Component, arguments ...string) string {
+	return strings.Join(append([]string{customID}, arguments...), argumentSeparator)
+}
+
+// Arguments splits a custom ID made by WithArguments into the custom ID and its arguments.
+func Arguments(customID string) (Component, []string) {
+	id, arguments, found := strings.Cut(customID, argumentSeparator)
+	if !found {
+		return customID, nil
+	}
+	return id, strings.Split(arguments, argumentSeparator)
+}
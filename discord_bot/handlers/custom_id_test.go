@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestArguments(t *testing.T) {
+	customID, arguments := Arguments(WithArguments("imagine_pick_upscale", "message", "3"))
+	if customID != "imagine_pick_upscale" || !slices.Equal(arguments, []string{"message", "3"}) {
+		t.Errorf("Expected imagine_pick_upscale with [message 3], got %s with %v", customID, arguments)
+	}
+
+	customID, arguments = Arguments("imagine_reroll")
+	if customID != "imagine_reroll" || arguments != nil {
+		t.Errorf("Expected imagine_reroll without arguments, got %s with %v", customID, arguments)
+	}
+}
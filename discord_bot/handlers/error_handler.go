@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
 
@@ -13,6 +14,21 @@ import (
 
 var Token *string
 
+var (
+	secrets   []string
+	secretsMu sync.RWMutex
+)
+
+// RedactSecret hides secret, such as an API key, from the error messages shown to users.
+func RedactSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	secretsMu.Lock()
+	secrets = append(secrets, secret)
+	secretsMu.Unlock()
+}
+
 func CheckAPIAlive(apiHost string) bool {
 	resp, err := http.Get(apiHost)
 	if err != nil || resp.StatusCode != http.StatusOK {
@@ -29,6 +45,16 @@ func ErrorFollowup(bot *discordgo.Session, i *discordgo.Interaction, errorConten
 
 	logError(toPrint, i)
 
+	if isMessageInteraction(i) {
+		_, err := bot.ChannelMessageSendComplex(i.ChannelID, &discordgo.MessageSend{
+			Content:    *sanitizeToken(&toPrint),
+			Components: []discordgo.MessageComponent{Components[DeleteButton]},
+			Embeds:     embed,
+			Reference:  i.Message.Reference(),
+		})
+		return Wrap(err)
+	}
+
 	_, err := bot.FollowupMessageCreate(i, true, &discordgo.WebhookParams{
 		Content:    *sanitizeToken(&toPrint),
 		Components: []discordgo.MessageComponent{Components[DeleteButton]},
@@ -43,12 +69,12 @@ func ErrorEdit(bot *discordgo.Session, i *discordgo.Interaction, errorContent ..
 
 	logError(toPrint, i)
 
-	_, err := bot.InteractionResponseEdit(i, &discordgo.WebhookEdit{
+	_, err := EditInteractionResponse(bot, i, &discordgo.WebhookEdit{
 		Content:    sanitizeToken(&toPrint),
 		Components: &[]discordgo.MessageComponent{Components[DeleteButton]},
 		Embeds:     &embed,
 	})
-	return Wrap(err)
+	return err
 }
 
 // ErrorEphemeral [ErrorEphemeral] responds to the interaction with an ephemeral error message.
@@ -71,6 +97,11 @@ func ErrorEphemeral(bot *discordgo.Session, i *discordgo.Interaction, errorConte
 }
 
 func ErrorFollowupEphemeral(bot *discordgo.Session, i *discordgo.Interaction, errorContent ...any) error {
+	if isMessageInteraction(i) {
+		// channel messages can't be ephemeral
+		return ErrorFollowup(bot, i, errorContent...)
+	}
+
 	embed, toPrint := errorEmbed(i, errorContent...)
 
 	logError(toPrint, i)
@@ -111,7 +142,17 @@ func formatError(errorContent ...any) string {
 		errorString = "Multiple errors have occurred:\n" + errorString
 	}
 
-	return errorString
+	return Redact(errorString)
+}
+
+// Redact replaces the secrets registered with RedactSecret in text.
+func Redact(text string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "[...]")
+	}
+	return text
 }
 
 func errorEmbed(i *discordgo.Interaction, errorContent ...any) ([]*discordgo.MessageEmbed, string) {
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/utils"
+)
+
+// MessageInteraction sends content to the channel and returns an interaction backed by that message,
+// so that queues can respond to work that wasn't started by a command, such as images requested by an LLM.
+// The interaction has no token, so the responses to it edit the message instead.
+// The interaction shares its ID with the message, and is credited to the member or user of from,
+// who is the only user the message can mention so that MessageInteractionMetadata finds them again.
+func MessageInteraction(bot *discordgo.Session, channelID string, from *discordgo.Interaction, command string, content string) (*discordgo.Interaction, error) {
+	message, err := bot.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         content,
+		AllowedMentions: ownerMentions(from),
+	})
+	if err != nil {
+		return nil, Wrap(err)
+	}
+
+	return &discordgo.Interaction{
+		ID:        message.ID,
+		AppID:     from.AppID,
+		Type:      discordgo.InteractionApplicationCommand,
+		Data:      discordgo.ApplicationCommandInteractionData{Name: command},
+		GuildID:   from.GuildID,
+		ChannelID: channelID,
+		Message:   message,
+		Member:    from.Member,
+		User:      from.User,
+		Locale:    from.Locale,
+	}, nil
+}
+
+// isMessageInteraction reports whether i was created by MessageInteraction.
+func isMessageInteraction(i *discordgo.Interaction) bool {
+	return i != nil && i.Token == "" && i.Message != nil
+}
+
+// ownerMentions only allows the user of i to be mentioned.
+func ownerMentions(i *discordgo.Interaction) *discordgo.MessageAllowedMentions {
+	mentions := &discordgo.MessageAllowedMentions{}
+	if user := utils.GetUser(i); user != nil {
+		mentions.Users = []string{user.ID}
+	}
+	return mentions
+}
+
+// editMessageInteraction applies the edit to the message backing i.
+// Unless the edit sets its own, only the user of i can be mentioned, like when the message was sent.
+func editMessageInteraction(bot *discordgo.Session, i *discordgo.Interaction, webhookEdit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	allowedMentions := webhookEdit.AllowedMentions
+	if allowedMentions == nil {
+		allowedMentions = ownerMentions(i)
+	}
+
+	msg, err := bot.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Content:         webhookEdit.Content,
+		Components:      webhookEdit.Components,
+		Embeds:          webhookEdit.Embeds,
+		AllowedMentions: allowedMentions,
+		Files:           webhookEdit.Files,
+		Attachments:     webhookEdit.Attachments,
+		ID:              i.Message.ID,
+		Channel:         i.ChannelID,
+	})
+	if err != nil {
+		return nil, Wrap(err)
+	}
+
+	return msg, nil
+}
+
+// InteractionResponse returns the message responding to i, which is the message itself for a MessageInteraction.
+func InteractionResponse(bot *discordgo.Session, i *discordgo.Interaction) (*discordgo.Message, error) {
+	if isMessageInteraction(i) {
+		return i.Message, nil
+	}
+
+	msg, err := bot.InteractionResponse(i)
+	if err != nil {
+		return nil, Wrap(err)
+	}
+
+	return msg, nil
+}
+
+// MessageInteractionMetadata returns the interaction that message responds to.
+// Messages sent for a MessageInteraction have no metadata, so it's rebuilt from the message ID and its only mention,
+// the user it was credited to, which matches the ID of the interaction the queues keep track of.
+func MessageInteractionMetadata(message *discordgo.Message) *discordgo.MessageInteractionMetadata {
+	if message == nil {
+		return nil
+	}
+	if message.InteractionMetadata != nil {
+		return message.InteractionMetadata
+	}
+	if len(message.Mentions) == 0 {
+		return nil
+	}
+
+	return &discordgo.MessageInteractionMetadata{
+		ID:   message.ID,
+		Type: discordgo.InteractionApplicationCommand,
+		User: message.Mentions[0],
+	}
+}
@@ -113,6 +113,10 @@ func EditInteractionResponse(bot *discordgo.Session, i *discordgo.Interaction, c
 	webhookEdit := webhookFromContents(content...)
 	contentEdit(webhookEdit, content...)
 
+	if isMessageInteraction(i) {
+		return editMessageInteraction(bot, i, webhookEdit)
+	}
+
 	msg, err := bot.InteractionResponseEdit(i, webhookEdit)
 	if err != nil {
 		return nil, Wrap(err)
@@ -0,0 +1,317 @@
+package discord_bot
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+const ServerSettingsCommand = "server_settings"
+
+const (
+	allowedCommandsOption     = "allowed_commands"
+	defaultCheckpointOption   = "checkpoint"
+	maxWidthOption            = "max_width"
+	maxHeightOption           = "max_height"
+	nsfwOption                = "nsfw"
+	queueLimitOption          = "queue_limit"
+	announcementChannelOption = "announcement_channel"
+	resetOption               = "reset"
+
+	// clearValue clears the text settings, which fall back to the defaults again.
+	clearValue = "default"
+)
+
+func serverSettingsCommand() *discordgo.ApplicationCommand {
+	minimum := 0.0
+	return &discordgo.ApplicationCommand{
+		Name:        ServerSettingsCommand,
+		Description: "Change the settings of the bot for this server (admins only)",
+		Type:        discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        allowedCommandsOption,
+				Description: "The only commands that can be used, separated by commas, or 'default' for all of them",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        defaultCheckpointOption,
+				Description: "The checkpoint used when none is chosen, or 'default' for the bot's",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        maxWidthOption,
+				Description: "The largest width of the images, 0 for no limit",
+				MinValue:    &minimum,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        maxHeightOption,
+				Description: "The largest height of the images, 0 for no limit",
+				MinValue:    &minimum,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        nsfwOption,
+				Description: "Whether NSFW images can be generated",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Allow", Value: entities.NSFWAllow},
+					{Name: "Only in age-restricted channels", Value: entities.NSFWChannels},
+					{Name: "Block", Value: entities.NSFWBlock},
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        queueLimitOption,
+				Description: "The number of items each member can have waiting in a queue, 0 for no limit",
+				MinValue:    &minimum,
+			},
+			{
+				Type:         discordgo.ApplicationCommandOptionChannel,
+				Name:         announcementChannelOption,
+				Description:  "The channel where changes to the settings are announced",
+				ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        resetOption,
+				Description: "Reset all the settings of this server to the bot defaults",
+			},
+		},
+	}
+}
+
+// guildSettings returns the settings of the server, or nil if it has none.
+func (b *botImpl) guildSettings(guildID string) (*entities.GuildSettings, error) {
+	if b.config.GuildSettingsRepo == nil || guildID == "" {
+		return nil, nil
+	}
+
+	settings, err := b.config.GuildSettingsRepo.GetByGuildID(context.Background(), guildID)
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// allowed reports whether the command of the interaction can be used in its server, and returns the command.
+// Components and modals are checked against the command they belong to, see queue.CommandOwner,
+// while the ones that belong to no command, such as the delete button, are always allowed.
+// /server_settings is always allowed, so that admins can't lock themselves out.
+func (b *botImpl) allowed(i *discordgo.InteractionCreate) (string, bool) {
+	var name string
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		name = i.ApplicationCommandData().Name
+	case discordgo.InteractionMessageComponent:
+		name = b.commandOf(i.MessageComponentData().CustomID)
+	case discordgo.InteractionModalSubmit:
+		name = b.commandOf(i.ModalSubmitData().CustomID)
+	}
+	if name == "" || name == ServerSettingsCommand {
+		return name, true
+	}
+
+	settings, err := b.guildSettings(i.GuildID)
+	if err != nil {
+		log.Printf("Error retrieving the settings of guild %s: %v", i.GuildID, err)
+		return name, true
+	}
+
+	return name, settings.Allows(name)
+}
+
+// commandOf returns the command the component or modal belongs to, or an empty string if it belongs to none.
+func (b *botImpl) commandOf(customID string) string {
+	customID, _ = handlers.Arguments(customID)
+	for _, q := range b.queues {
+		owner, ok := q.(queue.CommandOwner)
+		if !ok {
+			continue
+		}
+		if command, ok := owner.CommandOf(customID); ok {
+			return command
+		}
+	}
+	return ""
+}
+
+// processServerSettingsCommand stores the given options as the settings of the server, then shows its current settings.
+func (b *botImpl) processServerSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	if i.GuildID == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "Server settings can only be changed in a server.")
+	}
+	if !handlers.CanManageGuild(i.Interaction) {
+		return handlers.ErrorEdit(s, i.Interaction, "Only admins can change the settings of the server.")
+	}
+
+	ctx := context.Background()
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	settings, err := b.guildSettings(i.GuildID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving the server settings.", err)
+	}
+	if settings == nil {
+		settings = &entities.GuildSettings{GuildID: i.GuildID}
+	}
+	// changes are announced in the channel from before them, in case it's the channel that changes
+	announcementChannel := settings.AnnouncementChannelID
+
+	if option, ok := optionMap[resetOption]; ok && option.BoolValue() {
+		if err := b.config.GuildSettingsRepo.Delete(ctx, i.GuildID); err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error resetting the server settings.", err)
+		}
+
+		content := "The server settings have been reset to the bot defaults."
+		announce(s, i, announcementChannel, content)
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, content)
+		return err
+	}
+
+	if len(optionMap) == 0 {
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+			Embeds: &[]*discordgo.MessageEmbed{serverSettingsEmbed(settings)},
+		})
+		return err
+	}
+
+	if option, ok := optionMap[allowedCommandsOption]; ok {
+		commands, err := b.parseCommands(option.StringValue())
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, err)
+		}
+		settings.AllowedCommands = commands
+	}
+	if option, ok := optionMap[defaultCheckpointOption]; ok {
+		settings.DefaultCheckpoint = strings.TrimSpace(option.StringValue())
+		if strings.EqualFold(settings.DefaultCheckpoint, clearValue) {
+			settings.DefaultCheckpoint = ""
+		}
+	}
+	if option, ok := optionMap[maxWidthOption]; ok {
+		settings.MaxWidth = int(option.IntValue())
+	}
+	if option, ok := optionMap[maxHeightOption]; ok {
+		settings.MaxHeight = int(option.IntValue())
+	}
+	if option, ok := optionMap[nsfwOption]; ok {
+		settings.NSFWPolicy = entities.NSFWPolicy(option.StringValue())
+	}
+	if option, ok := optionMap[queueLimitOption]; ok {
+		settings.QueueLimit = int(option.IntValue())
+	}
+	if option, ok := optionMap[announcementChannelOption]; ok {
+		settings.AnnouncementChannelID = option.ChannelValue(nil).ID
+	}
+
+	settings, err = b.config.GuildSettingsRepo.Upsert(ctx, settings)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error saving the server settings.", err)
+	}
+
+	announce(s, i, cmp.Or(announcementChannel, settings.AnnouncementChannelID), "The server settings have been updated.", serverSettingsEmbed(settings))
+
+	content := "The server settings have been updated."
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Embeds:  &[]*discordgo.MessageEmbed{serverSettingsEmbed(settings)},
+	})
+	return err
+}
+
+// parseCommands reads a comma separated list of the commands registered by the bot.
+func (b *botImpl) parseCommands(list string) ([]string, error) {
+	if strings.EqualFold(strings.TrimSpace(list), clearValue) {
+		return nil, nil
+	}
+
+	var commands []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+		if name == "" {
+			continue
+		}
+		if _, ok := b.registeredCommands[name]; !ok && name != ServerSettingsCommand {
+			return nil, fmt.Errorf("unknown command `/%s`", name)
+		}
+		if !slices.Contains(commands, name) {
+			commands = append(commands, name)
+		}
+	}
+
+	return commands, nil
+}
+
+// announce posts the change of the settings in the announcement channel of the server, if it has one.
+func announce(s *discordgo.Session, i *discordgo.InteractionCreate, channelID string, content string, embeds ...*discordgo.MessageEmbed) {
+	if channelID == "" {
+		return
+	}
+
+	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Content:         fmt.Sprintf("%s Changed by <@%s>.", content, utils.GetUser(i.Interaction).ID),
+		Embeds:          embeds,
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error announcing the server settings in channel %s: %v", channelID, err)
+	}
+}
+
+func serverSettingsEmbed(settings *entities.GuildSettings) *discordgo.MessageEmbed {
+	orDefault := func(value string) string {
+		if value == "" {
+			return "Bot default"
+		}
+		return value
+	}
+	limit := func(value int) string {
+		if value == 0 {
+			return "No limit"
+		}
+		return fmt.Sprintf("%d", value)
+	}
+
+	commands := "All"
+	if len(settings.AllowedCommands) > 0 {
+		commands = "/" + strings.Join(settings.AllowedCommands, ", /")
+	}
+	channel := "None"
+	if settings.AnnouncementChannelID != "" {
+		channel = fmt.Sprintf("<#%s>", settings.AnnouncementChannelID)
+	}
+
+	return &discordgo.MessageEmbed{
+		Title: "Server settings",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Allowed commands", Value: commands},
+			{Name: "Default checkpoint", Value: orDefault(settings.DefaultCheckpoint), Inline: true},
+			{Name: "Max size", Value: fmt.Sprintf("%s x %s", limit(settings.MaxWidth), limit(settings.MaxHeight)), Inline: true},
+			{Name: "NSFW", Value: orDefault(string(settings.NSFWPolicy)), Inline: true},
+			{Name: "Queue limit", Value: limit(settings.QueueLimit), Inline: true},
+			{Name: "Announcement channel", Value: channel, Inline: true},
+		},
+	}
+}
@@ -1,9 +1,29 @@
 package entities
 
+// DefaultSettings are the defaults of /imagine, either the bot defaults or a member's own profile.
+// Empty fields of a member's profile fall back to the bot defaults, and those of the bot defaults to the built-in ones.
 type DefaultSettings struct {
 	MemberID   string `json:"member_id"`
 	Width      int    `json:"width"`
 	Height     int    `json:"height"`
 	BatchCount int    `json:"batch_count"`
 	BatchSize  int    `json:"batch_size"`
+
+	SamplerName    string  `json:"sampler_name,omitempty"`
+	Steps          int     `json:"steps,omitempty"`
+	CFGScale       float64 `json:"cfg_scale,omitempty"`
+	NegativePrompt string  `json:"negative_prompt,omitempty"`
+	Checkpoint     string  `json:"checkpoint,omitempty"`
+	VAE            string  `json:"vae,omitempty"`
+	CLIPSkip       int     `json:"clip_skip,omitempty"`
+
+	// EnableHr turns the hires fix on or off, while nil leaves it to the defaults.
+	EnableHr          *bool   `json:"enable_hr,omitempty"`
+	HrScale           float64 `json:"hr_scale,omitempty"`
+	HrUpscaler        string  `json:"hr_upscaler,omitempty"`
+	HrSteps           int64   `json:"hr_steps,omitempty"`
+	DenoisingStrength float64 `json:"denoising_strength,omitempty"`
+
+	// ADetailer are the ADetailer models separated by commas, as in the ad_model option of /imagine.
+	ADetailer string `json:"adetailer,omitempty"`
 }
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// Favorite is an image of a generation starred by a member, referenced by its message and sort order.
+// AttachmentURL is the image itself, or the tile of the whole batch for batches of more than four images.
+type Favorite struct {
+	ID            int64     `json:"id"`
+	MemberID      string    `json:"member_id"`
+	GuildID       string    `json:"guild_id"`
+	ChannelID     string    `json:"channel_id"`
+	MessageID     string    `json:"message_id"`
+	SortOrder     int       `json:"sort_order"`
+	AttachmentURL string    `json:"attachment_url"`
+	CreatedAt     time.Time `json:"created_at"`
+}
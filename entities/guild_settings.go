@@ -0,0 +1,43 @@
+package entities
+
+import "slices"
+
+// NSFWPolicy is whether a server allows NSFW images to be generated.
+type NSFWPolicy string
+
+const (
+	NSFWAllow    NSFWPolicy = "allow"
+	NSFWChannels NSFWPolicy = "nsfw_channels" // only in the age-restricted channels of the server
+	NSFWBlock    NSFWPolicy = "block"
+)
+
+// GuildSettings are a server's settings, set by its admins with /server_settings.
+// Empty fields fall back to the member's settings or the bot defaults.
+type GuildSettings struct {
+	GuildID string `json:"guild_id"`
+	// AllowedCommands are the only commands that can be used in the server, or all of them when it's empty.
+	AllowedCommands   []string   `json:"allowed_commands,omitempty"`
+	DefaultCheckpoint string     `json:"default_checkpoint,omitempty"`
+	MaxWidth          int        `json:"max_width,omitempty"`
+	MaxHeight         int        `json:"max_height,omitempty"`
+	NSFWPolicy        NSFWPolicy `json:"nsfw_policy,omitempty"`
+	// QueueLimit is the number of items each member can have waiting in a queue.
+	QueueLimit            int    `json:"queue_limit,omitempty"`
+	AnnouncementChannelID string `json:"announcement_channel_id,omitempty"`
+}
+
+// Allows reports whether the command can be used in the server.
+func (s *GuildSettings) Allows(command string) bool {
+	if s == nil || len(s.AllowedCommands) == 0 {
+		return true
+	}
+	return slices.Contains(s.AllowedCommands, command)
+}
+
+// FitsSize reports whether an image of this size can be generated in the server.
+func (s *GuildSettings) FitsSize(width, height int) bool {
+	if s == nil {
+		return true
+	}
+	return (s.MaxWidth == 0 || width <= s.MaxWidth) && (s.MaxHeight == 0 || height <= s.MaxHeight)
+}
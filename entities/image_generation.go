@@ -57,6 +57,16 @@ type GenerationInfo struct {
 	VAE           *string   `json:"vae,omitempty"`
 	Hypernetwork  *string   `json:"hypernetwork,omitempty"`
 	CreatedAt     time.Time `json:"created_at"`
+
+	// OriginalPrompt is the prompt as written by the user when Prompt was enhanced by an LLM.
+	OriginalPrompt string `json:"original_prompt,omitempty"`
+	// ParentID is the generation this one was edited from with the Edit button.
+	ParentID int64 `json:"parent_id,omitempty"`
+	// GuildID and ChannelID are where the message of the generation was posted, to link to it.
+	GuildID   string `json:"guild_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+	// Style is the name of the style applied to the prompt.
+	Style string `json:"style,omitempty"`
 }
 
 func NewGeneration() *ImageGeneration {
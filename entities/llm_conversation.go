@@ -0,0 +1,23 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+)
+
+// LLMConversation is a multi-turn conversation held in a Discord thread.
+// Messages starts with the system prompt, followed by the user and assistant turns in order.
+type LLMConversation struct {
+	ID        int64  `json:"id"`
+	ThreadID  string `json:"thread_id"`
+	MemberID  string `json:"member_id"`
+	Endpoint  string `json:"endpoint"`
+	Model     string `json:"model"`
+	MaxTokens int64  `json:"max_tokens"`
+	// Temperature is nil for the conversations stored before it was kept, which use the default temperature.
+	Temperature *float64      `json:"temperature,omitempty"`
+	Tools       bool          `json:"tools"`
+	Messages    []llm.Message `json:"messages"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
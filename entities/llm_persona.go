@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+// LLMPersona is a named system prompt with its own generation settings, shared by the members of a guild.
+// An empty Model falls back to the member's default model.
+type LLMPersona struct {
+	ID           int64     `json:"id"`
+	GuildID      string    `json:"guild_id"`
+	Name         string    `json:"name"`
+	SystemPrompt string    `json:"system_prompt"`
+	Temperature  float64   `json:"temperature"`
+	MaxTokens    int64     `json:"max_tokens"`
+	Model        string    `json:"model,omitempty"`
+	IsDefault    bool      `json:"is_default"`
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,8 @@
+package entities
+
+// LLMSettings are a member's defaults for the /llm command. Empty fields fall back to the bot defaults.
+type LLMSettings struct {
+	MemberID string `json:"member_id"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
@@ -121,6 +121,14 @@ type Center struct {
 	Y int64 `json:"y"`
 }
 
+// NovelAIUpscaleRequest is sent to the NovelAI upscaler. Width and Height are the dimensions of Image.
+type NovelAIUpscaleRequest struct {
+	Image  *async `json:"image"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Scale  int    `json:"scale"`
+}
+
 type NovelAIResponse struct {
 	Images []io.Reader `json:"images"`
 }
@@ -290,9 +298,23 @@ func (r *NovelAIRequest) Init() {
 		r.Parameters.AutoSmea = r.Parameters.Smea
 	}
 
+	// Always send vibe transfer references as the *_multiple fields, as this is what V4 expects.
 	if r.Parameters.VibeTransferImage != nil {
-		r.Parameters.ReferenceStrength = cmp.Or(r.Parameters.ReferenceStrength, 0.6)
-		r.Parameters.ReferenceInformationExtracted = cmp.Or(r.Parameters.ReferenceInformationExtracted, 1.0)
+		r.Parameters.ReferenceImageMultiple = append([]*async{r.Parameters.VibeTransferImage}, r.Parameters.ReferenceImageMultiple...)
+		r.Parameters.ReferenceInformationExtractedMultiple = append([]float64{cmp.Or(r.Parameters.ReferenceInformationExtracted, 1.0)}, r.Parameters.ReferenceInformationExtractedMultiple...)
+		r.Parameters.ReferenceStrengthMultiple = append([]float64{cmp.Or(r.Parameters.ReferenceStrength, 0.6)}, r.Parameters.ReferenceStrengthMultiple...)
+		r.Parameters.VibeTransferImage = nil
+	}
+
+	if len(r.Parameters.ReferenceImageMultiple) > 0 {
+		r.Parameters.ReferenceInformationExtracted = 0
+		r.Parameters.ReferenceStrength = 0
+		for len(r.Parameters.ReferenceInformationExtractedMultiple) < len(r.Parameters.ReferenceImageMultiple) {
+			r.Parameters.ReferenceInformationExtractedMultiple = append(r.Parameters.ReferenceInformationExtractedMultiple, 1.0)
+		}
+		for len(r.Parameters.ReferenceStrengthMultiple) < len(r.Parameters.ReferenceImageMultiple) {
+			r.Parameters.ReferenceStrengthMultiple = append(r.Parameters.ReferenceStrengthMultiple, 0.6)
+		}
 	}
 
 	if r.Parameters.Img2Img != nil {
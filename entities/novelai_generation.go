@@ -0,0 +1,20 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/ellypaws/novelai-metadata/pkg/meta"
+)
+
+// NovelAIGeneration is a single image of a NovelAI generation.
+// Request is the request as submitted by the user, without any image payloads, and with the seed used for this image.
+type NovelAIGeneration struct {
+	ID            int64           `json:"id"`
+	InteractionID string          `json:"interaction_id"`
+	MessageID     string          `json:"message_id"`
+	MemberID      string          `json:"member_id"`
+	SortOrder     int             `json:"sort_order"`
+	Request       *NovelAIRequest `json:"request"`
+	Metadata      *meta.Metadata  `json:"metadata,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
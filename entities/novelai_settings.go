@@ -0,0 +1,48 @@
+package entities
+
+// NovelAISettings are a member's defaults for the /novelai command. Empty or nil fields fall back to DefaultNovelAIRequest.
+type NovelAISettings struct {
+	MemberID      string   `json:"member_id"`
+	Model         string   `json:"model,omitempty"`
+	Size          string   `json:"size,omitempty"` // one of the Option* resolution presets
+	Sampler       string   `json:"sampler,omitempty"`
+	Schedule      string   `json:"schedule,omitempty"`
+	UcPreset      *int64   `json:"uc_preset,omitempty"`
+	QualityToggle *bool    `json:"quality_toggle,omitempty"`
+	Scale         *float64 `json:"scale,omitempty"`
+	Steps         *int64   `json:"steps,omitempty"`
+}
+
+// Apply overwrites the request's parameters with the settings that have been set.
+func (s *NovelAISettings) Apply(request *NovelAIRequest) {
+	if s == nil || request == nil {
+		return
+	}
+
+	if s.Model != "" {
+		request.Model = s.Model
+	}
+	if s.Size != "" {
+		preset := GetDimensions(s.Size)
+		request.Parameters.ResolutionPreset = &preset
+	}
+	if s.Sampler != "" {
+		request.Parameters.Sampler = s.Sampler
+	}
+	if s.Schedule != "" {
+		request.Parameters.NoiseSchedule = s.Schedule
+	}
+	if s.UcPreset != nil {
+		uc := *s.UcPreset
+		request.Parameters.UcPreset = &uc
+	}
+	if s.QualityToggle != nil {
+		request.Parameters.QualityToggle = *s.QualityToggle
+	}
+	if s.Scale != nil {
+		request.Parameters.Scale = *s.Scale
+	}
+	if s.Steps != nil {
+		request.Parameters.Steps = *s.Steps
+	}
+}
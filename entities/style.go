@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"strings"
+	"time"
+)
+
+// StylePlaceholder is replaced by the prompt of the member in the templates of a Style.
+const StylePlaceholder = "{prompt}"
+
+// Style is a named preset of /imagine, either personal to its creator or shared in a guild.
+// The empty settings are left to the defaults and the options of the command.
+type Style struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	MemberID string `json:"member_id"`
+	// GuildID is the guild the style is shared in, or empty for a personal style.
+	GuildID string `json:"guild_id,omitempty"`
+
+	Prompt         string `json:"prompt"`
+	NegativePrompt string `json:"negative_prompt,omitempty"`
+
+	SamplerName string  `json:"sampler_name,omitempty"`
+	Steps       int     `json:"steps,omitempty"`
+	CFGScale    float64 `json:"cfg_scale,omitempty"`
+	Width       int     `json:"width,omitempty"`
+	Height      int     `json:"height,omitempty"`
+	// Loras are the LoRAs added to the prompt separated by commas, each as name:strength.
+	Loras string `json:"loras,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Shared reports whether the style is shared in a guild rather than personal.
+func (s *Style) Shared() bool {
+	return s.GuildID != ""
+}
+
+// Apply puts the prompt in the positive template of the style.
+func (s *Style) Apply(prompt string) string {
+	return applyTemplate(s.Prompt, prompt)
+}
+
+// ApplyNegative puts the negative prompt in the negative template of the style.
+func (s *Style) ApplyNegative(negative string) string {
+	return applyTemplate(s.NegativePrompt, negative)
+}
+
+// applyTemplate replaces the placeholder of the template with the prompt,
+// or adds the template after the prompt when it has no placeholder, the same way as the styles of the web UI.
+func applyTemplate(template, prompt string) string {
+	switch {
+	case strings.TrimSpace(template) == "":
+		return prompt
+	case strings.Contains(template, StylePlaceholder):
+		return strings.ReplaceAll(template, StylePlaceholder, prompt)
+	case strings.TrimSpace(prompt) == "":
+		return template
+	default:
+		return prompt + ", " + template
+	}
+}
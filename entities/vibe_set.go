@@ -0,0 +1,18 @@
+package entities
+
+import "time"
+
+// VibeSet is a named group of vibe transfer references that a member can reuse on later NovelAI generations.
+type VibeSet struct {
+	ID         int64           `json:"id"`
+	MemberID   string          `json:"member_id"`
+	Name       string          `json:"name"`
+	References []VibeReference `json:"references"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type VibeReference struct {
+	Image                []byte  `json:"image"`
+	InformationExtracted float64 `json:"information_extracted"`
+	ReferenceStrength    float64 `json:"reference_strength"`
+}
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// Wildcard is a list of values uploaded to a guild, one of which replaces __name__ in a prompt.
+type Wildcard struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	GuildID string   `json:"guild_id"`
+	Values  []string `json:"values"`
+	// MemberID is the member who last uploaded the values.
+	MemberID string `json:"member_id"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"flag"
 	"log"
-	"net/url"
 	"os"
 	"strings"
 
@@ -12,13 +11,23 @@ import (
 	"stable_diffusion_bot/databases/sqlite"
 	"stable_diffusion_bot/discord_bot"
 	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/queue/llm"
 	"stable_diffusion_bot/queue/novelai"
 	"stable_diffusion_bot/queue/stable_diffusion"
 	"stable_diffusion_bot/repositories/default_settings"
+	"stable_diffusion_bot/repositories/favorites"
+	"stable_diffusion_bot/repositories/guild_settings"
 	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/repositories/llm_conversations"
+	"stable_diffusion_bot/repositories/llm_personas"
+	"stable_diffusion_bot/repositories/llm_settings"
+	"stable_diffusion_bot/repositories/novelai_generations"
+	"stable_diffusion_bot/repositories/novelai_settings"
+	"stable_diffusion_bot/repositories/styles"
+	"stable_diffusion_bot/repositories/vibe_sets"
+	"stable_diffusion_bot/repositories/wildcards"
 
-	openai "github.com/ellypaws/inkbunny-sd/llm"
 	"github.com/joho/godotenv"
 )
 
@@ -30,8 +39,15 @@ var (
 	imagineCommand     = flag.String("imagine", "imagine", "Imagine command name. Default is \"imagine\"")
 	removeCommandsFlag = flag.Bool("remove", false, "Delete all commands when bot exits")
 
-	llmHost      = flag.String("llm", "", "LLM model to use")
-	novelAIToken = flag.String("novelai", "", "NovelAI API token")
+	llmHost   = flag.String("llm", "", "LLM model to use")
+	llmModel  = flag.String("llm_model", "", "Default LLM model. Defaults to the first model in -llm_models")
+	llmModels = flag.String("llm_models", "", "Comma separated list of LLM models members can pick from. Any model is allowed if empty")
+	llmAPIKey = flag.String("llm_key", "", "API key for the LLM host")
+
+	llmEndpointsFile = flag.String("llm_endpoints", "", "JSON file with named LLM endpoints members can pick from")
+	llmCaptionModel  = flag.String("llm_caption_model", "", "Vision model of the LLM host that writes the alt text of generated images. Alt text is disabled if empty")
+	llmThreads       = flag.Bool("llm_threads", false, "Let /llm open threads whose replies continue the conversation. Needs the message content intent enabled for the bot")
+	novelAIToken     = flag.String("novelai", "", "NovelAI API token")
 )
 
 func init() {
@@ -84,6 +100,49 @@ func init() {
 		}
 	}
 
+	if llmModel == nil || *llmModel == "" {
+		llmModelEnv := os.Getenv("LLM_MODEL")
+		if llmModelEnv != "" {
+			llmModel = &llmModelEnv
+		}
+	}
+
+	if llmModels == nil || *llmModels == "" {
+		llmModelsEnv := os.Getenv("LLM_MODELS")
+		if llmModelsEnv != "" {
+			llmModels = &llmModelsEnv
+		}
+	}
+
+	if llmAPIKey == nil || *llmAPIKey == "" {
+		llmAPIKeyEnv := os.Getenv("LLM_API_KEY")
+		if llmAPIKeyEnv != "" {
+			llmAPIKey = &llmAPIKeyEnv
+		}
+	}
+
+	if llmEndpointsFile == nil || *llmEndpointsFile == "" {
+		llmEndpointsEnv := os.Getenv("LLM_ENDPOINTS")
+		if llmEndpointsEnv != "" {
+			llmEndpointsFile = &llmEndpointsEnv
+		}
+	}
+
+	if llmCaptionModel == nil || *llmCaptionModel == "" {
+		llmCaptionModelEnv := os.Getenv("LLM_CAPTION_MODEL")
+		if llmCaptionModelEnv != "" {
+			llmCaptionModel = &llmCaptionModelEnv
+		}
+	}
+
+	if llmThreads == nil || !*llmThreads {
+		llmThreadsEnv := os.Getenv("LLM_THREADS")
+		if llmThreadsEnv != "" {
+			llmThreads = new(bool)
+			*llmThreads = llmThreadsEnv == "true"
+		}
+	}
+
 	if novelAIToken == nil || *novelAIToken == "" {
 		novelAITokenEnv := os.Getenv("NOVELAI_TOKEN")
 		if novelAITokenEnv != "" {
@@ -159,38 +218,161 @@ func main() {
 		log.Fatalf("Failed to create default settings repository: %v", err)
 	}
 
+	favoriteRepo, err := favorites.NewRepository(&favorites.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create favorite repository: %v", err)
+	}
+
+	guildSettingsRepo, err := guild_settings.NewRepository(&guild_settings.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create guild settings repository: %v", err)
+	}
+
+	vibeSetRepo, err := vibe_sets.NewRepository(&vibe_sets.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create vibe set repository: %v", err)
+	}
+
+	novelAIGenerationRepo, err := novelai_generations.NewRepository(&novelai_generations.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create NovelAI generation repository: %v", err)
+	}
+
+	novelAISettingsRepo, err := novelai_settings.NewRepository(&novelai_settings.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create NovelAI settings repository: %v", err)
+	}
+
+	llmConversationRepo, err := llm_conversations.NewRepository(&llm_conversations.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create LLM conversation repository: %v", err)
+	}
+
+	llmSettingsRepo, err := llm_settings.NewRepository(&llm_settings.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create LLM settings repository: %v", err)
+	}
+
+	styleRepo, err := styles.NewRepository(&styles.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create style repository: %v", err)
+	}
+
+	wildcardRepo, err := wildcards.NewRepository(&wildcards.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create wildcard repository: %v", err)
+	}
+
+	llmPersonaRepo, err := llm_personas.NewRepository(&llm_personas.Config{DB: sqliteDB})
+	if err != nil {
+		log.Fatalf("Failed to create LLM persona repository: %v", err)
+	}
+
+	var allowedModels []string
+	for _, model := range strings.Split(*llmModels, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			allowedModels = append(allowedModels, model)
+		}
+	}
+
+	var llmEndpoints []*llm.Endpoint
+	if llmHost != nil && *llmHost != "" {
+		llmEndpoints = append(llmEndpoints, &llm.Endpoint{
+			Name:         "default",
+			URL:          *llmHost,
+			APIKey:       *llmAPIKey,
+			DefaultModel: *llmModel,
+			Models:       allowedModels,
+		})
+		log.Printf("LLM host set to %s", *llmHost)
+	}
+	if llmEndpointsFile != nil && *llmEndpointsFile != "" {
+		endpoints, err := llm.LoadEndpoints(*llmEndpointsFile)
+		if err != nil {
+			log.Fatalf("Failed to load LLM endpoints: %v", err)
+		}
+		llmEndpoints = append(llmEndpoints, endpoints...)
+		log.Printf("Loaded %d LLM endpoints from %s", len(endpoints), *llmEndpointsFile)
+	}
+	if len(llmEndpoints) == 0 {
+		log.Printf("LLM host is not set, LLM commands will be disabled")
+	}
+
+	// the settings of each server are applied by the same Guilds in every queue, so that the queue limit counts all of them
+	guilds := queue.NewGuilds(guildSettingsRepo)
+
+	// replies in threads can only be read with the privileged message content intent, so conversations are opt-in
+	var conversationRepo llm_conversations.Repository
+	if *llmThreads {
+		conversationRepo = llmConversationRepo
+	}
+
+	llmQueue, err := llm.New(llm.Config{
+		Endpoints:        llmEndpoints,
+		ConversationRepo: conversationRepo,
+		SettingsRepo:     llmSettingsRepo,
+		PersonaRepo:      llmPersonaRepo,
+		Guilds:           guilds,
+		CaptionModel:     *llmCaptionModel,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create LLM queue: %v", err)
+	}
+
+	// the LLM queue enhances /imagine prompts before handing them to the imagine queue
+	promptEnhancer, _ := llmQueue.(stable_diffusion.PromptEnhancer)
+
+	// and writes the alt text of the finished images when there is a caption model
+	var captioner queue.Captioner
+	if *llmCaptionModel != "" {
+		captioner, _ = llmQueue.(queue.Captioner)
+	}
+
+	novelAIQueue := novelai.New(novelai.Config{
+		Token:                 novelAIToken,
+		VibeSetRepo:           vibeSetRepo,
+		NovelAIGenerationRepo: novelAIGenerationRepo,
+		NovelAISettingsRepo:   novelAISettingsRepo,
+		Guilds:                guilds,
+		Captioner:             captioner,
+	})
+
 	imagineQueue, err := stable_diffusion.New(stable_diffusion.Config{
 		StableDiffusionAPI:  stableDiffusionAPI,
 		ImageGenerationRepo: generationRepo,
 		DefaultSettingsRepo: defaultSettingsRepo,
+		FavoriteRepo:        favoriteRepo,
+		Guilds:              guilds,
+		StyleRepo:           styleRepo,
+		WildcardRepo:        wildcardRepo,
+		PromptEnhancer:      promptEnhancer,
+		Captioner:           captioner,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create imagine queue: %v", err)
 	}
 
-	var llmConfig *openai.Config
-	if llmHost != nil && *llmHost != "" {
-		endpoint, err := url.Parse(*llmHost)
-		if err != nil {
-			log.Fatalf("Failed to parse LLM host: %v", err)
+	// the LLM queue can use the image queues as tools when a member enables them
+	if llmTools, ok := llmQueue.(*llm.LLMQueue); ok {
+		var tools llm.Tools
+		if generator, ok := imagineQueue.(llm.ImageGenerator); ok {
+			tools.Backends = append(tools.Backends, llm.ImageBackend{Name: "stable_diffusion", Generator: generator})
 		}
-		llmConfig = &openai.Config{
-			Host:     *llmHost,
-			APIKey:   "", // TODO: Add API key
-			Endpoint: *endpoint,
+		if generator, ok := novelAIQueue.(llm.ImageGenerator); ok {
+			tools.Backends = append(tools.Backends, llm.ImageBackend{Name: "novelai", Generator: generator})
 		}
-		log.Printf("LLM host set to %s", llmConfig.Endpoint.String())
-	} else {
-		log.Printf("LLM host is not set, LLM commands will be disabled")
+		tools.Models, _ = imagineQueue.(llm.ModelLister)
+		llmTools.SetTools(tools)
 	}
 
 	bot, err := discord_bot.New(&discord_bot.Config{
-		BotToken:       *botToken,
-		GuildID:        *guildID,
-		ImagineQueue:   imagineQueue,
-		NovelAIQueue:   novelai.New(novelAIToken),
-		LLMQueue:       llm.New(llmConfig),
-		RemoveCommands: removeCommands,
+		BotToken:          *botToken,
+		GuildID:           *guildID,
+		ImagineQueue:      imagineQueue,
+		NovelAIQueue:      novelAIQueue,
+		LLMQueue:          llmQueue,
+		RemoveCommands:    removeCommands,
+		GuildSettingsRepo: guildSettingsRepo,
 	})
 	if err != nil {
 		log.Fatalf("Error creating Discord bot: %v", err)
@@ -0,0 +1,149 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/repositories/guild_settings"
+	"stable_diffusion_bot/utils"
+)
+
+// NSFWNegative is added to the negative prompt where NSFW images aren't allowed.
+const NSFWNegative = "nsfw, nude, explicit"
+
+// Guilds applies the settings of each server to the items of a queue.
+// Direct messages and servers without settings get the member's settings or the bot defaults instead.
+type Guilds struct {
+	repo guild_settings.Repository // optional, no server has settings without it
+
+	mu      sync.Mutex
+	waiting map[string]int // the items each member has in the queue, by server and member
+}
+
+func NewGuilds(repo guild_settings.Repository) *Guilds {
+	return &Guilds{
+		repo:    repo,
+		waiting: make(map[string]int),
+	}
+}
+
+// Settings returns the settings of the server, which are empty if it has none.
+func (g *Guilds) Settings(guildID string) *entities.GuildSettings {
+	settings := &entities.GuildSettings{GuildID: guildID}
+	if guildID == "" || g.repo == nil {
+		return settings
+	}
+
+	stored, err := g.repo.GetByGuildID(context.Background(), guildID)
+	if err != nil {
+		if !errors.Is(err, &repositories.NotFoundError{}) {
+			log.Printf("Error retrieving the settings of guild %s: %v", guildID, err)
+		}
+		return settings
+	}
+
+	return stored
+}
+
+// Wait counts the item of the interaction as waiting in the queue, unless its member already has
+// as many items waiting as the server allows. Every item that was counted has to be let go with Done.
+func (g *Guilds) Wait(i *discordgo.Interaction) error {
+	key, ok := waitingKey(i)
+	if !ok {
+		return nil
+	}
+
+	limit := g.Settings(i.GuildID).QueueLimit
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if limit > 0 && g.waiting[key] >= limit {
+		return fmt.Errorf("you already have %d item(s) in the queue, which is as many as this server allows", g.waiting[key])
+	}
+	g.waiting[key]++
+
+	return nil
+}
+
+// Done stops counting the item of the interaction once it's out of the queue, whether it was processed or cancelled.
+func (g *Guilds) Done(i *discordgo.Interaction) {
+	key, ok := waitingKey(i)
+	if !ok {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.waiting[key] <= 1 {
+		delete(g.waiting, key)
+		return
+	}
+	g.waiting[key]--
+}
+
+// waitingKey returns the key of the items of the member of the interaction in Guilds.waiting,
+// which is only counted in servers.
+func waitingKey(i *discordgo.Interaction) (string, bool) {
+	if i == nil || i.GuildID == "" {
+		return "", false
+	}
+	user := utils.GetUser(i)
+	if user == nil {
+		return "", false
+	}
+	return i.GuildID + "/" + user.ID, true
+}
+
+// AllowsNSFW reports whether NSFW images can be generated in the channel of the interaction.
+func (g *Guilds) AllowsNSFW(s *discordgo.Session, i *discordgo.Interaction) bool {
+	if i == nil || i.GuildID == "" {
+		return true
+	}
+
+	switch g.Settings(i.GuildID).NSFWPolicy {
+	case entities.NSFWBlock:
+		return false
+	case entities.NSFWChannels:
+		return s != nil && isNSFWChannel(s, i.ChannelID)
+	default:
+		return true
+	}
+}
+
+// isNSFWChannel reports whether the channel is age-restricted, which threads are when their channel is.
+func isNSFWChannel(s *discordgo.Session, channelID string) bool {
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		channel, err = s.Channel(channelID)
+		if err != nil {
+			log.Printf("Error retrieving channel %s: %v", channelID, err)
+			return false
+		}
+	}
+
+	if channel.IsThread() && channel.ParentID != "" {
+		return isNSFWChannel(s, channel.ParentID)
+	}
+
+	return channel.NSFW
+}
+
+// AvoidNSFW adds NSFWNegative to the negative prompt, unless it's already there.
+func AvoidNSFW(negative string) string {
+	switch {
+	case strings.Contains(negative, NSFWNegative):
+		return negative
+	case strings.TrimSpace(negative) == "":
+		return NSFWNegative
+	default:
+		return negative + ", " + NSFWNegative
+	}
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+// fakeGuildSettings holds the settings of the servers in memory.
+type fakeGuildSettings map[string]*entities.GuildSettings
+
+func (f fakeGuildSettings) Upsert(_ context.Context, settings *entities.GuildSettings) (*entities.GuildSettings, error) {
+	f[settings.GuildID] = settings
+	return settings, nil
+}
+
+func (f fakeGuildSettings) GetByGuildID(_ context.Context, guildID string) (*entities.GuildSettings, error) {
+	settings, ok := f[guildID]
+	if !ok {
+		return nil, repositories.NewNotFoundError(guildID)
+	}
+	return settings, nil
+}
+
+func (f fakeGuildSettings) Delete(_ context.Context, guildID string) error {
+	delete(f, guildID)
+	return nil
+}
+
+func TestGuildsQueueLimit(t *testing.T) {
+	guilds := NewGuilds(fakeGuildSettings{"limited": {GuildID: "limited", QueueLimit: 2}})
+
+	interaction := func(guildID, userID string) *discordgo.Interaction {
+		return &discordgo.Interaction{GuildID: guildID, Member: &discordgo.Member{User: &discordgo.User{ID: userID}}}
+	}
+	first, second := interaction("limited", "1"), interaction("limited", "2")
+
+	for range 2 {
+		if err := guilds.Wait(first); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+	}
+	if err := guilds.Wait(first); err == nil {
+		t.Errorf("Expected the third item of the member to be refused")
+	}
+	if err := guilds.Wait(second); err != nil {
+		t.Errorf("Expected the items of another member to be counted apart, got %v", err)
+	}
+
+	guilds.Done(first)
+	if err := guilds.Wait(first); err != nil {
+		t.Errorf("Expected an item to fit again once one is done, got %v", err)
+	}
+
+	for range 5 {
+		if err := guilds.Wait(interaction("unlimited", "1")); err != nil {
+			t.Fatalf("Expected no limit in a server without settings, got %v", err)
+		}
+		if err := guilds.Wait(&discordgo.Interaction{User: &discordgo.User{ID: "1"}}); err != nil {
+			t.Fatalf("Expected no limit in direct messages, got %v", err)
+		}
+	}
+}
+
+func TestAvoidNSFW(t *testing.T) {
+	for negative, expected := range map[string]string{
+		"":                        NSFWNegative,
+		"blurry":                  "blurry, " + NSFWNegative,
+		"blurry, " + NSFWNegative: "blurry, " + NSFWNegative,
+	} {
+		if got := AvoidNSFW(negative); got != expected {
+			t.Errorf("Expected %q, got %q", expected, got)
+		}
+	}
+}
@@ -28,6 +28,21 @@ type Registrar interface {
 	Components() Components
 }
 
+// CommandOwner is implemented by queues whose components and modals belong to their commands,
+// so that the commands a server allows apply to them as well.
+type CommandOwner interface {
+	// CommandOf returns the command the component or modal with the custom ID belongs to.
+	CommandOf(customID string) (Command, bool)
+}
+
+// MessageHandler is implemented by queues that respond to regular messages, such as replies in a thread.
+type MessageHandler interface {
+	MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate)
+	// HandlesMessages reports whether the queue is set up to respond to messages, which needs the privileged
+	// message content intent. MessageCreate is only registered when it does.
+	HandlesMessages() bool
+}
+
 type HandlerStartStopper interface {
 	Registrar
 	StartStop
@@ -41,3 +56,8 @@ type StartStop interface {
 type Item interface {
 	Interaction() *discordgo.Interaction
 }
+
+// Captioner describes a generated image in words, which is posted as its alt text.
+type Captioner interface {
+	Caption(image []byte) (string, error)
+}
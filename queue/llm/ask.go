@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/utils"
+)
+
+// askContextLength is the maximum number of characters of the message sent along with the question.
+const askContextLength = historyBudget
+
+// processAskCommand responds with a modal for the question about the message the context menu was used on.
+// The message is kept until the modal is submitted, since the modal submission doesn't include it.
+func (q *LLMQueue) processAskCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.ApplicationCommandData()
+	message, ok := data.Resolved.Messages[data.TargetID]
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Could not find the message.")
+	}
+	if messageContext(message) == "" {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The message has no text, embeds or attachments to ask about.")
+	}
+	if message.ChannelID == "" {
+		message.ChannelID = i.ChannelID
+	}
+
+	q.mu.Lock()
+	q.asking[utils.GetUser(i.Interaction).ID] = message
+	q.mu.Unlock()
+
+	return handlers.Wrap(s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID:   askModal,
+			Title:      "Ask the LLM about this message",
+			Components: []discordgo.MessageComponent{components[askInput]},
+		},
+	}))
+}
+
+// processAskModal queues the question along with the message it's about.
+func (q *LLMQueue) processAskModal(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	user := utils.GetUser(i.Interaction)
+
+	q.mu.Lock()
+	message, ok := q.asking[user.ID]
+	delete(q.asking, user.ID)
+	q.mu.Unlock()
+	if !ok {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the message to ask about, please use the command again.")
+	}
+
+	var question string
+	for _, row := range i.ModalSubmitData().Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok && input.CustomID == askInput {
+				question = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	if question == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a question.")
+	}
+
+	item := q.NewItem(i.Interaction, WithPrompt(askPrompt(message, question)))
+	item.Type = ItemTypeAsk
+	item.Message = message
+	item.Question = question
+	item.Endpoint, item.Request.Model = q.memberDefaults(user.ID)
+	item.Endpoint.limit(item.Request)
+
+	position, err := q.Add(item)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error adding your question to the queue.", err)
+	}
+
+	queueString := fmt.Sprintf(
+		"I'm reading the message. You are currently #%d in line.\n<@%s> asked \n```\n%s\n```",
+		position,
+		user.ID,
+		question,
+	)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, queueString, components[cancel])
+	return err
+}
+
+// processAsk answers the question as a reply to the message it's about, then links to the answer in the response to the modal.
+func (q *LLMQueue) processAsk() error {
+	defer q.done()
+	item := q.current
+	message := item.Message
+
+	thinking := "Thinking about your question..."
+	_, err := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, &discordgo.WebhookEdit{
+		Content:    &thinking,
+		Components: &[]discordgo.MessageComponent{},
+	})
+	if err != nil {
+		log.Printf("Error updating the question status: %v", err)
+	}
+
+	response, err := item.Endpoint.host.Infer(item.Request)
+	if err != nil {
+		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, fmt.Errorf("error processing LLM request: %w", err))
+	}
+	if len(response.Choices) == 0 {
+		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, fmt.Errorf("LLM response was invalid"))
+	}
+
+	asker := utils.GetUser(item.DiscordInteraction).ID
+	content := fmt.Sprintf("<@%s> asked: %s\n\n%s", asker, item.Question, response.Choices[0].Message.Content)
+	send := replyMessage(content, message.Reference())
+	// only the asker is pinged, not the mentions in the question or the answer
+	send.AllowedMentions = &discordgo.MessageAllowedMentions{Users: []string{asker}}
+	reply, err := q.botSession.ChannelMessageSendComplex(message.ChannelID, send)
+	if err != nil {
+		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, "Error replying to the message.", err)
+	}
+
+	guildID := item.DiscordInteraction.GuildID
+	if guildID == "" {
+		guildID = "@me"
+	}
+	_, err = handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction,
+		fmt.Sprintf("Answered https://discord.com/channels/%s/%s/%s", guildID, reply.ChannelID, reply.ID))
+	return err
+}
+
+// askPrompt combines the message and the question into a single prompt.
+func askPrompt(message *discordgo.Message, question string) string {
+	context := []rune(messageContext(message))
+	if len(context) > askContextLength {
+		context = append(context[:askContextLength], []rune(" ...")...)
+	}
+
+	return fmt.Sprintf("%s wrote:\n\"\"\"\n%s\n\"\"\"\n\n%s", utils.GetUsername(message), string(context), question)
+}
+
+// messageContext describes the message for the model, with its embeds and attachments written out as text.
+func messageContext(message *discordgo.Message) string {
+	var parts []string
+	if message.Content != "" {
+		parts = append(parts, message.Content)
+	}
+
+	for _, embed := range message.Embeds {
+		lines := []string{"[Embed]"}
+		if embed.Author != nil && embed.Author.Name != "" {
+			lines = append(lines, "Author: "+embed.Author.Name)
+		}
+		if embed.Title != "" {
+			lines = append(lines, "Title: "+embed.Title)
+		}
+		if embed.Description != "" {
+			lines = append(lines, embed.Description)
+		}
+		for _, field := range embed.Fields {
+			lines = append(lines, field.Name+": "+field.Value)
+		}
+		if embed.Footer != nil && embed.Footer.Text != "" {
+			lines = append(lines, "Footer: "+embed.Footer.Text)
+		}
+		if len(lines) > 1 {
+			parts = append(parts, strings.Join(lines, "\n"))
+		}
+	}
+
+	for _, attachment := range message.Attachments {
+		// discordgo doesn't decode the alt text of attachments, so they are described by their file
+		line := fmt.Sprintf("[Attachment] %s (%s)", attachment.Filename, attachment.ContentType)
+		if attachment.Width > 0 && attachment.Height > 0 {
+			line += fmt.Sprintf(", %dx%d", attachment.Width, attachment.Height)
+		}
+		parts = append(parts, line)
+	}
+
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/api/openai"
+)
+
+const (
+	captionPrompt = "Write alt text for this image for someone who can't see it. Describe what it shows in one or two sentences, without starting with \"This image\"."
+	// captionTimeout keeps a slow model from holding up the image queues, which post the images without alt text instead.
+	captionTimeout   = time.Minute
+	captionMaxTokens = 200
+)
+
+// Caption describes the image with the caption model, for the alt text of the images of the other queues.
+// It's sent to the default endpoint directly rather than queued, since the image queues wait for it.
+// Caption returns an error when no caption model is set, which disables alt text.
+func (q *LLMQueue) Caption(image []byte) (string, error) {
+	if q.captionModel == "" {
+		return "", errors.New("no caption model set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), captionTimeout)
+	defer cancel()
+
+	url := openai.ImageDataURL(http.DetectContentType(image), base64.StdEncoding.EncodeToString(image))
+	response, err := openai.Chat(ctx, q.endpoints[0].host, &openai.ChatRequest{
+		Model: q.captionModel,
+		Messages: []openai.Message{{
+			Role:  llm.UserRole,
+			Parts: []openai.ContentPart{openai.TextPart(captionPrompt), openai.ImagePart(url)},
+		}},
+		Temperature: 0.2,
+		MaxTokens:   captionMaxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(response.Choices) == 0 {
+		return "", errors.New("LLM response was invalid")
+	}
+
+	return strings.TrimSpace(response.Choices[0].Message.Content), nil
+}
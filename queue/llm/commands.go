@@ -10,15 +10,123 @@ func (q *LLMQueue) commands() []*discordgo.ApplicationCommand {
 			Name:        LLMCommand,
 			Description: "Ask the bot to generate text using an LLM",
 			Type:        discordgo.ChatApplicationCommand,
-			Options: []*discordgo.ApplicationCommandOption{
+			Options: append([]*discordgo.ApplicationCommandOption{
 				commandOptions[promptOption],
 				commandOptions[systemPromptOption],
 				commandOptions[maxTokensOption],
+				commandOptions[threadOption],
+				commandOptions[toolsOption],
+				commandOptions[personaOption],
+			}, append(q.modelOptions(), imageCommandOptions()...)...),
+		},
+		{
+			Name:        LLMSettingsCommand,
+			Description: "Change your default settings for the llm command",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: append(q.modelOptions(),
+				commandOptions[resetOption],
+			),
+		},
+		q.personaCommand(),
+		{
+			Name: LLMAskMessage,
+			Type: discordgo.MessageApplicationCommand,
+		},
+	}
+}
+
+func (q *LLMQueue) personaCommand() *discordgo.ApplicationCommand {
+	name := *commandOptions[personaNameOption]
+	name.Autocomplete = false
+
+	systemPrompt := *commandOptions[systemPromptOption]
+	systemPrompt.Required = true
+
+	settings := []*discordgo.ApplicationCommandOption{
+		commandOptions[temperatureOption],
+		commandOptions[maxTokensOption],
+		commandOptions[llmModelOption],
+	}
+
+	defaultName := *commandOptions[personaNameOption]
+	defaultName.Required = false
+	defaultName.Description = "The persona to use when none is picked. Leave empty to clear the default"
+
+	return &discordgo.ApplicationCommand{
+		Name:        PersonaCommand,
+		Description: "Manage the LLM personas of this server",
+		Type:        discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        personaCreate,
+				Description: "Create a persona with its own system prompt and settings",
+				Options:     append([]*discordgo.ApplicationCommandOption{&name, &systemPrompt}, settings...),
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        personaEdit,
+				Description: "Change a persona you created",
+				Options:     append([]*discordgo.ApplicationCommandOption{commandOptions[personaNameOption], commandOptions[systemPromptOption]}, settings...),
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        personaDelete,
+				Description: "Delete a persona you created",
+				Options:     []*discordgo.ApplicationCommandOption{commandOptions[personaNameOption]},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        personaList,
+				Description: "List the personas of this server",
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        personaDefault,
+				Description: "Set the default persona of this server (requires Manage Server)",
+				Options:     []*discordgo.ApplicationCommandOption{&defaultName},
 			},
 		},
 	}
 }
 
+// modelOptions returns the model option, preceded by the endpoint option if there is more than one endpoint to pick from.
+func (q *LLMQueue) modelOptions() []*discordgo.ApplicationCommandOption {
+	if len(q.endpoints) < 2 {
+		return []*discordgo.ApplicationCommandOption{commandOptions[llmModelOption]}
+	}
+
+	endpoint := *commandOptions[endpointOption]
+	endpoint.Choices = nil
+	for _, e := range q.endpoints[:min(25, len(q.endpoints))] {
+		endpoint.Choices = append(endpoint.Choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  e.Name,
+			Value: e.Name,
+		})
+	}
+
+	return []*discordgo.ApplicationCommandOption{&endpoint, commandOptions[llmModelOption]}
+}
+
+// imageOptions are the images the model can see, for vision models.
+var imageOptions = []string{imageOption, imageOption + "_2", imageOption + "_3"}
+
+func imageCommandOptions() []*discordgo.ApplicationCommandOption {
+	options := make([]*discordgo.ApplicationCommandOption, len(imageOptions))
+	for i, name := range imageOptions {
+		options[i] = &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionAttachment,
+			Name:        name,
+			Description: "An image for the model to look at, which needs a model with vision",
+			Required:    false,
+		}
+	}
+	return options
+}
+
+// minTemperature is referenced by the temperature option, since Discord needs to tell a minimum of 0 apart from no minimum.
+var minTemperature = 0.0
+
 var commandOptions = map[string]*discordgo.ApplicationCommandOption{
 	promptOption: {
 		Type:        discordgo.ApplicationCommandOptionString,
@@ -38,4 +146,58 @@ var commandOptions = map[string]*discordgo.ApplicationCommandOption{
 		Description: "The maximum number of tokens to generate. Use -1 for infinite (default: 1024)",
 		Required:    false,
 	},
+	threadOption: {
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        threadOption,
+		Description: "Open a thread to continue the conversation by replying in it",
+		Required:    false,
+	},
+	toolsOption: {
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        toolsOption,
+		Description: "Let the model generate images, list models and check the queue. The response is not streamed",
+		Required:    false,
+	},
+	endpointOption: {
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        endpointOption,
+		Description: "The server to send the request to",
+		Required:    false,
+	},
+	llmModelOption: {
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         llmModelOption,
+		Description:  "The model to generate with",
+		Required:     false,
+		Autocomplete: true,
+	},
+	personaOption: {
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         personaOption,
+		Description:  "The persona to answer as, which sets the system prompt and settings",
+		Required:     false,
+		Autocomplete: true,
+	},
+	personaNameOption: {
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         personaNameOption,
+		Description:  "The name of the persona",
+		Required:     true,
+		Autocomplete: true,
+		MaxLength:    choiceLength,
+	},
+	temperatureOption: {
+		Type:        discordgo.ApplicationCommandOptionNumber,
+		Name:        temperatureOption,
+		Description: "The sampling temperature, where higher is more creative (default: 0.7)",
+		Required:    false,
+		MinValue:    &minTemperature,
+		MaxValue:    2,
+	},
+	resetOption: {
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        resetOption,
+		Description: "Reset your settings to the bot defaults",
+		Required:    false,
+	},
 }
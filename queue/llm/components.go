@@ -6,6 +6,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 
 	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/utils"
 )
 
@@ -14,6 +15,9 @@ type Handler = func(*discordgo.Session, *discordgo.InteractionCreate) error
 const (
 	prefix = "llm_"
 	cancel = prefix + "cancel"
+
+	askModal = prefix + "ask_modal"
+	askInput = prefix + "ask_input"
 )
 
 var components = map[string]discordgo.MessageComponent{
@@ -26,6 +30,31 @@ var components = map[string]discordgo.MessageComponent{
 			},
 		},
 	},
+	askInput: discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:    askInput,
+				Label:       "Question",
+				Style:       discordgo.TextInputParagraph,
+				Placeholder: "What does this mean?",
+				Required:    true,
+				MinLength:   1,
+				MaxLength:   1000,
+			},
+		},
+	},
+}
+
+// CommandOf returns the command the component or modal belongs to, see queue.CommandOwner.
+func (q *LLMQueue) CommandOf(customID string) (queue.Command, bool) {
+	switch customID {
+	case askModal:
+		return LLMAskMessage, true
+	case cancel:
+		return LLMCommand, true
+	default:
+		return "", false
+	}
 }
 
 func (q *LLMQueue) components() map[string]Handler {
@@ -35,18 +64,22 @@ func (q *LLMQueue) components() map[string]Handler {
 }
 
 func (q *LLMQueue) removeImagineFromQueue(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if utils.GetUser(i.Interaction).ID != i.Message.InteractionMetadata.User.ID {
+	metadata := handlers.MessageInteractionMetadata(i.Message)
+	if metadata == nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Unable to determine who started this generation")
+	}
+	if utils.GetUser(i.Interaction).ID != metadata.User.ID {
 		return handlers.ErrorEphemeral(s, i.Interaction, "You can only cancel your own generations")
 	}
 
-	log.Printf("Removing imagine from queue: %#v", i.Message.InteractionMetadata)
+	log.Printf("Removing imagine from queue: %#v", metadata)
 
-	err := q.Remove(i.Message.InteractionMetadata)
+	err := q.Remove(metadata)
 	if err != nil {
 		log.Printf("Error removing imagine from queue: %v", err)
 		return handlers.ErrorEdit(s, i.Interaction, "Error removing imagine from queue")
 	}
-	log.Printf("Removed imagine from queue: %#v", i.Message.InteractionMetadata)
+	log.Printf("Removed imagine from queue: %#v", metadata)
 
 	return handlers.UpdateFromComponent(s, i.Interaction, "Generation cancelled", handlers.Components[handlers.DeleteButton])
 }
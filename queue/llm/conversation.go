@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	// historyBudget is the maximum number of characters of history sent with a follow-up.
+	// The oldest turns are dropped first, while the system prompt and the latest reply are always kept.
+	historyBudget = 16000
+
+	threadNameLength = 100
+	messageLength    = 1900
+)
+
+// openThread opens the thread of the conversation on the response message, unless it's already open.
+func (q *LLMQueue) openThread(item *LLMItem, message *discordgo.Message) (*discordgo.Channel, error) {
+	if item.thread != nil {
+		return item.thread, nil
+	}
+
+	request := item.Request
+	name := []rune(request.Messages[len(request.Messages)-1].Content)
+	if len(name) > threadNameLength {
+		name = name[:threadNameLength]
+	}
+
+	thread, err := q.botSession.MessageThreadStartComplex(message.ChannelID, message.ID, &discordgo.ThreadStart{
+		Name:                string(name),
+		AutoArchiveDuration: 1440,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	item.thread = thread
+	return thread, nil
+}
+
+// startConversation opens a thread on the response message and stores the first turn, so that replies in the thread can continue the conversation.
+func (q *LLMQueue) startConversation(item *LLMItem, response *llm.Response, message *discordgo.Message) error {
+	request := item.Request
+	thread, err := q.openThread(item, message)
+	if err != nil {
+		return handlers.ErrorFollowup(q.botSession, item.DiscordInteraction, "Error opening a thread for the conversation.", err)
+	}
+
+	_, err = q.conversationRepo.Create(context.Background(), &entities.LLMConversation{
+		ThreadID:    thread.ID,
+		MemberID:    utils.GetUser(item.DiscordInteraction).ID,
+		Endpoint:    item.Endpoint.Name,
+		Model:       request.Model,
+		MaxTokens:   request.MaxTokens,
+		Temperature: &request.Temperature,
+		Tools:       item.Tools,
+		Messages:    append(request.Messages, response.Choices[0].Message),
+	})
+	if err != nil {
+		return handlers.ErrorFollowup(q.botSession, item.DiscordInteraction, "Error saving the conversation.", err)
+	}
+
+	log.Printf("Started LLM conversation in thread %s", thread.ID)
+	return nil
+}
+
+// HandlesMessages reports whether conversations are enabled, as only their threads are read.
+func (q *LLMQueue) HandlesMessages() bool {
+	return q.conversationRepo != nil
+}
+
+// MessageCreate queues replies in conversation threads as follow-up turns.
+func (q *LLMQueue) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if q.conversationRepo == nil || m.Author == nil || m.Author.Bot || m.Content == "" {
+		return
+	}
+
+	channel, err := s.State.Channel(m.ChannelID)
+	if err != nil {
+		channel, err = s.Channel(m.ChannelID)
+		if err != nil {
+			return
+		}
+	}
+	if !channel.IsThread() {
+		return
+	}
+
+	_, err = q.conversationRepo.GetByThreadID(context.Background(), m.ChannelID)
+	if err != nil {
+		if !errors.Is(err, &repositories.NotFoundError{}) {
+			log.Printf("Error retrieving conversation for thread %s: %v", m.ChannelID, err)
+		}
+		return
+	}
+
+	item := &LLMItem{
+		Type:    ItemTypeFollowUp,
+		Message: m.Message,
+		Created: time.Now(),
+	}
+
+	if _, err := q.Add(item); err != nil {
+		_, err = s.ChannelMessageSendReply(m.ChannelID, fmt.Sprintf("Error adding your reply to the queue: %v", err), m.Reference())
+		if err != nil {
+			log.Printf("Error replying to %s: %v", m.Author.Username, err)
+		}
+	}
+}
+
+// processFollowUp answers a reply in a conversation thread with the stored history, then stores both turns.
+// The history is loaded when the item is processed rather than when it is queued, so consecutive replies see each other.
+func (q *LLMQueue) processFollowUp() error {
+	defer q.done()
+	item := q.current
+	message := item.Message
+
+	conversation, err := q.conversationRepo.GetByThreadID(context.Background(), message.ChannelID)
+	if err != nil {
+		return q.replyError(message, err)
+	}
+
+	reply := llm.UserMessage(message.Content)
+
+	item.Endpoint, err = q.endpoint(conversation.Endpoint)
+	if err != nil {
+		return q.replyError(message, err)
+	}
+	item.Request = q.DefaultQueueItem().Request
+	item.Request.Model = conversation.Model
+	item.Request.MaxTokens = conversation.MaxTokens
+	if conversation.Temperature != nil {
+		item.Request.Temperature = *conversation.Temperature
+	}
+	item.Endpoint.limit(item.Request)
+	item.Request.Messages = truncateHistory(append(conversation.Messages, reply), historyBudget)
+
+	if err := q.botSession.ChannelTyping(message.ChannelID); err != nil {
+		log.Printf("Error sending typing indicator: %v", err)
+	}
+
+	var response llm.Response
+	item.Tools = conversation.Tools
+	if item.Tools {
+		response, err = q.runTools(context.Background(), item, nil)
+	} else {
+		response, err = item.Endpoint.host.Infer(item.Request)
+	}
+	if err != nil {
+		return q.replyError(message, fmt.Errorf("error processing LLM request: %w", err))
+	}
+	if len(response.Choices) == 0 {
+		return q.replyError(message, errors.New("LLM response was invalid"))
+	}
+
+	answer := response.Choices[0].Message
+	send := replyMessage(answer.Content, message.Reference())
+
+	if len(item.toolCalls) > 0 {
+		embed := &discordgo.MessageEmbed{}
+		setTools(embed, item.toolCalls)
+		send.Embeds = []*discordgo.MessageEmbed{embed}
+	}
+
+	if _, err := q.botSession.ChannelMessageSendComplex(message.ChannelID, send); err != nil {
+		return fmt.Errorf("error sending follow-up: %w", err)
+	}
+
+	return q.conversationRepo.AddMessages(context.Background(), conversation.ID, reply, answer)
+}
+
+// replyMessage replies with the content, truncated to messageLength with the full content attached as a file.
+func replyMessage(content string, reference *discordgo.MessageReference) *discordgo.MessageSend {
+	send := &discordgo.MessageSend{
+		Content:   content,
+		Reference: reference,
+	}
+	if runes := []rune(content); len(runes) > messageLength {
+		send.Content = fmt.Sprintf("%s ...\n<truncated, see file>", string(runes[:messageLength]))
+		send.Files = []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("output-%s.txt", time.Now().Format("2006-01-02-15-04-05")),
+				ContentType: "text/plain",
+				Reader:      strings.NewReader(content),
+			},
+		}
+	}
+	return send
+}
+
+// messageAuthor returns an interaction from the author of the message, to queue images on their behalf.
+func messageAuthor(message *discordgo.Message) *discordgo.Interaction {
+	from := &discordgo.Interaction{
+		GuildID: message.GuildID,
+		User:    message.Author,
+	}
+	if message.Member != nil {
+		member := *message.Member
+		member.User = message.Author
+		from.Member = &member
+	}
+	return from
+}
+
+func (q *LLMQueue) replyError(message *discordgo.Message, err error) error {
+	_, sendErr := q.botSession.ChannelMessageSendReply(message.ChannelID, handlers.Redact(fmt.Sprintf("Error: %v", err)), message.Reference())
+	if sendErr != nil {
+		log.Printf("Error replying with error: %v", sendErr)
+	}
+	return err
+}
+
+// truncateHistory drops the oldest turns until the messages fit in budget characters.
+// The system prompt and the last message are always kept, and the history never starts with an assistant turn.
+func truncateHistory(messages []llm.Message, budget int) []llm.Message {
+	var system []llm.Message
+	if len(messages) > 0 && messages[0].Role == llm.SystemRole {
+		system, messages = messages[:1], messages[1:]
+	}
+
+	size := 0
+	for _, message := range system {
+		size += len(message.Content)
+	}
+	for _, message := range messages {
+		size += len(message.Content)
+	}
+
+	for len(messages) > 1 && (size > budget || messages[0].Role == llm.AssistantRole) {
+		size -= len(messages[0].Content)
+		messages = messages[1:]
+	}
+
+	return append(append([]llm.Message{}, system...), messages...)
+}
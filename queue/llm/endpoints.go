@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+)
+
+// Endpoint is a named OpenAI-compatible server members can send their requests to,
+// such as a local llama.cpp server and a hosted service.
+//
+//	[
+//	  {"name": "local", "url": "http://localhost:7869/v1/chat/completions", "default_model": "llama3", "max_tokens": 2048},
+//	  {"name": "openai", "url": "https://api.openai.com/v1/chat/completions", "api_key_env": "OPENAI_API_KEY", "models": ["gpt-4o-mini"], "max_tokens": 1024}
+//	]
+type Endpoint struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	APIKey string `json:"api_key,omitempty"`
+	// APIKeyEnv reads the API key from an environment variable instead, to keep it out of the endpoints file.
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+
+	// DefaultModel is used when the member hasn't picked a model. It defaults to the first model in Models.
+	DefaultModel string `json:"default_model,omitempty"`
+	// Models is the allowlist of models members can pick from. Any model served by the endpoint is allowed if it's empty.
+	Models []string `json:"models,omitempty"`
+	// MaxTokens caps the tokens a member can request, including -1 for infinite. There is no cap if it's 0.
+	MaxTokens int64 `json:"max_tokens,omitempty"`
+
+	host   *llm.Config
+	models modelCache
+}
+
+// LoadEndpoints reads the endpoints from a JSON file.
+func LoadEndpoints(filename string) ([]*Endpoint, error) {
+	blob, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading LLM endpoints: %w", err)
+	}
+
+	var endpoints []*Endpoint
+	if err := json.Unmarshal(blob, &endpoints); err != nil {
+		return nil, fmt.Errorf("error decoding LLM endpoints: %w", err)
+	}
+
+	return endpoints, nil
+}
+
+// init parses the URL and resolves the API key, which is registered to be redacted from error messages.
+func (e *Endpoint) init() error {
+	if e.Name == "" {
+		return errors.New("LLM endpoint is missing a name")
+	}
+
+	endpoint, err := url.Parse(e.URL)
+	if err != nil {
+		return fmt.Errorf("error parsing the URL of LLM endpoint %s: %w", e.Name, err)
+	}
+
+	if e.APIKeyEnv != "" {
+		e.APIKey = os.Getenv(e.APIKeyEnv)
+		if e.APIKey == "" {
+			return fmt.Errorf("environment variable %s for LLM endpoint %s is empty", e.APIKeyEnv, e.Name)
+		}
+	}
+	handlers.RedactSecret(e.APIKey)
+
+	e.host = &llm.Config{
+		Host:     e.URL,
+		APIKey:   e.APIKey,
+		Endpoint: *endpoint,
+	}
+
+	return nil
+}
+
+// endpoint returns the endpoint with the given name, or the first endpoint if name is empty.
+func (q *LLMQueue) endpoint(name string) (*Endpoint, error) {
+	if name == "" {
+		return q.endpoints[0], nil
+	}
+
+	i := slices.IndexFunc(q.endpoints, func(e *Endpoint) bool { return e.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("unknown LLM endpoint %s", name)
+	}
+
+	return q.endpoints[i], nil
+}
+
+// limit caps the requested tokens to MaxTokens.
+func (e *Endpoint) limit(request *llm.Request) {
+	if e.MaxTokens > 0 && (request.MaxTokens < 0 || request.MaxTokens > e.MaxTokens) {
+		request.MaxTokens = e.MaxTokens
+	}
+}
@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"cmp"
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/utils"
+)
+
+// Enhance queues a request whose response is handed to then instead of being shown,
+// letting other queues build on it, such as /imagine expanding a short idea into a full prompt.
+// The interaction is shared with the other queue, so then is responsible for responding to it.
+func (q *LLMQueue) Enhance(interaction *discordgo.Interaction, request *llm.Request, then func(*llm.Response, error)) (int, error) {
+	if request == nil || then == nil {
+		return -1, errors.New("enhance needs a request and a handoff")
+	}
+
+	item := q.NewItem(interaction)
+	endpoint, model := q.memberDefaults(utils.GetUser(interaction).ID)
+	request.Model = cmp.Or(request.Model, model)
+	endpoint.limit(request)
+	item.Type = ItemTypeEnhance
+	item.Endpoint = endpoint
+	item.Request = request
+	item.Handoff = then
+
+	return q.Add(item)
+}
+
+func (q *LLMQueue) processEnhance() {
+	defer q.done()
+	item := q.current
+
+	response, err := item.Endpoint.host.Infer(item.Request)
+	if err == nil && len(response.Choices) == 0 {
+		err = errors.New("LLM response was empty")
+	}
+
+	q.release(item)
+	item.Handoff(&response, err)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 
+	"stable_diffusion_bot/api/openai"
 	"stable_diffusion_bot/discord_bot/handlers"
 	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/utils"
@@ -12,19 +13,51 @@ import (
 	"github.com/bwmarrin/discordgo"
 )
 
-const LLMCommand = "llm"
+const (
+	LLMCommand         = "llm"
+	LLMSettingsCommand = "llm_settings"
+	LLMAskMessage      = "Ask LLM about this"
+	PersonaCommand     = "persona"
+)
 
 const (
 	promptOption       = "prompt"
 	systemPromptOption = "system_prompt"
 	maxTokensOption    = "max_tokens"
-	llmModelOption     = "model" // TODO: Retrieve /v1/models from endpoint
+	threadOption       = "thread"
+	toolsOption        = "tools"
+	llmModelOption     = "model"
+	endpointOption     = "endpoint"
+	resetOption        = "reset"
+	personaOption      = "persona"
+	personaNameOption  = "name"
+	temperatureOption  = "temperature"
+	imageOption        = "image"
+)
+
+const (
+	personaCreate  = "create"
+	personaEdit    = "edit"
+	personaDelete  = "delete"
+	personaList    = "list"
+	personaDefault = "default"
 )
 
 func (q *LLMQueue) handlers() queue.CommandHandlers {
 	return queue.CommandHandlers{
 		discordgo.InteractionApplicationCommand: {
-			LLMCommand: q.processLLMCommand,
+			LLMCommand:         q.processLLMCommand,
+			LLMSettingsCommand: q.processLLMSettingsCommand,
+			LLMAskMessage:      q.processAskCommand,
+			PersonaCommand:     q.processPersonaCommand,
+		},
+		discordgo.InteractionApplicationCommandAutocomplete: {
+			LLMCommand:         q.processLLMAutocomplete,
+			LLMSettingsCommand: q.processLLMAutocomplete,
+			PersonaCommand:     q.processPersonaAutocomplete,
+		},
+		discordgo.InteractionModalSubmit: {
+			askModal: q.processAskModal,
 		},
 	}
 }
@@ -47,6 +80,22 @@ func (q *LLMQueue) processLLMCommand(s *discordgo.Session, i *discordgo.Interact
 		return handlers.ErrorEdit(s, i.Interaction, errors.New("unexpected error: LLM request messages is less than 2"))
 	}
 
+	endpoint, model, err := q.commandEndpoint(utils.GetUser(i.Interaction).ID, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+	item.Endpoint = endpoint
+	item.Request.Model = model
+
+	persona, err := q.commandPersona(i.GuildID, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+	if persona != nil {
+		_, modelPicked := optionMap[llmModelOption]
+		applyPersona(item, persona, modelPicked)
+	}
+
 	if s, ok := optionMap[systemPromptOption]; ok {
 		item.Request.Messages[0].Content = s.StringValue()
 	}
@@ -54,6 +103,41 @@ func (q *LLMQueue) processLLMCommand(s *discordgo.Session, i *discordgo.Interact
 	if m, ok := optionMap[maxTokensOption]; ok {
 		item.Request.MaxTokens = m.IntValue()
 	}
+	endpoint.limit(item.Request)
+
+	if t, ok := optionMap[threadOption]; ok && t.BoolValue() {
+		if q.conversationRepo == nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Conversations are not available.")
+		}
+		if i.GuildID == "" {
+			return handlers.ErrorEdit(s, i.Interaction, "Threads can only be opened in a server.")
+		}
+		item.Thread = true
+	}
+
+	if t, ok := optionMap[toolsOption]; ok && t.BoolValue() {
+		item.Tools = true
+	}
+
+	attachments, err := utils.GetAttachments(i)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error getting attachments.", err)
+	}
+	for _, name := range imageOptions {
+		option, ok := optionMap[name]
+		if !ok {
+			continue
+		}
+		attachment, ok := attachments[option.Value.(string)]
+		if !ok {
+			return handlers.ErrorEdit(s, i.Interaction, "The attachments need to be images.")
+		}
+		encoded, err := attachment.Image.Base64()
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error downloading the image.", err)
+		}
+		item.Images = append(item.Images, openai.ImageDataURL(attachment.Attachment.ContentType, encoded))
+	}
 
 	position, err := q.Add(item)
 	if err != nil {
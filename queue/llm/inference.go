@@ -1,7 +1,10 @@
 package llm
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
@@ -9,6 +12,7 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/ellypaws/inkbunny-sd/llm"
 
+	"stable_diffusion_bot/api/openai"
 	"stable_diffusion_bot/discord_bot/handlers"
 	"stable_diffusion_bot/utils"
 )
@@ -19,6 +23,12 @@ You can use markdown to format your text.
 
 const LLama3 = `lmstudio-community/Meta-Llama-3-8B-Instruct-GGUF/Meta-Llama-3-8B-Instruct-Q8_0.gguf`
 
+// streamEditInterval throttles the message edits while the response is streamed, to stay within Discord's rate limits.
+const streamEditInterval = 2 * time.Second
+
+// outputLength is the length of the output shown in the embed, after which the full output is attached as a file.
+const outputLength = 900
+
 func (q *LLMQueue) processLLM() error {
 	defer q.done()
 	item := q.current
@@ -28,30 +38,104 @@ func (q *LLMQueue) processLLM() error {
 		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, fmt.Errorf("LLM request of type %v is nil", item.Type))
 	}
 
-	embed, webhook, err := showProcessingLLM(item, q)
+	embed, processing, err := showProcessingLLM(item, q)
 	if err != nil {
 		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, fmt.Errorf("error showing processing LLM: %w", err))
 	}
 
-	response, err := q.host.Infer(request)
-	if err != nil {
+	// the images queued by the tools are posted in the thread, so it's opened before they run
+	if item.Thread && item.Tools {
+		if _, err := q.openThread(item, processing); err != nil {
+			return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, "Error opening a thread for the conversation.", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	q.mu.Lock()
+	item.cancel = cancel
+	q.mu.Unlock()
+
+	var response llm.Response
+	if item.Tools {
+		response, err = q.runTools(ctx, item, func() {
+			setTools(embed, item.toolCalls)
+			webhook := &discordgo.WebhookEdit{Embeds: &[]*discordgo.MessageEmbed{embed}}
+			if _, err := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook); err != nil {
+				log.Printf("Error updating LLM tool calls: %v", err)
+			}
+		})
+	} else {
+		var lastEdit time.Time
+		onDelta := func(content string) {
+			if time.Since(lastEdit) < streamEditInterval {
+				return
+			}
+			lastEdit = time.Now()
+
+			webhook := &discordgo.WebhookEdit{Embeds: &[]*discordgo.MessageEmbed{embed}}
+			setOutput(embed, content)
+			if len(content) > outputLength {
+				attachLLMResponse(content, webhook)
+			}
+
+			if _, err := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook); err != nil {
+				log.Printf("Error updating streamed LLM response: %v", err)
+			}
+		}
+
+		if len(item.Images) == 0 {
+			response, err = openai.Stream(ctx, item.Endpoint.host, request, onDelta)
+		} else {
+			response, err = openai.StreamChat(ctx, item.Endpoint.host, &openai.ChatRequest{
+				Model:       request.Model,
+				Messages:    item.chatMessages(),
+				Temperature: request.Temperature,
+				MaxTokens:   request.MaxTokens,
+			}, onDelta)
+		}
+	}
+	interrupted := errors.Is(err, context.Canceled)
+	// a stream that broke off still shows what was received, like an interrupted one
+	incomplete := errors.Is(err, io.ErrUnexpectedEOF) && len(response.Choices) > 0 && response.Choices[0].Message.Content != ""
+	if err != nil && !interrupted && !incomplete {
 		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, fmt.Errorf("error processing LLM request: %w", err))
 	}
 	if len(response.Choices) == 0 {
 		return handlers.ErrorEdit(q.botSession, item.DiscordInteraction, fmt.Errorf("LLM response was invalid"))
 	}
 
-	webhook = llmResponseEmbed(item, &response, embed)
+	if interrupted {
+		embed.Title = item.Type + " (Interrupted)"
+	}
+	if incomplete {
+		embed.Title = item.Type + " (Incomplete)"
+	}
+	webhook := llmResponseEmbed(item, &response, embed)
+
+	if content := response.Choices[0].Message.Content; len(content) > outputLength {
+		attachLLMResponse(content, webhook)
+	}
+
+	message, err := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook)
+	if err != nil {
+		return err
+	}
+
+	if interrupted || incomplete {
+		return nil
+	}
 
-	if len(response.Choices[0].Message.Content) > 900 {
-		attachLLMResponse(&response, webhook)
+	if item.Thread {
+		return q.startConversation(item, &response, message)
 	}
 
-	_, err = handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook)
-	return err
+	return nil
 }
 
-func showProcessingLLM(item *LLMItem, q *LLMQueue) (*discordgo.MessageEmbed, *discordgo.WebhookEdit, error) {
+// showProcessingLLM shows the request in the response, and returns the embed along with the message of the response.
+func showProcessingLLM(item *LLMItem, q *LLMQueue) (*discordgo.MessageEmbed, *discordgo.Message, error) {
 	request := item.Request
 
 	content := fmt.Sprintf(
@@ -65,12 +149,12 @@ func showProcessingLLM(item *LLMItem, q *LLMQueue) (*discordgo.MessageEmbed, *di
 		Embeds:  &[]*discordgo.MessageEmbed{embed},
 	}
 
-	_, err := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook)
+	message, err := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return embed, webhook, nil
+	return embed, message, nil
 }
 
 func llmResponseEmbed(item *LLMItem, response *llm.Response, embed *discordgo.MessageEmbed) *discordgo.WebhookEdit {
@@ -79,17 +163,11 @@ func llmResponseEmbed(item *LLMItem, response *llm.Response, embed *discordgo.Me
 		timeSince = "unknown"
 	}
 	mention := fmt.Sprintf("<@%s> generated in %s", utils.GetUser(item.DiscordInteraction).ID, timeSince)
-	message := response.Choices[0].Message.Content
-	if len(message) > 900 {
-		message = fmt.Sprintf("%s ...\n<truncated, see file>", message[:900])
-	}
 
-	embed.Fields[0].Value = fmt.Sprintf("`%s`", response.Model)
-	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-		Name:   "Output",
-		Value:  fmt.Sprintf("%s", message),
-		Inline: false,
-	})
+	if response.Model != "" {
+		embed.Fields[0].Value = fmt.Sprintf("`%s`", response.Model)
+	}
+	setOutput(embed, response.Choices[0].Message.Content)
 
 	return &discordgo.WebhookEdit{
 		Content:    &mention,
@@ -98,14 +176,43 @@ func llmResponseEmbed(item *LLMItem, response *llm.Response, embed *discordgo.Me
 	}
 }
 
-func attachLLMResponse(response *llm.Response, webhook *discordgo.WebhookEdit) {
+// setOutput shows the output in the embed, truncated to outputLength.
+// The field is added on the first call and updated on later calls as the response is streamed.
+func setOutput(embed *discordgo.MessageEmbed, content string) {
+	if content == "" {
+		// a model that only called tools can answer with nothing, which Discord doesn't allow in a field
+		content = "*No output*"
+	}
+	if len(content) > outputLength {
+		content = fmt.Sprintf("%s ...\n<truncated, see file>", strings.ToValidUTF8(content[:outputLength], ""))
+	}
+
+	for _, field := range embed.Fields {
+		if field.Name == "Output" {
+			field.Value = content
+			return
+		}
+	}
+
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:   "Output",
+		Value:  content,
+		Inline: false,
+	})
+}
+
+// attachLLMResponse attaches the full output as a markdown file.
+// The attachments are listed explicitly so that the file from a previous edit is replaced instead of added to.
+func attachLLMResponse(content string, webhook *discordgo.WebhookEdit) {
+	name := fmt.Sprintf("output-%s.md", time.Now().Format("2006-01-02-15-04-05"))
 	webhook.Files = []*discordgo.File{
 		{
-			Name:        fmt.Sprintf("output-%s.txt", time.Now().Format("2006-01-02-15-04-05")),
-			ContentType: "text/plain",
-			Reader:      strings.NewReader(response.Choices[0].Message.Content),
+			Name:        name,
+			ContentType: "text/markdown",
+			Reader:      strings.NewReader(content),
 		},
 	}
+	webhook.Attachments = &[]*discordgo.MessageAttachment{{ID: "0", Filename: name}}
 }
 
 func llmEmbed(embed *discordgo.MessageEmbed, request *llm.Request, item *LLMItem, interrupted bool) *discordgo.MessageEmbed {
@@ -140,6 +247,12 @@ func llmEmbed(embed *discordgo.MessageEmbed, request *llm.Request, item *LLMItem
 
 	embed.Description = fmt.Sprintf("<@%s> asked me to process `%d` tokens",
 		user.ID, request.MaxTokens)
+	if item.Endpoint != nil {
+		embed.Description += fmt.Sprintf(" on `%s`", item.Endpoint.Name)
+	}
+	if item.Persona != "" {
+		embed.Description += fmt.Sprintf(" as `%s`", item.Persona)
+	}
 
 	embed.Timestamp = time.Now().Format(time.RFC3339)
 	embed.Footer = &discordgo.MessageEmbedFooter{
@@ -167,5 +280,12 @@ func llmEmbed(embed *discordgo.MessageEmbed, request *llm.Request, item *LLMItem
 			Value: fmt.Sprintf("```\n%s\n```", request.Messages[1].Content),
 		},
 	}
+	if len(item.Images) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Images",
+			Value:  fmt.Sprintf("`%d`", len(item.Images)),
+			Inline: true,
+		})
+	}
 	return embed
 }
@@ -1,33 +1,83 @@
 package llm
 
 import (
+	"context"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/api/openai"
 )
 
 type ItemType = string
 
 const (
 	ItemTypeInstruct ItemType = "Instruct"
+	ItemTypeFollowUp ItemType = "Follow-up"
+	ItemTypeEnhance  ItemType = "Enhance"
+	ItemTypeAsk      ItemType = "Ask"
 )
 
 type LLMItem struct {
 	Type ItemType
 
-	Request *llm.Request
+	Request  *llm.Request
+	Endpoint *Endpoint
+	// Persona is the name of the persona whose system prompt and settings the request uses, if any.
+	Persona string
+
+	// Thread opens a thread on the response so that the conversation can be continued.
+	Thread bool
+	// Message is the reply in a conversation thread for ItemTypeFollowUp, which has no DiscordInteraction,
+	// or the message asked about for ItemTypeAsk.
+	Message *discordgo.Message
+	// Question is asked about Message for ItemTypeAsk.
+	Question string
+	// Images are data URLs of the images attached to the prompt, sent to vision models along with it.
+	// They are not kept in the conversation of a thread.
+	Images []string
+	// Tools lets the model call the tools of the queue, which answers in full rather than streaming.
+	Tools bool
+	// Handoff receives the response of ItemTypeEnhance, so that another queue can continue with it.
+	Handoff func(*llm.Response, error)
 
 	Created            time.Time
 	InteractionIndex   int
 	DiscordInteraction *discordgo.Interaction
 	Interrupt          chan *discordgo.Interaction
+
+	toolCalls []toolCall
+	// images is the number of images queued by the generate_image tool.
+	images int
+	// thread is the thread of the conversation once it's opened for Thread.
+	thread *discordgo.Channel
+	// released is set once the item stopped counting towards the queue limit of its member, see LLMQueue.release.
+	released bool
+
+	// cancel stops the stream of the item while it is being processed.
+	cancel context.CancelFunc
 }
 
 func (q *LLMItem) Interaction() *discordgo.Interaction {
 	return q.DiscordInteraction
 }
 
+// chatMessages converts the messages of the request, with the images attached to the last message.
+func (q *LLMItem) chatMessages() []openai.Message {
+	messages := openai.Messages(q.Request.Messages)
+	if len(q.Images) == 0 || len(messages) == 0 {
+		return messages
+	}
+
+	last := &messages[len(messages)-1]
+	last.Parts = append(last.Parts, openai.TextPart(last.Content))
+	for _, image := range q.Images {
+		last.Parts = append(last.Parts, openai.ImagePart(image))
+	}
+	return messages
+}
+
 func (q *LLMQueue) NewItem(interaction *discordgo.Interaction, options ...func(*LLMItem)) *LLMItem {
 	item := q.DefaultQueueItem()
 	item.DiscordInteraction = interaction
@@ -50,12 +100,13 @@ func (q *LLMQueue) DefaultQueueItem() *LLMItem {
 		Type: ItemTypeInstruct,
 		Request: &llm.Request{
 			Messages:      messages,
-			Model:         LLama3,
+			Model:         q.endpoints[0].defaultModel(),
 			Temperature:   0.7,
 			MaxTokens:     1024,
 			Stream:        false,
 			StreamChannel: nil,
 		},
+		Endpoint:  q.endpoints[0],
 		Created:   time.Now(),
 		Interrupt: nil,
 	}
@@ -0,0 +1,293 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/api/openai"
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	// modelCacheDuration is how long the /v1/models listing is reused before it's fetched again.
+	modelCacheDuration = 5 * time.Minute
+	// autocompleteTimeout keeps the listing within the 3 seconds Discord gives to answer an autocomplete.
+	autocompleteTimeout = 2 * time.Second
+
+	// choiceLength is Discord's limit for the name and value of a choice.
+	choiceLength = 100
+)
+
+type modelCache struct {
+	mu      sync.Mutex
+	models  []string
+	fetched time.Time
+}
+
+// availableModels returns the models served by the endpoint, limited to the allowlist if one is set.
+// The listing is cached for modelCacheDuration. If the endpoint can't be reached, the last listing is used,
+// or the allowlist itself if there is none.
+func (e *Endpoint) availableModels(ctx context.Context) ([]string, error) {
+	e.models.mu.Lock()
+	defer e.models.mu.Unlock()
+
+	var err error
+	if e.models.models == nil || time.Since(e.models.fetched) > modelCacheDuration {
+		var models []string
+		models, err = openai.Models(ctx, e.host)
+		if err == nil {
+			e.models.models = models
+			e.models.fetched = time.Now()
+		}
+	}
+
+	if e.models.models == nil {
+		return e.Models, err
+	}
+
+	if len(e.Models) == 0 {
+		return e.models.models, err
+	}
+
+	var models []string
+	for _, model := range e.models.models {
+		if e.allowed(model) {
+			models = append(models, model)
+		}
+	}
+	return models, err
+}
+
+// allowed reports whether the model can be requested. Every model is allowed when there is no allowlist.
+func (e *Endpoint) allowed(model string) bool {
+	return len(e.Models) == 0 || slices.Contains(e.Models, model)
+}
+
+// defaultModel is the model used when neither the member nor the command picks one.
+func (e *Endpoint) defaultModel() string {
+	switch {
+	case e.DefaultModel != "":
+		return e.DefaultModel
+	case len(e.Models) > 0:
+		return e.Models[0]
+	default:
+		return LLama3
+	}
+}
+
+func (e *Endpoint) disallowedMessage(model string) string {
+	return "The model `" + model + "` is not allowed on `" + e.Name + "`. Choose one of: `" + strings.Join(e.Models, "`, `") + "`"
+}
+
+// memberDefaults returns the member's default endpoint and model, falling back to the bot defaults
+// for an endpoint that no longer exists or a model that is no longer allowed.
+func (q *LLMQueue) memberDefaults(memberID string) (*Endpoint, string) {
+	settings, err := q.getSettings(memberID)
+	if err != nil {
+		log.Printf("Error retrieving LLM settings for %s: %v", memberID, err)
+	}
+	if settings == nil {
+		return q.endpoints[0], q.endpoints[0].defaultModel()
+	}
+
+	endpoint, err := q.endpoint(settings.Endpoint)
+	if err != nil {
+		endpoint = q.endpoints[0]
+	}
+	return endpoint, q.memberModel(endpoint, settings)
+}
+
+// memberModel returns the model the member has set for the endpoint, otherwise the endpoint's default.
+func (q *LLMQueue) memberModel(endpoint *Endpoint, settings *entities.LLMSettings) string {
+	if settings == nil || settings.Model == "" || !endpoint.allowed(settings.Model) {
+		return endpoint.defaultModel()
+	}
+
+	saved, err := q.endpoint(settings.Endpoint)
+	if err != nil || saved != endpoint {
+		return endpoint.defaultModel()
+	}
+
+	return settings.Model
+}
+
+// commandEndpoint returns the endpoint picked in the command options, or the member's default endpoint,
+// along with the model to use on it.
+func (q *LLMQueue) commandEndpoint(memberID string, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*Endpoint, string, error) {
+	endpoint, model := q.memberDefaults(memberID)
+	if option, ok := optionMap[endpointOption]; ok {
+		var err error
+		endpoint, err = q.endpoint(option.StringValue())
+		if err != nil {
+			return nil, "", err
+		}
+
+		settings, _ := q.getSettings(memberID)
+		model = q.memberModel(endpoint, settings)
+	}
+
+	if option, ok := optionMap[llmModelOption]; ok {
+		model = option.StringValue()
+		if !endpoint.allowed(model) {
+			return nil, "", errors.New(endpoint.disallowedMessage(model))
+		}
+	}
+
+	return endpoint, model, nil
+}
+
+// getSettings returns the member's LLM defaults, or nil if they haven't set any.
+func (q *LLMQueue) getSettings(memberID string) (*entities.LLMSettings, error) {
+	if q.settingsRepo == nil {
+		return nil, nil
+	}
+
+	settings, err := q.settingsRepo.GetByMemberID(context.Background(), memberID)
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (q *LLMQueue) processLLMAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, opt := range i.ApplicationCommandData().Options {
+		if !opt.Focused {
+			continue
+		}
+
+		switch opt.Name {
+		case llmModelOption:
+			// the models depend on the endpoint picked in the same command, so the model itself is left out
+			delete(optionMap, llmModelOption)
+			endpoint, _, err := q.commandEndpoint(utils.GetUser(i.Interaction).ID, optionMap)
+			if err != nil {
+				break
+			}
+			choices = endpoint.modelChoices(opt.StringValue())
+		case personaOption:
+			choices = q.personaChoices(i.GuildID, opt.StringValue())
+		}
+	}
+
+	return autocomplete(s, i, choices)
+}
+
+// modelChoices returns the models of the endpoint that contain the input.
+func (e *Endpoint) modelChoices(input string) []*discordgo.ApplicationCommandOptionChoice {
+	ctx, cancel := context.WithTimeout(context.Background(), autocompleteTimeout)
+	models, err := e.availableModels(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("Error retrieving LLM models: %v", err)
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	input = strings.ToLower(input)
+	for _, model := range models {
+		if len(model) > choiceLength {
+			continue
+		}
+		if input != "" && !strings.Contains(strings.ToLower(model), input) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  model,
+			Value: model,
+		})
+	}
+	return choices
+}
+
+func autocomplete(s *discordgo.Session, i *discordgo.InteractionCreate, choices []*discordgo.ApplicationCommandOptionChoice) error {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices[:min(25, len(choices))],
+		},
+	})
+	return handlers.Wrap(err)
+}
+
+// processLLMSettingsCommand stores the given options as the member's defaults for /llm, then shows the current defaults.
+func (q *LLMQueue) processLLMSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	if q.settingsRepo == nil {
+		return handlers.ErrorEdit(s, i.Interaction, "LLM settings are not available.")
+	}
+
+	user := utils.GetUser(i.Interaction)
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	if option, ok := optionMap[resetOption]; ok && option.BoolValue() {
+		if err := q.settingsRepo.Delete(context.Background(), user.ID); err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error resetting your LLM settings.", err)
+		}
+
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, "Your LLM settings have been reset to the bot defaults.")
+		return err
+	}
+
+	settings, err := q.getSettings(user.ID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving your LLM settings.", err)
+	}
+	if settings == nil {
+		settings = &entities.LLMSettings{MemberID: user.ID}
+	}
+
+	if len(optionMap) > 0 {
+		endpoint, model, err := q.commandEndpoint(user.ID, optionMap)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, err)
+		}
+		// picking only an endpoint goes back to its default model
+		settings.Endpoint = endpoint.Name
+		settings.Model = ""
+		if _, ok := optionMap[llmModelOption]; ok {
+			settings.Model = model
+		}
+
+		settings, err = q.settingsRepo.Upsert(context.Background(), settings)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error saving your LLM settings.", err)
+		}
+		log.Printf("Updated LLM settings for %s: %+v", user.Username, settings)
+	}
+
+	endpoint, model := q.memberDefaults(user.ID)
+	if settings.Model == "" || settings.Model != model {
+		model += " (bot default)"
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction,
+		"Your defaults for the llm command:",
+		discordgo.MessageEmbed{
+			Title: "LLM Settings",
+			Fields: []*discordgo.MessageEmbedField{
+				{Name: "Endpoint", Value: "`" + endpoint.Name + "`", Inline: true},
+				{Name: "Model", Value: "`" + model + "`", Inline: true},
+			},
+		},
+	)
+	return err
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+)
+
+// fakeHost serves /v1/models like an OpenAI-compatible server and counts the requests made to it.
+func fakeHost(t *testing.T, requests *atomic.Int32) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"llama3"},{"id":"mistral"},{"id":"qwen2"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/v1/chat/completions"
+}
+
+func newQueue(t *testing.T, endpoints ...*Endpoint) *LLMQueue {
+	t.Helper()
+	q, err := New(Config{Endpoints: endpoints})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q.(*LLMQueue)
+}
+
+func TestAvailableModels(t *testing.T) {
+	var requests atomic.Int32
+	endpoint := &Endpoint{Name: "local", URL: fakeHost(t, &requests)}
+	newQueue(t, endpoint)
+
+	for range 3 {
+		models, err := endpoint.availableModels(context.Background())
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if want := []string{"llama3", "mistral", "qwen2"}; !slices.Equal(models, want) {
+			t.Errorf("Expected %v, got %v", want, models)
+		}
+	}
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected the listing to be cached after 1 request, got %d requests", n)
+	}
+}
+
+func TestAvailableModelsAllowlist(t *testing.T) {
+	var requests atomic.Int32
+	endpoint := &Endpoint{Name: "local", URL: fakeHost(t, &requests), Models: []string{"mistral", "qwen2", "offline"}}
+	q := newQueue(t, endpoint)
+
+	models, err := endpoint.availableModels(context.Background())
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if want := []string{"mistral", "qwen2"}; !slices.Equal(models, want) {
+		t.Errorf("Expected %v, got %v", want, models)
+	}
+
+	if endpoint.allowed("llama3") {
+		t.Error("Expected llama3 to not be allowed")
+	}
+	if model := endpoint.defaultModel(); model != "mistral" {
+		t.Errorf("Expected the default model to be the first allowed model, got %s", model)
+	}
+	if _, model := q.memberDefaults("member"); model != "mistral" {
+		t.Errorf("Expected members without settings to use the default model, got %s", model)
+	}
+}
+
+func TestAvailableModelsUnreachable(t *testing.T) {
+	endpoint := &Endpoint{Name: "offline", URL: "http://127.0.0.1:0/v1/chat/completions", Models: []string{"mistral"}}
+	newQueue(t, endpoint)
+
+	models, err := endpoint.availableModels(context.Background())
+	if err == nil {
+		t.Error("Expected an error for an unreachable host")
+	}
+	if want := []string{"mistral"}; !slices.Equal(models, want) {
+		t.Errorf("Expected the allowlist %v as a fallback, got %v", want, models)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	var requests atomic.Int32
+	local := &Endpoint{Name: "local", URL: fakeHost(t, &requests), DefaultModel: "llama3"}
+	hosted := &Endpoint{Name: "hosted", URL: "https://example.com/v1/chat/completions", APIKey: "sk-secret", Models: []string{"gpt-4o-mini"}, MaxTokens: 512}
+	q := newQueue(t, local, hosted)
+
+	if e, err := q.endpoint(""); err != nil || e != local {
+		t.Errorf("Expected the first endpoint to be the default, got %v, %v", e, err)
+	}
+	if _, err := q.endpoint("missing"); err == nil {
+		t.Error("Expected an error for an unknown endpoint")
+	}
+
+	request := &llm.Request{MaxTokens: -1}
+	hosted.limit(request)
+	if request.MaxTokens != 512 {
+		t.Errorf("Expected infinite tokens to be capped to 512, got %d", request.MaxTokens)
+	}
+	request.MaxTokens = 4096
+	local.limit(request)
+	if request.MaxTokens != 4096 {
+		t.Errorf("Expected endpoints without a cap to keep the requested tokens, got %d", request.MaxTokens)
+	}
+
+	if redacted := handlers.Redact("Authorization: Bearer sk-secret"); strings.Contains(redacted, "sk-secret") {
+		t.Errorf("Expected the API key to be redacted, got %s", redacted)
+	}
+
+	if _, err := New(Config{Endpoints: []*Endpoint{{Name: "local", URL: local.URL}, {Name: "local", URL: local.URL}}}); err == nil {
+		t.Error("Expected an error for duplicate endpoint names")
+	}
+}
@@ -0,0 +1,344 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+// personaPromptLength is the length of the system prompt shown when listing personas.
+const personaPromptLength = 200
+
+// commandPersona returns the persona picked in the command options, or the guild's default persona.
+// It returns nil without an error when no persona was picked and the guild has no default.
+func (q *LLMQueue) commandPersona(guildID string, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*entities.LLMPersona, error) {
+	option, picked := optionMap[personaOption]
+	if q.personaRepo == nil || guildID == "" {
+		if picked {
+			return nil, errors.New("personas can only be used in a server")
+		}
+		return nil, nil
+	}
+
+	if picked {
+		persona, err := q.personaRepo.GetByName(context.Background(), guildID, option.StringValue())
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return nil, fmt.Errorf("there is no persona named `%s`", option.StringValue())
+		}
+		return persona, err
+	}
+
+	persona, err := q.personaRepo.GetDefault(context.Background(), guildID)
+	if err != nil {
+		if !errors.Is(err, &repositories.NotFoundError{}) {
+			log.Printf("Error retrieving the default persona of %s: %v", guildID, err)
+		}
+		return nil, nil
+	}
+
+	return persona, nil
+}
+
+// applyPersona replaces the system prompt and settings of the item with the persona's.
+// The persona's model is only used if the member didn't pick one and the endpoint allows it.
+func applyPersona(item *LLMItem, persona *entities.LLMPersona, modelPicked bool) {
+	item.Persona = persona.Name
+	item.Request.Messages[0].Content = persona.SystemPrompt
+	item.Request.Temperature = persona.Temperature
+	item.Request.MaxTokens = persona.MaxTokens
+	if !modelPicked && persona.Model != "" && item.Endpoint.allowed(persona.Model) {
+		item.Request.Model = persona.Model
+	}
+}
+
+func (q *LLMQueue) personaChoices(guildID, input string) []*discordgo.ApplicationCommandOptionChoice {
+	if q.personaRepo == nil || guildID == "" {
+		return nil
+	}
+
+	personas, err := q.personaRepo.ListByGuildID(context.Background(), guildID)
+	if err != nil {
+		log.Printf("Error retrieving personas: %v", err)
+		return nil
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	input = strings.ToLower(input)
+	for _, persona := range personas {
+		if input != "" && !strings.Contains(strings.ToLower(persona.Name), input) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  persona.Name,
+			Value: persona.Name,
+		})
+	}
+	return choices
+}
+
+func (q *LLMQueue) processPersonaAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.ApplicationCommandData()
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	if len(data.Options) > 0 {
+		for _, opt := range data.Options[0].Options {
+			if !opt.Focused {
+				continue
+			}
+
+			switch opt.Name {
+			case personaNameOption:
+				choices = q.personaChoices(i.GuildID, opt.StringValue())
+			case llmModelOption:
+				endpoint, _ := q.memberDefaults(utils.GetUser(i.Interaction).ID)
+				choices = endpoint.modelChoices(opt.StringValue())
+			}
+		}
+	}
+
+	return autocomplete(s, i, choices)
+}
+
+func (q *LLMQueue) processPersonaCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	if q.personaRepo == nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Personas are not available.")
+	}
+	if i.GuildID == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "Personas can only be used in a server.")
+	}
+
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to pick a subcommand.")
+	}
+	subcommand := data.Options[0]
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(subcommand.Options))
+	for _, opt := range subcommand.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	switch subcommand.Name {
+	case personaCreate:
+		return q.createPersona(s, i, optionMap)
+	case personaEdit:
+		return q.editPersona(s, i, optionMap)
+	case personaDelete:
+		return q.deletePersona(s, i, optionMap)
+	case personaList:
+		return q.listPersonas(s, i)
+	case personaDefault:
+		return q.setDefaultPersona(s, i, optionMap)
+	}
+
+	return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("Unknown subcommand %s.", subcommand.Name))
+}
+
+// setPersonaOptions copies the given options onto the persona.
+func (q *LLMQueue) setPersonaOptions(persona *entities.LLMPersona, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	if option, ok := optionMap[systemPromptOption]; ok {
+		persona.SystemPrompt = option.StringValue()
+	}
+	if option, ok := optionMap[temperatureOption]; ok {
+		persona.Temperature = option.FloatValue()
+	}
+	if option, ok := optionMap[maxTokensOption]; ok {
+		persona.MaxTokens = option.IntValue()
+	}
+	if option, ok := optionMap[llmModelOption]; ok {
+		persona.Model = option.StringValue()
+		if persona.Model != "" && !q.modelAllowed(persona.Model) {
+			return fmt.Errorf("the model `%s` is not allowed on any endpoint", persona.Model)
+		}
+	}
+	return nil
+}
+
+// modelAllowed reports whether any endpoint allows the model.
+func (q *LLMQueue) modelAllowed(model string) bool {
+	for _, endpoint := range q.endpoints {
+		if endpoint.allowed(model) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *LLMQueue) createPersona(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	option, ok := optionMap[personaNameOption]
+	if !ok || strings.TrimSpace(option.StringValue()) == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a name.")
+	}
+	name := strings.TrimSpace(option.StringValue())
+
+	if _, err := q.personaRepo.GetByName(context.Background(), i.GuildID, name); err == nil {
+		return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("A persona named `%s` already exists.", name))
+	}
+
+	defaults := q.DefaultQueueItem().Request
+	persona := &entities.LLMPersona{
+		GuildID:      i.GuildID,
+		Name:         name,
+		SystemPrompt: defaults.Messages[0].Content,
+		Temperature:  defaults.Temperature,
+		MaxTokens:    defaults.MaxTokens,
+		CreatedBy:    utils.GetUser(i.Interaction).ID,
+	}
+	if err := q.setPersonaOptions(persona, optionMap); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	persona, err := q.personaRepo.Create(context.Background(), persona)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error saving the persona.", err)
+	}
+	log.Printf("Created persona %s in %s", persona.Name, persona.GuildID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, "Created the persona:", personaEmbed(persona))
+	return err
+}
+
+// managedPersona returns the persona named in the options if the member created it or can manage the guild.
+func (q *LLMQueue) managedPersona(i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*entities.LLMPersona, error) {
+	option, ok := optionMap[personaNameOption]
+	if !ok {
+		return nil, errors.New("you need to provide a name")
+	}
+
+	persona, err := q.personaRepo.GetByName(context.Background(), i.GuildID, option.StringValue())
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return nil, fmt.Errorf("there is no persona named `%s`", option.StringValue())
+		}
+		return nil, err
+	}
+
+	if persona.CreatedBy != utils.GetUser(i.Interaction).ID && !handlers.CanManageGuild(i.Interaction) {
+		return nil, errors.New("you can only change the personas you created")
+	}
+
+	return persona, nil
+}
+
+func (q *LLMQueue) editPersona(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	persona, err := q.managedPersona(i, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	if err := q.setPersonaOptions(persona, optionMap); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	persona, err = q.personaRepo.Update(context.Background(), persona)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error saving the persona.", err)
+	}
+	log.Printf("Updated persona %s in %s", persona.Name, persona.GuildID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, "Updated the persona:", personaEmbed(persona))
+	return err
+}
+
+func (q *LLMQueue) deletePersona(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	persona, err := q.managedPersona(i, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	if err := q.personaRepo.Delete(context.Background(), persona.GuildID, persona.Name); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error deleting the persona.", err)
+	}
+	log.Printf("Deleted persona %s in %s", persona.Name, persona.GuildID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, fmt.Sprintf("Deleted the persona `%s`.", persona.Name))
+	return err
+}
+
+func (q *LLMQueue) listPersonas(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	personas, err := q.personaRepo.ListByGuildID(context.Background(), i.GuildID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving the personas.", err)
+	}
+	if len(personas) == 0 {
+		_, err = handlers.EditInteractionResponse(s, i.Interaction, "There are no personas yet, create one with `/persona create`.")
+		return err
+	}
+
+	embed := discordgo.MessageEmbed{Title: "Personas"}
+	for _, persona := range personas[:min(25, len(personas))] {
+		name := persona.Name
+		if persona.IsDefault {
+			name += " (default)"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  name,
+			Value: fmt.Sprintf("%s\n-# %s", truncateText(persona.SystemPrompt, personaPromptLength), personaSettings(persona)),
+		})
+	}
+	if len(personas) > 25 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Showing 25 of %d personas", len(personas))}
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, embed)
+	return err
+}
+
+func (q *LLMQueue) setDefaultPersona(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	if !handlers.CanManageGuild(i.Interaction) {
+		return handlers.ErrorEdit(s, i.Interaction, "Only members who can manage the server can set the default persona.")
+	}
+
+	var name string
+	if option, ok := optionMap[personaNameOption]; ok {
+		name = option.StringValue()
+	}
+
+	if err := q.personaRepo.SetDefault(context.Background(), i.GuildID, name); err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("There is no persona named `%s`.", name))
+		}
+		return handlers.ErrorEdit(s, i.Interaction, "Error setting the default persona.", err)
+	}
+
+	content := "The server no longer has a default persona."
+	if name != "" {
+		content = fmt.Sprintf("`%s` is now the default persona of the server.", name)
+	}
+	log.Printf("Set the default persona of %s to %q", i.GuildID, name)
+
+	_, err := handlers.EditInteractionResponse(s, i.Interaction, content)
+	return err
+}
+
+func personaSettings(persona *entities.LLMPersona) string {
+	model := persona.Model
+	if model == "" {
+		model = "member default"
+	}
+	return fmt.Sprintf("Temperature `%.2f`, max tokens `%d`, model `%s`", persona.Temperature, persona.MaxTokens, model)
+}
+
+func personaEmbed(persona *entities.LLMPersona) discordgo.MessageEmbed {
+	return discordgo.MessageEmbed{
+		Title:       persona.Name,
+		Description: fmt.Sprintf("```\n%s\n```", truncateText(persona.SystemPrompt, 3900)),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Settings", Value: personaSettings(persona)},
+			{Name: "Created by", Value: fmt.Sprintf("<@%s>", persona.CreatedBy), Inline: true},
+			{Name: "Default", Value: fmt.Sprintf("`%t`", persona.IsDefault), Inline: true},
+		},
+	}
+}
@@ -15,7 +15,7 @@ func (q *LLMQueue) next() error {
 		}
 		select {
 		case q.current = <-q.queue:
-			if q.current.DiscordInteraction == nil {
+			if q.current.DiscordInteraction == nil && q.current.Type != ItemTypeFollowUp {
 				log.Panicf("DiscordInteraction is nil! Make sure to set it before adding to the queue. Example: queue.DiscordInteraction = i.Interaction\n%v", q.current)
 			}
 
@@ -31,6 +31,18 @@ func (q *LLMQueue) next() error {
 				if err != nil {
 					return fmt.Errorf("error processing current item: %w", err)
 				}
+			case ItemTypeEnhance:
+				q.processEnhance()
+			case ItemTypeAsk:
+				err := q.processAsk()
+				if err != nil {
+					return fmt.Errorf("error processing question: %w", err)
+				}
+			case ItemTypeFollowUp:
+				err := q.processFollowUp()
+				if err != nil {
+					return fmt.Errorf("error processing follow-up: %w", err)
+				}
 			default:
 				q.done()
 				return handlers.ErrorEdit(q.botSession, q.current.DiscordInteraction, fmt.Errorf("unknown item type: %s", q.current.Type))
@@ -41,7 +53,21 @@ func (q *LLMQueue) next() error {
 }
 
 func (q *LLMQueue) done() {
+	if q.current != nil {
+		q.release(q.current)
+	}
+
 	q.mu.Lock()
 	q.current = nil
 	q.mu.Unlock()
 }
+
+// release stops counting the item towards the queue limit of its member before it's done, as the queues share
+// the limit and the item hands its work to another queue, which counts it again.
+func (q *LLMQueue) release(item *LLMItem) {
+	if item.released {
+		return
+	}
+	item.released = true
+	q.guilds.Done(item.DiscordInteraction)
+}
@@ -2,39 +2,86 @@ package llm
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/ellypaws/inkbunny-sd/llm"
 
 	"stable_diffusion_bot/composite_renderer"
 	"stable_diffusion_bot/queue"
+	"stable_diffusion_bot/repositories/llm_conversations"
+	"stable_diffusion_bot/repositories/llm_personas"
+	"stable_diffusion_bot/repositories/llm_settings"
 )
 
-func New(host *llm.Config) queue.Queue[*LLMItem] {
-	if host == nil {
-		return nil
+type Config struct {
+	// Endpoints are the servers members can pick from, where the first one is the default.
+	Endpoints        []*Endpoint
+	ConversationRepo llm_conversations.Repository
+	SettingsRepo     llm_settings.Repository
+	PersonaRepo      llm_personas.Repository // optional, enables /persona
+	Guilds           *queue.Guilds           // optional, shared by the queues, enables the queue limit of each server
+	// CaptionModel is the vision model of the default endpoint used by Caption. Alt text is disabled if it's empty.
+	CaptionModel string
+}
+
+// New returns nil without an error if there are no endpoints, which disables the LLM commands.
+func New(cfg Config) (queue.Queue[*LLMItem], error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, nil
 	}
-	return &LLMQueue{
-		host:       host,
-		queue:      make(chan *LLMItem, 24),
-		cancelled:  make(map[string]bool),
-		compositor: composite_renderer.Compositor(),
+
+	names := make(map[string]bool, len(cfg.Endpoints))
+	for _, endpoint := range cfg.Endpoints {
+		if err := endpoint.init(); err != nil {
+			return nil, err
+		}
+		if names[endpoint.Name] {
+			return nil, fmt.Errorf("duplicate LLM endpoint %s", endpoint.Name)
+		}
+		names[endpoint.Name] = true
+	}
+
+	guilds := cfg.Guilds
+	if guilds == nil {
+		guilds = queue.NewGuilds(nil)
 	}
+
+	return &LLMQueue{
+		endpoints:        cfg.Endpoints,
+		conversationRepo: cfg.ConversationRepo,
+		settingsRepo:     cfg.SettingsRepo,
+		personaRepo:      cfg.PersonaRepo,
+		captionModel:     cfg.CaptionModel,
+		guilds:           guilds,
+		queue:            make(chan *LLMItem, 24),
+		cancelled:        make(map[string]bool),
+		asking:           make(map[string]*discordgo.Message),
+		compositor:       composite_renderer.Compositor(),
+	}, nil
 }
 
 type LLMQueue struct {
-	host *llm.Config
+	endpoints    []*Endpoint
+	tools        Tools
+	captionModel string
+
+	conversationRepo llm_conversations.Repository
+	settingsRepo     llm_settings.Repository
+	personaRepo      llm_personas.Repository
+	guilds           *queue.Guilds
 
 	botSession *discordgo.Session
 
 	queue     chan *LLMItem
 	current   *LLMItem
 	cancelled map[string]bool
-	mu        sync.Mutex
+	// asking holds the message each member is asking about while the question modal is open.
+	asking map[string]*discordgo.Message
+	mu     sync.Mutex
 
 	compositor composite_renderer.Renderer
 
@@ -72,6 +119,10 @@ func (q *LLMQueue) Add(item *LLMItem) (int, error) {
 		return -1, errors.New("queue is full")
 	}
 
+	if err := q.guilds.Wait(item.DiscordInteraction); err != nil {
+		return -1, err
+	}
+
 	q.queue <- item
 
 	return len(q.queue), nil
@@ -84,6 +135,12 @@ func (q *LLMQueue) Remove(messageInteraction *discordgo.MessageInteractionMetada
 	// Mark the item as cancelled
 	q.cancelled[messageInteraction.ID] = true
 
+	// Stop the stream if the item is already being processed
+	if q.current != nil && q.current.DiscordInteraction != nil && q.current.DiscordInteraction.ID == messageInteraction.ID && q.current.cancel != nil {
+		q.current.cancel()
+		delete(q.cancelled, messageInteraction.ID)
+	}
+
 	return nil
 }
 
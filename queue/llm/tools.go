@@ -0,0 +1,373 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/api/openai"
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	toolGenerateImage   = "generate_image"
+	toolListLoras       = "list_loras"
+	toolListCheckpoints = "list_checkpoints"
+	toolQueueStatus     = "get_queue_status"
+
+	// maxToolRounds is how many times the model can call tools before it has to answer.
+	maxToolRounds = 5
+	// maxToolImages is how many images a single reply can queue.
+	maxToolImages = 2
+	// maxListedModels keeps the lists of models from filling up the context.
+	maxListedModels = 50
+
+	// toolsLength is Discord's limit for the value of an embed field.
+	toolsLength = 1024
+	// toolTextLength is the length of the arguments and result of each call shown in the embed.
+	toolTextLength = 150
+)
+
+// ImageGenerator queues images for the generate_image tool, such as the stable diffusion and NovelAI queues.
+// The interaction is backed by a message in the conversation, see handlers.MessageInteraction,
+// which the generator responds to the same way it would respond to a command.
+type ImageGenerator interface {
+	GenerateImage(interaction *discordgo.Interaction, prompt, negativePrompt string) (int, error)
+	Pending() int
+}
+
+// ModelLister backs the list_loras and list_checkpoints tools.
+type ModelLister interface {
+	Loras() ([]string, error)
+	Checkpoints() ([]string, error)
+}
+
+type ImageBackend struct {
+	Name      string
+	Generator ImageGenerator
+}
+
+// Tools are the other queues the model can use when a member enables tools.
+type Tools struct {
+	// Backends can be picked with the backend argument of generate_image, where the first one is the default.
+	Backends []ImageBackend
+	// Models is optional, and enables list_loras and list_checkpoints.
+	Models ModelLister
+}
+
+// toolCall is a call made by the model, shown in the embed of the response.
+type toolCall struct {
+	Name      string
+	Arguments string
+	Result    string
+}
+
+// SetTools sets the queues used by the tools. It's separate from Config because the other queues are created
+// after the LLM queue, so that they can use it to enhance prompts.
+func (q *LLMQueue) SetTools(tools Tools) {
+	q.tools = tools
+}
+
+func function(name, description string, properties map[string]any, required ...string) openai.Tool {
+	if properties == nil {
+		properties = map[string]any{}
+	}
+	parameters := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		parameters["required"] = required
+	}
+
+	return openai.Tool{
+		Type: "function",
+		Function: openai.Function{
+			Name:        name,
+			Description: description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+func (q *LLMQueue) toolDefinitions() []openai.Tool {
+	var tools []openai.Tool
+
+	if len(q.tools.Backends) > 0 {
+		properties := map[string]any{
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "What the image should show, as a comma separated list of tags or a short description",
+			},
+			"negative_prompt": map[string]any{
+				"type":        "string",
+				"description": "What the image should not show",
+			},
+		}
+		if len(q.tools.Backends) > 1 {
+			names := make([]string, len(q.tools.Backends))
+			for i, backend := range q.tools.Backends {
+				names[i] = backend.Name
+			}
+			properties["backend"] = map[string]any{
+				"type":        "string",
+				"enum":        names,
+				"description": "The image generator to use, defaults to " + names[0],
+			}
+		}
+		tools = append(tools, function(toolGenerateImage,
+			fmt.Sprintf("Queue an image to be generated for the user. The image is posted in the channel once it's done. At most %d images can be queued per reply.", maxToolImages),
+			properties, "prompt"))
+	}
+
+	if q.tools.Models != nil {
+		search := map[string]any{
+			"search": map[string]any{
+				"type":        "string",
+				"description": "Only list the names containing this text",
+			},
+		}
+		tools = append(tools,
+			function(toolListLoras, "List the loras that can be added to a stable diffusion prompt as <lora:name:1>", search),
+			function(toolListCheckpoints, "List the stable diffusion checkpoints", search),
+		)
+	}
+
+	tools = append(tools, function(toolQueueStatus, "Get the number of requests waiting in each queue of the bot", nil))
+
+	return tools
+}
+
+// runTools answers the request in full, calling the tools the model asks for until it answers without any,
+// or until maxToolRounds is reached. The calls are recorded on the item, and onRound is called after each round.
+func (q *LLMQueue) runTools(ctx context.Context, item *LLMItem, onRound func()) (llm.Response, error) {
+	request := item.Request
+	chat := &openai.ChatRequest{
+		Model:       request.Model,
+		Messages:    item.chatMessages(),
+		Temperature: request.Temperature,
+		MaxTokens:   request.MaxTokens,
+		Tools:       q.toolDefinitions(),
+	}
+
+	for round := 0; ; round++ {
+		if round == maxToolRounds {
+			chat.Tools = nil
+		}
+
+		response, err := openai.Chat(ctx, item.Endpoint.host, chat)
+		if err != nil {
+			return llm.Response{}, err
+		}
+		if len(response.Choices) == 0 {
+			return llm.Response{}, errors.New("LLM response was invalid")
+		}
+
+		choice := response.Choices[0]
+		if len(choice.Message.ToolCalls) == 0 || chat.Tools == nil {
+			return llm.Response{
+				ID:    response.ID,
+				Model: response.Model,
+				Choices: []llm.Choice{{
+					Index:        int64(choice.Index),
+					Message:      llm.Message{Role: llm.AssistantRole, Content: choice.Message.Content},
+					FinishReason: choice.FinishReason,
+				}},
+				Usage: response.Usage,
+			}, nil
+		}
+
+		chat.Messages = append(chat.Messages, choice.Message)
+		for _, call := range choice.Message.ToolCalls {
+			result := q.callTool(item, call.Function)
+			log.Printf("LLM called %s(%s): %s", call.Function.Name, call.Function.Arguments, result)
+
+			item.toolCalls = append(item.toolCalls, toolCall{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+				Result:    result,
+			})
+			chat.Messages = append(chat.Messages, openai.Message{
+				Role:       openai.ToolRole,
+				Content:    result,
+				ToolCallID: call.ID,
+			})
+		}
+
+		if onRound != nil {
+			onRound()
+		}
+	}
+}
+
+// callTool runs the call and returns the result for the model. Errors are returned as results too,
+// so that the model can correct itself or explain the problem.
+func (q *LLMQueue) callTool(item *LLMItem, call openai.FunctionCall) string {
+	var arguments struct {
+		Prompt         string `json:"prompt"`
+		NegativePrompt string `json:"negative_prompt"`
+		Backend        string `json:"backend"`
+		Search         string `json:"search"`
+	}
+	if call.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &arguments); err != nil {
+			return fmt.Sprintf("Error: the arguments are not valid JSON: %v", err)
+		}
+	}
+
+	switch call.Name {
+	case toolGenerateImage:
+		if len(q.tools.Backends) == 0 {
+			break
+		}
+		if strings.TrimSpace(arguments.Prompt) == "" {
+			return "Error: prompt is required"
+		}
+		if item.images >= maxToolImages {
+			return fmt.Sprintf("Error: only %d images can be queued per reply", maxToolImages)
+		}
+
+		backend := q.tools.Backends[0]
+		if arguments.Backend != "" {
+			var ok bool
+			backend, ok = q.backend(arguments.Backend)
+			if !ok {
+				return fmt.Sprintf("Error: unknown backend %s", arguments.Backend)
+			}
+		}
+
+		position, err := q.queueImage(item, backend, arguments.Prompt, arguments.NegativePrompt)
+		if err != nil {
+			return fmt.Sprintf("Error: could not queue the image on %s: %v", backend.Name, err)
+		}
+		item.images++
+		return fmt.Sprintf("Queued on %s at position #%d. The image will be posted in the channel when it's done.", backend.Name, position)
+	case toolListLoras:
+		if q.tools.Models == nil {
+			break
+		}
+		return listModels("loras", arguments.Search, q.tools.Models.Loras)
+	case toolListCheckpoints:
+		if q.tools.Models == nil {
+			break
+		}
+		return listModels("checkpoints", arguments.Search, q.tools.Models.Checkpoints)
+	case toolQueueStatus:
+		status := []string{fmt.Sprintf("llm: %d pending", len(q.queue)+1)}
+		for _, backend := range q.tools.Backends {
+			status = append(status, fmt.Sprintf("%s: %d pending", backend.Name, backend.Generator.Pending()))
+		}
+		return strings.Join(status, ", ")
+	}
+
+	return fmt.Sprintf("Error: unknown tool %s", call.Name)
+}
+
+func (q *LLMQueue) backend(name string) (ImageBackend, bool) {
+	for _, backend := range q.tools.Backends {
+		if backend.Name == name {
+			return backend, true
+		}
+	}
+	return ImageBackend{}, false
+}
+
+func listModels(kind, search string, list func() ([]string, error)) string {
+	models, err := list()
+	if err != nil {
+		return fmt.Sprintf("Error: could not list the %s: %v", kind, err)
+	}
+
+	search = strings.ToLower(search)
+	var found []string
+	for _, model := range models {
+		if search == "" || strings.Contains(strings.ToLower(model), search) {
+			found = append(found, model)
+		}
+	}
+
+	if len(found) == 0 {
+		return fmt.Sprintf("No %s found", kind)
+	}
+	if len(found) > maxListedModels {
+		return fmt.Sprintf("Found %d %s, showing the first %d: %s", len(found), kind, maxListedModels, strings.Join(found[:maxListedModels], ", "))
+	}
+	return fmt.Sprintf("Found %d %s: %s", len(found), kind, strings.Join(found, ", "))
+}
+
+// queueImage sends a message in the channel of the item, then queues the image on behalf of the member who
+// invoked the item. The message is edited by the backend like the response to an /imagine.
+// The item no longer counts towards the queue limit, so that the image isn't refused for it.
+func (q *LLMQueue) queueImage(item *LLMItem, backend ImageBackend, prompt, negativePrompt string) (int, error) {
+	q.release(item)
+
+	channelID, from := item.invoker()
+	content := fmt.Sprintf("<@%s> the LLM asked me to imagine \n```\n%s\n```", utils.GetUser(from).ID, prompt)
+	interaction, err := handlers.MessageInteraction(q.botSession, channelID, from, LLMCommand, content)
+	if err != nil {
+		return 0, fmt.Errorf("error sending the message for the image: %w", err)
+	}
+
+	position, err := backend.Generator.GenerateImage(interaction, prompt, negativePrompt)
+	if err != nil {
+		if err := handlers.ErrorEdit(q.botSession, interaction, "Error adding the image to the queue.", err); err != nil {
+			log.Printf("Error responding to an LLM image: %v", err)
+		}
+		return 0, err
+	}
+
+	return position, nil
+}
+
+// invoker returns the channel of the item and the member who invoked it, which is the author of the reply
+// for ItemTypeFollowUp. The channel is the thread of the conversation once it's opened.
+func (item *LLMItem) invoker() (string, *discordgo.Interaction) {
+	if item.DiscordInteraction == nil && item.Message != nil {
+		return item.Message.ChannelID, messageAuthor(item.Message)
+	}
+	if item.thread != nil {
+		return item.thread.ID, item.DiscordInteraction
+	}
+	return item.DiscordInteraction.ChannelID, item.DiscordInteraction
+}
+
+// setTools lists the tool calls in the embed. The field is added on the first call and updated on later calls.
+func setTools(embed *discordgo.MessageEmbed, calls []toolCall) {
+	var value strings.Builder
+	for _, call := range calls {
+		line := fmt.Sprintf("`%s(%s)` → %s\n", call.Name, truncateText(call.Arguments, toolTextLength), truncateText(call.Result, toolTextLength))
+		if value.Len()+len(line) > toolsLength-len("...") {
+			value.WriteString("...")
+			break
+		}
+		value.WriteString(line)
+	}
+
+	for _, field := range embed.Fields {
+		if field.Name == "Tools" {
+			field.Value = value.String()
+			return
+		}
+	}
+
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:   "Tools",
+		Value:  value.String(),
+		Inline: false,
+	})
+}
+
+func truncateText(text string, length int) string {
+	text = strings.ReplaceAll(text, "`", "'")
+	if len(text) <= length {
+		return text
+	}
+	return strings.ToValidUTF8(text[:length], "") + "..."
+}
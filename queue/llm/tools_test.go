@@ -0,0 +1,236 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/api/openai"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
+	"stable_diffusion_bot/repositories/guild_settings"
+)
+
+type fakeGenerator struct{ pending int }
+
+func (g *fakeGenerator) GenerateImage(*discordgo.Interaction, string, string) (int, error) {
+	g.pending++
+	return g.pending, nil
+}
+
+func (g *fakeGenerator) Pending() int { return g.pending }
+
+// limitedGenerator counts its images towards the queue limit, like the queues sharing the Guilds of the LLM queue.
+type limitedGenerator struct{ guilds *queue.Guilds }
+
+func (g limitedGenerator) GenerateImage(interaction *discordgo.Interaction, _, _ string) (int, error) {
+	if err := g.guilds.Wait(interaction); err != nil {
+		return -1, err
+	}
+	return 1, nil
+}
+
+func (limitedGenerator) Pending() int { return 1 }
+
+// fakeGuildSettings only returns the settings of the server it holds.
+type fakeGuildSettings struct {
+	guild_settings.Repository
+	settings *entities.GuildSettings
+}
+
+func (f fakeGuildSettings) GetByGuildID(context.Context, string) (*entities.GuildSettings, error) {
+	return f.settings, nil
+}
+
+type fakeModels struct{}
+
+func (fakeModels) Loras() ([]string, error) { return []string{"add_detail", "pixel_art", "lowra"}, nil }
+
+func (fakeModels) Checkpoints() ([]string, error) { return []string{"sd_xl_base_1.0.safetensors"}, nil }
+
+// fakeChat answers the first request with the given tool calls, and every request after that with a final answer.
+func fakeChat(t *testing.T, calls ...openai.ToolCall) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request openai.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Expected a chat request, got %v", err)
+		}
+
+		message := openai.Message{Role: "assistant", Content: "Here you go"}
+		if last := request.Messages[len(request.Messages)-1]; last.Role != openai.ToolRole {
+			message = openai.Message{Role: "assistant", ToolCalls: calls}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatResponse{
+			Model:   request.Model,
+			Choices: []openai.Choice{{Message: message, FinishReason: "stop"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/v1/chat/completions"
+}
+
+// fakeDiscord answers the messages sent to channels, recording the users each message is allowed to mention.
+func fakeDiscord(t *testing.T) (*discordgo.Session, *[][]string) {
+	t.Helper()
+	var mentions [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var send discordgo.MessageSend
+		if err := json.NewDecoder(r.Body).Decode(&send); err != nil {
+			t.Errorf("Expected a message, got %v", err)
+		}
+		var users []string
+		if send.AllowedMentions != nil {
+			users = send.AllowedMentions.Users
+		}
+		mentions = append(mentions, users)
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(discordgo.Message{ID: "message", Content: send.Content})
+	}))
+	t.Cleanup(server.Close)
+
+	endpoint := discordgo.EndpointChannelMessages
+	discordgo.EndpointChannelMessages = func(channelID string) string { return server.URL + "/channels/" + channelID + "/messages" }
+	t.Cleanup(func() { discordgo.EndpointChannelMessages = endpoint })
+
+	session, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session, &mentions
+}
+
+func call(id, name, arguments string) openai.ToolCall {
+	return openai.ToolCall{ID: id, Type: "function", Function: openai.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func TestRunTools(t *testing.T) {
+	endpoint := &Endpoint{Name: "local", URL: fakeChat(t,
+		call("1", toolGenerateImage, `{"prompt":"a cat"}`),
+		call("2", toolGenerateImage, `{"prompt":"a dog","backend":"novelai"}`),
+		call("3", toolGenerateImage, `{"prompt":"a bird"}`),
+		call("4", toolListLoras, `{"search":"DETAIL"}`),
+		call("5", toolQueueStatus, ``),
+	)}
+	q := newQueue(t, endpoint)
+	q.SetTools(Tools{
+		Backends: []ImageBackend{
+			{Name: "stable_diffusion", Generator: &fakeGenerator{}},
+			{Name: "novelai", Generator: &fakeGenerator{pending: 3}},
+		},
+		Models: fakeModels{},
+	})
+
+	var mentions *[][]string
+	q.botSession, mentions = fakeDiscord(t)
+
+	item := q.NewItem(&discordgo.Interaction{
+		ChannelID: "channel",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "invoker"}},
+	}, WithPrompt("draw a cat and a dog"))
+	item.Tools = true
+
+	var rounds int
+	response, err := q.runTools(context.Background(), item, func() { rounds++ })
+	if err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if content := response.Choices[0].Message.Content; content != "Here you go" {
+		t.Errorf("Expected the final answer, got %q", content)
+	}
+	if rounds != 1 {
+		t.Errorf("Expected 1 round of tool calls, got %d", rounds)
+	}
+
+	if item.images != maxToolImages {
+		t.Fatalf("Expected %d images to be queued, got %d", maxToolImages, item.images)
+	}
+	if len(*mentions) != maxToolImages {
+		t.Fatalf("Expected a message for each image, got %d", len(*mentions))
+	}
+	for _, users := range *mentions {
+		if len(users) != 1 || users[0] != "invoker" {
+			t.Errorf("Expected the messages to only mention the invoker, got %v", users)
+		}
+	}
+
+	results := make([]string, len(item.toolCalls))
+	for i, call := range item.toolCalls {
+		results[i] = call.Result
+	}
+	if want := "Queued on stable_diffusion at position #1"; !strings.HasPrefix(results[0], want) {
+		t.Errorf("Expected %q, got %q", want, results[0])
+	}
+	if want := "Queued on novelai at position #4"; !strings.HasPrefix(results[1], want) {
+		t.Errorf("Expected %q, got %q", want, results[1])
+	}
+	if !strings.HasPrefix(results[2], "Error:") {
+		t.Errorf("Expected the third image to be refused, got %s", results[2])
+	}
+	if want := "Found 1 loras: add_detail"; results[3] != want {
+		t.Errorf("Expected %q, got %q", want, results[3])
+	}
+	if !strings.Contains(results[4], "novelai: 4 pending") {
+		t.Errorf("Expected the queue status to include novelai, got %s", results[4])
+	}
+
+	embed := &discordgo.MessageEmbed{}
+	setTools(embed, item.toolCalls)
+	if len(embed.Fields) != 1 || len(embed.Fields[0].Value) > toolsLength {
+		t.Errorf("Expected a single Tools field within %d characters, got %v", toolsLength, embed.Fields)
+	}
+}
+
+func TestQueueImageReleasesItem(t *testing.T) {
+	q := newQueue(t, &Endpoint{Name: "local", URL: "http://localhost"})
+	q.guilds = queue.NewGuilds(fakeGuildSettings{settings: &entities.GuildSettings{GuildID: "guild", QueueLimit: 1}})
+	q.botSession, _ = fakeDiscord(t)
+	backend := ImageBackend{Name: "stable_diffusion", Generator: limitedGenerator{guilds: q.guilds}}
+
+	invoker := &discordgo.Interaction{
+		GuildID:   "guild",
+		ChannelID: "channel",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "invoker"}},
+	}
+	item := q.NewItem(invoker, WithPrompt("draw a cat"))
+	if _, err := q.Add(item); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	q.current = <-q.queue
+
+	if _, err := q.queueImage(item, backend, "a cat", ""); err != nil {
+		t.Fatalf("Expected the image to take the place of the item in the queue, got %v", err)
+	}
+
+	q.done()
+	if err := q.guilds.Wait(invoker); err == nil {
+		t.Error("Expected the image to still count towards the queue limit once the item is done")
+	}
+}
+
+func TestInvokerThread(t *testing.T) {
+	q := newQueue(t, &Endpoint{Name: "local", URL: "http://localhost"})
+	item := q.NewItem(&discordgo.Interaction{
+		ChannelID: "channel",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "invoker"}},
+	}, WithPrompt("draw a cat"))
+	item.Thread = true
+
+	if channelID, _ := item.invoker(); channelID != "channel" {
+		t.Errorf("Expected the images to be posted in the channel before the thread is opened, got %s", channelID)
+	}
+
+	item.thread = &discordgo.Channel{ID: "thread"}
+	if channelID, from := item.invoker(); channelID != "thread" || from != item.DiscordInteraction {
+		t.Errorf("Expected the images of the invoker to be posted in the thread, got %s", channelID)
+	}
+}
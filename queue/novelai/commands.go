@@ -28,12 +28,54 @@ func (q *NAIQueue) commands() []*discordgo.ApplicationCommand {
 				commandOptions[novelaiVibeTransfer],
 				commandOptions[novelaiInformation],
 				commandOptions[novelaiReference],
+				commandOptions[novelaiVibeTransfer2],
+				commandOptions[novelaiInformation2],
+				commandOptions[novelaiReference2],
+				commandOptions[novelaiVibeSet],
+				commandOptions[novelaiSaveVibeSet],
 				commandOptions[img2imgOption],
 				commandOptions[novelaiImg2ImgStr],
 				commandOptions[novelaiSMEAOption],
 				commandOptions[novelaiSMEADynOption],
 			},
 		},
+		{
+			Name:        NovelAISettingsCommand,
+			Description: "Change your default settings for the novelai command",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				commandOptions[novelaiModelOption],
+				commandOptions[novelaiSizeOption],
+				commandOptions[novelaiSamplerOption],
+				commandOptions[novelaiScheduleOption],
+				commandOptions[novelaiUCPresetOption],
+				commandOptions[novelaiQualityOption],
+				commandOptions[cfgScaleOption],
+				commandOptions[stepOption],
+				commandOptions[novelaiResetOption],
+			},
+		},
+		{
+			Name:        NovelAIRawCommand,
+			Description: "Send a raw NovelAI request as JSON",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				commandOptions[novelaiJSONFile],
+				commandOptions[novelaiUseDefaults],
+			},
+		},
+		{
+			Name:        NovelAIInfoCommand,
+			Description: "Show the NovelAI generation parameters of an image",
+			Type:        discordgo.ChatApplicationCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				commandOptions[novelaiInfoImage],
+			},
+		},
+		{
+			Name: NovelAIInfoMessage,
+			Type: discordgo.MessageApplicationCommand,
+		},
 	}
 }
 
@@ -53,7 +95,7 @@ var commandOptions = map[string]*discordgo.ApplicationCommandOption{
 	stepOption: {
 		Type:        discordgo.ApplicationCommandOptionInteger,
 		Name:        stepOption,
-		Description: "Number of iterations to sample with. Default is 20",
+		Description: "Number of iterations to sample with. Default is 28",
 		Required:    false,
 	},
 	seedOption: {
@@ -310,6 +352,62 @@ var commandOptions = map[string]*discordgo.ApplicationCommandOption{
 		Description: "The strength of the reference. Default is 0.6",
 		Required:    false,
 	},
+	novelaiVibeTransfer2: {
+		Type:        discordgo.ApplicationCommandOptionAttachment,
+		Name:        novelaiVibeTransfer2,
+		Description: "Attach a second image to use as input for vibe transfer",
+		Required:    false,
+	},
+	novelaiInformation2: {
+		Type:        discordgo.ApplicationCommandOptionNumber,
+		Name:        novelaiInformation2,
+		Description: "The amount of information to extract from the second image. Default is 1.0",
+		Required:    false,
+	},
+	novelaiReference2: {
+		Type:        discordgo.ApplicationCommandOptionNumber,
+		Name:        novelaiReference2,
+		Description: "The strength of the second reference. Default is 0.6",
+		Required:    false,
+	},
+	novelaiVibeSet: {
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         novelaiVibeSet,
+		Description:  "Reuse a saved set of vibe transfer references. Attached images are added to the set",
+		Required:     false,
+		Autocomplete: true,
+	},
+	novelaiSaveVibeSet: {
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        novelaiSaveVibeSet,
+		Description: "Save all vibe transfer references of this generation under a name for later use",
+		Required:    false,
+		MaxLength:   100,
+	},
+	novelaiResetOption: {
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        novelaiResetOption,
+		Description: "Reset your settings to the bot defaults",
+		Required:    false,
+	},
+	novelaiJSONFile: {
+		Type:        discordgo.ApplicationCommandOptionAttachment,
+		Name:        novelaiJSONFile,
+		Description: "The json file to use for the raw command. If not specified, a modal will be opened to paste the json",
+		Required:    false,
+	},
+	novelaiUseDefaults: {
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        novelaiUseDefaults,
+		Description: "Merge the json with your default settings. This is set to True by default",
+		Required:    false,
+	},
+	novelaiInfoImage: {
+		Type:        discordgo.ApplicationCommandOptionAttachment,
+		Name:        novelaiInfoImage,
+		Description: "The NovelAI image to read the generation parameters from",
+		Required:    true,
+	},
 	novelaiImg2ImgStr: {
 		Type:        discordgo.ApplicationCommandOptionNumber,
 		Name:        novelaiImg2ImgStr,
@@ -1,19 +1,37 @@
 package novelai
 
 import (
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/bwmarrin/discordgo"
 
 	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/utils"
 )
 
 type Handler = func(*discordgo.Session, *discordgo.InteractionCreate) error
 
 const (
-	prefix = "novelai_"
-	cancel = prefix + "cancel"
+	prefix    = "novelai_"
+	cancel    = prefix + "cancel"
+	reroll    = prefix + "reroll"
+	variation = prefix + "variation"
+	upscale   = prefix + "upscale"
+	requeue   = prefix + "requeue"
+
+	rawModal         = prefix + "raw_modal"
+	rawModalDefaults = prefix + "raw_modal_defaults"
+	rawInput         = prefix + "raw_input"
+)
+
+const (
+	variationStrength = 0.35
+	upscaleFactor     = 4
 )
 
 var components = map[string]discordgo.MessageComponent{
@@ -26,27 +44,238 @@ var components = map[string]discordgo.MessageComponent{
 			},
 		},
 	},
+	requeue: discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Generate",
+				Style:    discordgo.PrimaryButton,
+				CustomID: requeue,
+				Emoji: &discordgo.ComponentEmoji{
+					Name: "🎨",
+				},
+			},
+		},
+	},
+	rawInput: discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.TextInput{
+				CustomID:    rawInput,
+				Label:       "JSON blob",
+				Style:       discordgo.TextInputParagraph,
+				Placeholder: "{\"input\":\"1girl\",\"parameters\":{\"cfg_rescale\":0.2,\"skip_cfg_above_sigma\":19}}",
+				Required:    true,
+				MinLength:   1,
+				MaxLength:   4000,
+			},
+		},
+	},
+}
+
+// CommandOf returns the command the component or modal belongs to, see queue.CommandOwner.
+func (q *NAIQueue) CommandOf(customID string) (queue.Command, bool) {
+	switch {
+	case customID == rawModal || customID == rawModalDefaults:
+		return NovelAIRawCommand, true
+	case strings.HasPrefix(customID, prefix):
+		return NovelAICommand, true
+	default:
+		return "", false
+	}
 }
 
 func (q *NAIQueue) components() map[string]Handler {
-	return map[string]Handler{
-		cancel: q.removeImagineFromQueue,
+	h := map[string]Handler{
+		cancel:  q.removeImagineFromQueue,
+		reroll:  q.processReroll,
+		requeue: q.processRequeue,
+	}
+
+	for i := range 4 {
+		h[variation+"_"+strconv.Itoa(i+1)] = q.variationComponentHandler
+		h[upscale+"_"+strconv.Itoa(i+1)] = q.upscaleComponentHandler
+	}
+
+	return h
+}
+
+// rerollVariationComponents returns the variation and re-roll buttons on the first row, and the upscale and delete buttons on the second.
+// When amount is 0, only the re-roll and delete buttons are shown.
+func rerollVariationComponents(amount int) *[]discordgo.MessageComponent {
+	amount = min(amount, 4)
+
+	var firstRow []discordgo.MessageComponent
+	for i := 1; i <= amount; i++ {
+		firstRow = append(firstRow, discordgo.Button{
+			Label:    fmt.Sprintf("%d", i),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%v_%d", variation, i),
+			Emoji: &discordgo.ComponentEmoji{
+				Name: "♻️",
+			},
+		})
+	}
+
+	firstRow = append(firstRow, discordgo.Button{
+		Label:    "Re-roll",
+		Style:    discordgo.PrimaryButton,
+		CustomID: reroll,
+		Emoji: &discordgo.ComponentEmoji{
+			Name: "🎲",
+		},
+	})
+
+	var secondRow []discordgo.MessageComponent
+	for i := 1; i <= amount; i++ {
+		secondRow = append(secondRow, discordgo.Button{
+			Label:    fmt.Sprintf("%d", i),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%v_%d", upscale, i),
+			Emoji: &discordgo.ComponentEmoji{
+				Name: "⬆️",
+			},
+		})
+	}
+
+	secondRow = append(secondRow, discordgo.Button{
+		Label:    "Delete",
+		Style:    discordgo.DangerButton,
+		CustomID: handlers.DeleteGeneration,
+		Emoji: &discordgo.ComponentEmoji{
+			Name: "🗑️",
+		},
+	})
+
+	return &[]discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: firstRow},
+		discordgo.ActionsRow{Components: secondRow},
 	}
 }
 
 func (q *NAIQueue) removeImagineFromQueue(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if utils.GetUser(i.Interaction).ID != i.Message.InteractionMetadata.User.ID {
+	metadata := handlers.MessageInteractionMetadata(i.Message)
+	if metadata == nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Unable to determine who started this generation")
+	}
+	if utils.GetUser(i.Interaction).ID != metadata.User.ID {
 		return handlers.ErrorEphemeral(s, i.Interaction, "You can only cancel your own generations")
 	}
 
-	log.Printf("Removing imagine from queue: %#v", i.Message.InteractionMetadata)
+	log.Printf("Removing imagine from queue: %#v", metadata)
 
-	err := q.Remove(i.Message.InteractionMetadata)
+	err := q.Remove(metadata)
 	if err != nil {
 		log.Printf("Error removing imagine from queue: %v", err)
 		return handlers.ErrorEdit(s, i.Interaction, "Error removing imagine from queue")
 	}
-	log.Printf("Removed imagine from queue: %#v", i.Message.InteractionMetadata)
+	log.Printf("Removed imagine from queue: %#v", metadata)
 
 	return handlers.UpdateFromComponent(s, i.Interaction, "Generation cancelled", handlers.Components[handlers.DeleteButton])
 }
+
+func (q *NAIQueue) variationComponentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, err := strconv.Atoi(strings.TrimPrefix(i.MessageComponentData().CustomID, variation+"_"))
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "error parsing interaction index", err)
+	}
+
+	return q.processVariation(s, i, index)
+}
+
+func (q *NAIQueue) upscaleComponentHandler(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, err := strconv.Atoi(strings.TrimPrefix(i.MessageComponentData().CustomID, upscale+"_"))
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "error parsing interaction index", err)
+	}
+
+	return q.processUpscale(s, i, index)
+}
+
+// processReroll generates the first image of the message again with a new seed.
+// Image payloads are not stored, so vibe transfer and img2img generations are rerolled as text to image.
+func (q *NAIQueue) processReroll(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	generation, err := q.getGeneration(i.Message.ID, 1)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the original generation.", err)
+	}
+
+	request := *generation.Request
+	request.Action = entities.ActionGenerate
+	request.Parameters.Seed = 0
+
+	item := q.NewItem(i.Interaction, WithRequest(&request))
+	item.Type = ItemTypeReroll
+
+	return q.addToQueue(s, i, item)
+}
+
+// processVariation runs img2img on the chosen image at a low strength.
+func (q *NAIQueue) processVariation(s *discordgo.Session, i *discordgo.InteractionCreate, index int) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	generation, err := q.getGeneration(i.Message.ID, index)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the original generation.", err)
+	}
+
+	image, err := messageImage(i.Message, index)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the image to use.", err)
+	}
+
+	request := *generation.Request
+	request.Action = entities.ActionImg2Img
+	request.Parameters.Img2Img = image
+	request.Parameters.Strength = variationStrength
+	request.Parameters.Seed = 0
+	request.Parameters.ImageCount = 1
+
+	item := q.NewItem(i.Interaction, WithRequest(&request))
+	item.Type = ItemTypeVariation
+
+	return q.addToQueue(s, i, item)
+}
+
+func (q *NAIQueue) processUpscale(s *discordgo.Session, i *discordgo.InteractionCreate, index int) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	generation, err := q.getGeneration(i.Message.ID, index)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the original generation.", err)
+	}
+
+	image, err := messageImage(i.Message, index)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the image to upscale.", err)
+	}
+
+	request := *generation.Request
+	item := q.NewItem(i.Interaction, WithRequest(&request))
+	item.Type = ItemTypeUpscale
+	item.Image = image
+
+	return q.addToQueue(s, i, item)
+}
+
+// messageImage downloads the image at index (starting from 1) that utils.EmbedImages attached to the message.
+func messageImage(message *discordgo.Message, index int) (*utils.Image, error) {
+	if message == nil {
+		return nil, fmt.Errorf("message is nil")
+	}
+
+	suffix := fmt.Sprintf("-%d.png", index-1)
+	for _, attachment := range message.Attachments {
+		if strings.HasSuffix(attachment.Filename, suffix) {
+			return utils.AsyncImage(attachment.URL), nil
+		}
+	}
+
+	return nil, fmt.Errorf("image #%d not found in message %s", index, message.ID)
+}
@@ -0,0 +1,69 @@
+package novelai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ellypaws/novelai-metadata/pkg/meta"
+
+	"stable_diffusion_bot/entities"
+)
+
+// submittedRequest returns a copy of the request as the user submitted it, without any image payloads.
+// It should be called before the request is sent, as entities.NovelAIRequest.Init modifies the prompt.
+func submittedRequest(request *entities.NovelAIRequest) *entities.NovelAIRequest {
+	submitted := *request
+	submitted.Parameters.Img2Img = nil
+	submitted.Parameters.VibeTransferImage = nil
+	submitted.Parameters.ReferenceImageMultiple = nil
+	submitted.Parameters.ReferenceInformationExtractedMultiple = nil
+	submitted.Parameters.ReferenceStrengthMultiple = nil
+	return &submitted
+}
+
+// recordGenerations stores one generation per image with the seed that was used for it.
+// The seed is read from the image metadata, or derived from the request's seed if the metadata is missing.
+func (q *NAIQueue) recordGenerations(item *NAIQueueItem, submitted *entities.NovelAIRequest, message *discordgo.Message, metadata []*meta.Metadata) error {
+	if q.generationRepo == nil {
+		return nil
+	}
+
+	if message == nil {
+		return fmt.Errorf("cannot record generation for %v without a message", item.DiscordInteraction.ID)
+	}
+
+	var errs []error
+	for idx, data := range metadata {
+		request := *submitted
+		request.Parameters.Seed = item.Request.Parameters.Seed + int64(idx)
+		if data != nil && data.Comment != nil {
+			request.Parameters.Seed = data.Comment.Seed
+		}
+
+		_, err := q.generationRepo.Create(context.Background(), &entities.NovelAIGeneration{
+			InteractionID: item.DiscordInteraction.ID,
+			MessageID:     message.ID,
+			MemberID:      item.user.ID,
+			SortOrder:     idx + 1,
+			Request:       &request,
+			Metadata:      data,
+			CreatedAt:     item.Created,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error recording generation #%d: %w", idx+1, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// getGeneration returns the generation of the image at sortOrder, starting from 1.
+func (q *NAIQueue) getGeneration(messageID string, sortOrder int) (*entities.NovelAIGeneration, error) {
+	if q.generationRepo == nil {
+		return nil, errors.New("novelai generations are not being stored")
+	}
+
+	return q.generationRepo.GetByMessageAndSort(context.Background(), messageID, sortOrder)
+}
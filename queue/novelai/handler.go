@@ -12,7 +12,13 @@ import (
 	"stable_diffusion_bot/utils"
 )
 
-const NovelAICommand = "novelai"
+const (
+	NovelAICommand         = "novelai"
+	NovelAISettingsCommand = "novelai_settings"
+	NovelAIRawCommand      = "novelai_raw"
+	NovelAIInfoCommand     = "naiinfo"
+	NovelAIInfoMessage     = "NovelAI Info"
+)
 
 const (
 	promptOption   = "prompt"
@@ -30,13 +36,25 @@ const (
 	novelaiSMEAOption     = "smea"
 	novelaiSMEADynOption  = "smea_dyn"
 
-	novelaiVibeTransfer = "vibe_transfer"
-	novelaiInformation  = "information_extracted"
-	novelaiReference    = "reference_strength"
-	novelaiImg2ImgStr   = "img2img_strength"
+	novelaiVibeTransfer  = "vibe_transfer"
+	novelaiInformation   = "information_extracted"
+	novelaiReference     = "reference_strength"
+	novelaiVibeTransfer2 = "vibe_transfer_2"
+	novelaiInformation2  = "information_extracted_2"
+	novelaiReference2    = "reference_strength_2"
+	novelaiVibeSet       = "vibe_set"
+	novelaiSaveVibeSet   = "save_vibe_set"
+	novelaiImg2ImgStr    = "img2img_strength"
 
 	novelaiVariety = "variety"
 
+	novelaiResetOption = "reset"
+
+	novelaiJSONFile    = "json_file"
+	novelaiUseDefaults = "use_defaults"
+
+	novelaiInfoImage = "image"
+
 	img2imgOption   = "img2img"
 	denoisingOption = "denoising"
 )
@@ -44,7 +62,18 @@ const (
 func (q *NAIQueue) handlers() queue.CommandHandlers {
 	return queue.CommandHandlers{
 		discordgo.InteractionApplicationCommand: {
-			NovelAICommand: q.processNovelAICommand,
+			NovelAICommand:         q.processNovelAICommand,
+			NovelAISettingsCommand: q.processNovelAISettingsCommand,
+			NovelAIRawCommand:      q.processNovelAIRawCommand,
+			NovelAIInfoCommand:     q.processNovelAIInfoCommand,
+			NovelAIInfoMessage:     q.processNovelAIInfoMessage,
+		},
+		discordgo.InteractionApplicationCommandAutocomplete: {
+			NovelAICommand: q.processNovelAIAutocomplete,
+		},
+		discordgo.InteractionModalSubmit: {
+			rawModal:         q.processNovelAIRawModal,
+			rawModalDefaults: q.processNovelAIRawModal,
 		},
 	}
 }
@@ -114,25 +143,8 @@ func (q *NAIQueue) processNovelAICommand(s *discordgo.Session, i *discordgo.Inte
 		return handlers.ErrorEdit(s, i.Interaction, "Error getting attachments.", err)
 	}
 
-	if option, ok := optionMap[novelaiVibeTransfer]; ok {
-		attachment, ok := attachments[option.Value.(string)]
-		if !ok {
-			return handlers.ErrorEdit(s, i.Interaction, "You need to provide an image to img2img.")
-		}
-		if item.Request.Model == entities.ModelV4Preview {
-			return handlers.ErrorEdit(s, i.Interaction, "Vibe transfer is not yet supported for V4 models.")
-		}
-
-		item.Type = ItemTypeVibeTransfer
-		item.Request.Parameters.VibeTransferImage = attachment.Image
-
-		if option, ok := optionMap[novelaiInformation]; ok {
-			item.Request.Parameters.ReferenceInformationExtracted = option.FloatValue()
-		}
-
-		if option, ok := optionMap[novelaiReference]; ok {
-			item.Request.Parameters.ReferenceStrength = option.FloatValue()
-		}
+	if err := q.applyVibeTransfer(item, optionMap, attachments); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error applying vibe transfer.", err)
 	}
 
 	if option, ok := optionMap[img2imgOption]; ok {
@@ -157,7 +169,12 @@ func (q *NAIQueue) processNovelAICommand(s *discordgo.Session, i *discordgo.Inte
 		}
 	}
 
-	_, err = q.Add(item)
+	return q.addToQueue(s, i, item)
+}
+
+// addToQueue adds the item to the queue and shows its position in line with a cancel button.
+func (q *NAIQueue) addToQueue(s *discordgo.Session, i *discordgo.InteractionCreate, item *NAIQueueItem) error {
+	_, err := q.Add(item)
 	if err != nil {
 		return handlers.ErrorEdit(s, i.Interaction, "Error adding imagine to queue.", err)
 	}
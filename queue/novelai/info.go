@@ -0,0 +1,244 @@
+package novelai
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ellypaws/novelai-metadata/pkg/meta"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+const requestFile = "request.json"
+
+// processNovelAIInfoCommand reads the metadata of the attached image.
+func (q *NAIQueue) processNovelAIInfoCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+	option, ok := optionMap[novelaiInfoImage]
+	if !ok {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide an image.")
+	}
+
+	attachment, ok := i.ApplicationCommandData().Resolved.Attachments[option.Value.(string)]
+	if !ok {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide an image.")
+	}
+
+	return q.showInfo(s, i, attachment)
+}
+
+// processNovelAIInfoMessage reads the metadata of the first image in the message the context menu was used on.
+func (q *NAIQueue) processNovelAIInfoMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	data := i.ApplicationCommandData()
+	message, ok := data.Resolved.Messages[data.TargetID]
+	if !ok {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find the message.")
+	}
+
+	for _, attachment := range message.Attachments {
+		if strings.HasPrefix(attachment.ContentType, "image/png") {
+			return q.showInfo(s, i, attachment)
+		}
+	}
+
+	return handlers.ErrorEdit(s, i.Interaction, "The message does not have a PNG image.")
+}
+
+// showInfo responds with the generation parameters of the attachment, along with the request rebuilt from them so it can be queued again.
+func (q *NAIQueue) showInfo(s *discordgo.Session, i *discordgo.InteractionCreate, attachment *discordgo.MessageAttachment) error {
+	image, err := utils.GetDataFromUrl(attachment.URL)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error downloading the image.", err)
+	}
+
+	metadata, err := readMetadata(image)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Could not find any NovelAI metadata in the image.", err)
+	}
+
+	request := requestFromMetadata(metadata)
+	blob, err := json.MarshalIndent(request, "", "  ")
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error encoding the request.", err)
+	}
+
+	embed := infoEmbed(metadata, request)
+	embed.Image = &discordgo.MessageEmbedImage{URL: attachment.URL}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+		Files: []*discordgo.File{
+			{
+				Name:        requestFile,
+				ContentType: "application/json",
+				Reader:      bytes.NewReader(blob),
+			},
+		},
+		Components: &[]discordgo.MessageComponent{components[requeue]},
+	})
+	return err
+}
+
+// processRequeue queues the request.json attached by showInfo.
+func (q *NAIQueue) processRequeue(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	for _, attachment := range i.Message.Attachments {
+		if attachment.Filename != requestFile {
+			continue
+		}
+
+		blob, err := utils.GetDataFromUrl(attachment.URL)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error downloading the request.", err)
+		}
+
+		return q.rawToQueue(s, i, blob, false)
+	}
+
+	return handlers.ErrorEdit(s, i.Interaction, "Could not find the request to queue.")
+}
+
+// readMetadata looks for the metadata in the PNG text chunks first, then falls back to the alpha channel,
+// which survives platforms that strip the text chunks.
+func readMetadata(image []byte) (*meta.Metadata, error) {
+	chunks, err := utils.PNGTextChunks(image)
+	if err == nil && chunks["Comment"] != "" {
+		var comment meta.Comment
+		if err := json.Unmarshal([]byte(chunks["Comment"]), &comment); err == nil {
+			metadata := &meta.Metadata{
+				Comment:     &comment,
+				Description: chunks["Description"],
+				Software:    chunks["Software"],
+				Source:      chunks["Source"],
+			}
+			if generationTime, ok := chunks["Generation time"]; ok {
+				metadata.GenerationTime = &generationTime
+			}
+			return metadata, nil
+		}
+	}
+
+	metadata, err := meta.ExtractFromBytes(bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil || metadata.Comment == nil {
+		return nil, errors.New("no metadata found")
+	}
+
+	return metadata, nil
+}
+
+// requestFromMetadata rebuilds the request that generated the image.
+// The prompt in the metadata already has the quality tags and undesired content preset applied, so both are turned off.
+func requestFromMetadata(metadata *meta.Metadata) *entities.NovelAIRequest {
+	request := entities.DefaultNovelAIRequest()
+	request.Model = modelFromSource(metadata.Source)
+
+	comment := metadata.Comment
+	request.Input = comment.Prompt
+	parameters := &request.Parameters
+	parameters.NegativePrompt = comment.Uc
+	parameters.Seed = comment.Seed
+	parameters.Steps = comment.Steps
+	parameters.Scale = comment.Scale
+	parameters.Width = comment.Width
+	parameters.Height = comment.Height
+	parameters.ResolutionPreset = nil
+	parameters.Smea = comment.Sm
+	parameters.SmeaDyn = comment.SmDyn
+	parameters.AutoSmea = false
+	parameters.QualityToggle = false
+	parameters.UcPreset = nil
+	parameters.ImageCount = 1
+	if comment.Sampler != "" {
+		parameters.Sampler = comment.Sampler
+	}
+	if comment.NoiseSchedule != nil {
+		parameters.NoiseSchedule = *comment.NoiseSchedule
+	}
+	if comment.CFGRescale != nil {
+		parameters.CFGRescale = *comment.CFGRescale
+	}
+
+	return request
+}
+
+// sourceModels maps the Source text chunk of each NovelAI model to the model, by the hash NovelAI gives the model.
+var sourceModels = map[string]string{
+	"Stable Diffusion 1D44365E":     entities.ModelV1Curated,
+	"Stable Diffusion F4D50568":     entities.ModelV1,
+	"Stable Diffusion 81274D13":     entities.ModelFurryV1,
+	"Stable Diffusion 3B3287AF":     entities.ModelFurryV1,
+	"Stable Diffusion 4CC42576":     entities.ModelV2,
+	"Stable Diffusion XL B0BDF6C1":  entities.ModelV3,
+	"Stable Diffusion XL C1E1DE52":  entities.ModelV3,
+	"Stable Diffusion XL 7BCCAA2C":  entities.ModelV3,
+	"Stable Diffusion XL 8BA2AF87":  entities.ModelV3,
+	"Stable Diffusion XL 9CC2F394":  entities.ModelFurryV3,
+	"NovelAI Diffusion V4 7ABFFA2A": entities.ModelV4Preview,
+	"NovelAI Diffusion V4 4F49EC75": entities.ModelV4Full,
+}
+
+// modelFromSource maps the Source text chunk (e.g. "NovelAI Diffusion V4 4F49EC75") to the model that generated the image.
+// Unknown sources fall back to the default model rather than guessing from the architecture,
+// as the anime and furry models share it.
+func modelFromSource(source string) string {
+	if model, ok := sourceModels[strings.TrimSpace(source)]; ok {
+		return model
+	}
+	return entities.DefaultNovelAIRequest().Model
+}
+
+func infoEmbed(metadata *meta.Metadata, request *entities.NovelAIRequest) *discordgo.MessageEmbed {
+	comment := metadata.Comment
+	source := cmp.Or(metadata.Source, "unknown")
+
+	embed := &discordgo.MessageEmbed{
+		Title: "NovelAI Info",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Prompt", Value: fmt.Sprintf("```\n%s\n```", truncate(comment.Prompt, 1000)), Inline: false},
+		},
+	}
+	if comment.Uc != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Undesired Content", Value: fmt.Sprintf("```\n%s\n```", truncate(comment.Uc, 1000)), Inline: false,
+		})
+	}
+	embed.Fields = append(embed.Fields,
+		&discordgo.MessageEmbedField{Name: "Model", Value: fmt.Sprintf("`%s` (%s)", request.Model, source), Inline: false},
+		&discordgo.MessageEmbedField{Name: "Seed", Value: fmt.Sprintf("`%d`", comment.Seed), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Sampler", Value: fmt.Sprintf("`%s`", comment.Sampler), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Steps", Value: fmt.Sprintf("`%d`", comment.Steps), Inline: true},
+		&discordgo.MessageEmbedField{Name: "CFG Scale", Value: fmt.Sprintf("`%0.1f`", comment.Scale), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Size", Value: fmt.Sprintf("`%dx%d`", comment.Width, comment.Height), Inline: true},
+	)
+
+	return embed
+}
+
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length]) + "..."
+}
@@ -0,0 +1,134 @@
+package novelai
+
+import (
+	"os"
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestRequestFromMetadata(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		model    string
+		prompt   string
+		negative string
+		seed     int64
+		sampler  string
+		schedule string
+		width    int64
+		height   int64
+		scale    float64
+		rescale  float64
+		smea     bool
+	}{
+		{
+			fixture:  "testdata/v4_full.png",
+			model:    entities.ModelV4Full,
+			prompt:   "1girl, hatsune miku, very aesthetic, masterpiece, no text",
+			negative: "blurry, lowres, error",
+			seed:     2810548327,
+			sampler:  "k_euler_ancestral",
+			schedule: "karras",
+			width:    832,
+			height:   1216,
+			scale:    6,
+			rescale:  0.2,
+		},
+		{
+			fixture:  "testdata/v3_anime.png",
+			model:    entities.ModelV3,
+			prompt:   "1girl, solo, best quality, amazing quality",
+			negative: "lowres, bad anatomy",
+			seed:     1234567890,
+			sampler:  "k_dpmpp_2s_ancestral",
+			schedule: "native",
+			width:    1024,
+			height:   1024,
+			scale:    5,
+			smea:     true,
+		},
+		{
+			fixture:  "testdata/v3_furry.png",
+			model:    entities.ModelFurryV3,
+			prompt:   "fox, solo, {best quality}",
+			negative: "lowres",
+			seed:     42,
+			sampler:  "k_euler",
+			schedule: "native",
+			width:    832,
+			height:   1216,
+			scale:    5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			image, err := os.ReadFile(tt.fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metadata, err := readMetadata(image)
+			if err != nil {
+				t.Fatalf("Expected the metadata to be read, got %v", err)
+			}
+			if metadata.GenerationTime == nil || metadata.Software != "NovelAI" {
+				t.Errorf("Expected the generation time and software to be read, got %v and %q", metadata.GenerationTime, metadata.Software)
+			}
+
+			request := requestFromMetadata(metadata)
+			parameters := request.Parameters
+			if request.Model != tt.model {
+				t.Errorf("Expected model %s from %q, got %s", tt.model, metadata.Source, request.Model)
+			}
+			if request.Input != tt.prompt || parameters.NegativePrompt != tt.negative {
+				t.Errorf("Expected prompt %q and negative %q, got %q and %q", tt.prompt, tt.negative, request.Input, parameters.NegativePrompt)
+			}
+			if parameters.Seed != tt.seed || parameters.Sampler != tt.sampler || string(parameters.NoiseSchedule) != tt.schedule {
+				t.Errorf("Expected seed %d with %s and %s, got %d with %s and %s",
+					tt.seed, tt.sampler, tt.schedule, parameters.Seed, parameters.Sampler, parameters.NoiseSchedule)
+			}
+			if parameters.Width != tt.width || parameters.Height != tt.height || parameters.ResolutionPreset != nil {
+				t.Errorf("Expected %dx%d without a preset, got %dx%d with %v", tt.width, tt.height, parameters.Width, parameters.Height, parameters.ResolutionPreset)
+			}
+			if parameters.Steps != 28 || parameters.Scale != tt.scale || parameters.CFGRescale != tt.rescale {
+				t.Errorf("Expected 28 steps at scale %v rescaled by %v, got %d at %v rescaled by %v",
+					tt.scale, tt.rescale, parameters.Steps, parameters.Scale, parameters.CFGRescale)
+			}
+			if parameters.Smea != tt.smea || parameters.SmeaDyn != tt.smea || parameters.AutoSmea {
+				t.Errorf("Expected SMEA %v without auto SMEA, got %v, %v and %v", tt.smea, parameters.Smea, parameters.SmeaDyn, parameters.AutoSmea)
+			}
+			if parameters.QualityToggle || parameters.UcPreset != nil || parameters.ImageCount != 1 {
+				t.Errorf("Expected a single image without the quality tags or undesired content preset, got %d with %v and %v",
+					parameters.ImageCount, parameters.QualityToggle, parameters.UcPreset)
+			}
+		})
+	}
+}
+
+func TestModelFromSource(t *testing.T) {
+	tests := []struct {
+		source string
+		model  string
+	}{
+		{"Stable Diffusion 1D44365E", entities.ModelV1Curated},
+		{"Stable Diffusion F4D50568", entities.ModelV1},
+		{"Stable Diffusion 81274D13", entities.ModelFurryV1},
+		{"Stable Diffusion 4CC42576", entities.ModelV2},
+		{"Stable Diffusion XL C1E1DE52", entities.ModelV3},
+		{"Stable Diffusion XL 8BA2AF87", entities.ModelV3},
+		{"Stable Diffusion XL 9CC2F394", entities.ModelFurryV3},
+		{"NovelAI Diffusion V4 7ABFFA2A", entities.ModelV4Preview},
+		{"NovelAI Diffusion V4 4F49EC75", entities.ModelV4Full},
+		// unknown models aren't guessed from their architecture
+		{"Stable Diffusion XL 00000000", entities.DefaultNovelAIRequest().Model},
+		{"", entities.DefaultNovelAIRequest().Model},
+	}
+
+	for _, tt := range tests {
+		if model := modelFromSource(tt.source); model != tt.model {
+			t.Errorf("Expected %q to be %s, got %s", tt.source, tt.model, model)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package novelai
 
 import (
+	"log"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -15,6 +16,10 @@ const (
 	ItemTypeImage        ItemType = "Text to Image"
 	ItemTypeVibeTransfer ItemType = "Vibe Transfer"
 	ItemTypeImg2Img      ItemType = "Image to Image"
+	ItemTypeReroll       ItemType = "Reroll"
+	ItemTypeVariation    ItemType = "Variation"
+	ItemTypeUpscale      ItemType = "Upscale"
+	ItemTypeRaw          ItemType = "Raw"
 )
 
 type NAIQueueItem struct {
@@ -22,6 +27,9 @@ type NAIQueueItem struct {
 
 	Request *entities.NovelAIRequest
 
+	// Image is the source image for ItemTypeUpscale
+	Image *utils.Image
+
 	Created            time.Time
 	InteractionIndex   int
 	DiscordInteraction *discordgo.Interaction
@@ -36,9 +44,8 @@ func (q *NAIQueueItem) Interaction() *discordgo.Interaction {
 }
 
 func (q *NAIQueue) NewItem(interaction *discordgo.Interaction, options ...func(*NAIQueueItem)) *NAIQueueItem {
-	item := q.DefaultQueueItem()
+	item := q.DefaultQueueItem(utils.GetUser(interaction))
 	item.DiscordInteraction = interaction
-	item.user = utils.GetUser(interaction)
 
 	for _, option := range options {
 		option(item)
@@ -47,12 +54,23 @@ func (q *NAIQueue) NewItem(interaction *discordgo.Interaction, options ...func(*
 	return item
 }
 
-func (q *NAIQueue) DefaultQueueItem() *NAIQueueItem {
+// DefaultQueueItem returns a new item with the user's NovelAI settings applied on top of entities.DefaultNovelAIRequest.
+func (q *NAIQueue) DefaultQueueItem(user *discordgo.User) *NAIQueueItem {
+	request := entities.DefaultNovelAIRequest()
+	if user != nil {
+		settings, err := q.getSettings(user.ID)
+		if err != nil {
+			log.Printf("Error retrieving NovelAI settings for %s: %v", user.Username, err)
+		}
+		settings.Apply(request)
+	}
+
 	return &NAIQueueItem{
 		Type:      ItemTypeImage,
-		Request:   entities.DefaultNovelAIRequest(),
+		Request:   request,
 		Created:   time.Now(),
 		Interrupt: nil,
+		user:      user,
 	}
 }
 
@@ -61,3 +79,9 @@ func WithPrompt(prompt string) func(*NAIQueueItem) {
 		item.Request.Input = prompt
 	}
 }
+
+func WithRequest(request *entities.NovelAIRequest) func(*NAIQueueItem) {
+	return func(item *NAIQueueItem) {
+		item.Request = request
+	}
+}
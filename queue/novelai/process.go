@@ -34,7 +34,7 @@ func (q *NAIQueue) next() error {
 	q.mu.Unlock()
 
 	switch q.current.Type {
-	case ItemTypeImage, ItemTypeVibeTransfer, ItemTypeImg2Img:
+	case ItemTypeImage, ItemTypeVibeTransfer, ItemTypeImg2Img, ItemTypeReroll, ItemTypeVariation, ItemTypeRaw, ItemTypeUpscale:
 		interaction, err := q.processCurrentItem()
 		if err != nil {
 			if interaction == nil {
@@ -57,6 +57,8 @@ func requireInteraction(i *discordgo.Interaction) {
 }
 
 func (q *NAIQueue) done() {
+	q.guilds.Done(q.current.DiscordInteraction)
+
 	q.mu.Lock()
 	q.current = nil
 	q.updateWaiting()
@@ -79,6 +81,7 @@ func (q *NAIQueue) updateWaiting() {
 		item := <-q.queue
 		if q.cancelled[item.DiscordInteraction.ID] {
 			delete(q.cancelled, item.DiscordInteraction.ID)
+			q.guilds.Done(item.DiscordInteraction)
 			continue
 		}
 		item.pos = position
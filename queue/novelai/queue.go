@@ -2,6 +2,7 @@ package novelai
 
 import (
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -12,17 +13,39 @@ import (
 	"stable_diffusion_bot/api/novelai"
 	"stable_diffusion_bot/composite_renderer"
 	"stable_diffusion_bot/queue"
+	"stable_diffusion_bot/repositories/novelai_generations"
+	"stable_diffusion_bot/repositories/novelai_settings"
+	"stable_diffusion_bot/repositories/vibe_sets"
 )
 
-func New(token *string) queue.Queue[*NAIQueueItem] {
-	if token == nil {
+type Config struct {
+	Token                 *string
+	VibeSetRepo           vibe_sets.Repository
+	NovelAIGenerationRepo novelai_generations.Repository
+	NovelAISettingsRepo   novelai_settings.Repository
+	Guilds                *queue.Guilds   // optional, shared by the queues, enables the settings of each server
+	Captioner             queue.Captioner // optional, gives the finished images alt text
+}
+
+func New(cfg Config) queue.Queue[*NAIQueueItem] {
+	if cfg.Token == nil {
 		return nil
 	}
+	guilds := cfg.Guilds
+	if guilds == nil {
+		guilds = queue.NewGuilds(nil)
+	}
+
 	return &NAIQueue{
-		client:     novelai.NewNovelAIClient(*token),
-		queue:      make(chan *NAIQueueItem, 24),
-		cancelled:  make(map[string]bool),
-		compositor: composite_renderer.Compositor(),
+		client:         novelai.NewNovelAIClient(*cfg.Token),
+		queue:          make(chan *NAIQueueItem, 24),
+		cancelled:      make(map[string]bool),
+		compositor:     composite_renderer.Compositor(),
+		vibeSetRepo:    cfg.VibeSetRepo,
+		generationRepo: cfg.NovelAIGenerationRepo,
+		settingsRepo:   cfg.NovelAISettingsRepo,
+		captioner:      cfg.Captioner,
+		guilds:         guilds,
 	}
 }
 
@@ -38,6 +61,12 @@ type NAIQueue struct {
 
 	compositor composite_renderer.Renderer
 
+	vibeSetRepo    vibe_sets.Repository
+	generationRepo novelai_generations.Repository
+	settingsRepo   novelai_settings.Repository
+	captioner      queue.Captioner
+	guilds         *queue.Guilds
+
 	stop chan os.Signal
 }
 
@@ -75,6 +104,21 @@ func (q *NAIQueue) Add(item *NAIQueueItem) (int, error) {
 		return -1, errors.New("queue is full")
 	}
 
+	if item.Request != nil && item.DiscordInteraction != nil {
+		guild := q.guilds.Settings(item.DiscordInteraction.GuildID)
+		width, height := item.Request.Parameters.Width, item.Request.Parameters.Height
+		if preset := item.Request.Parameters.ResolutionPreset; preset != nil {
+			width, height = preset[0], preset[1]
+		}
+		if !guild.FitsSize(int(width), int(height)) {
+			return -1, fmt.Errorf("%dx%d is larger than this server allows", width, height)
+		}
+	}
+
+	if err := q.guilds.Wait(item.DiscordInteraction); err != nil {
+		return -1, err
+	}
+
 	item.pos = len(q.queue)
 	q.queue <- item
 
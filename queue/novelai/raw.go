@@ -0,0 +1,153 @@
+package novelai
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+// processNovelAIRawCommand queues a request from an attached JSON file, or responds with a modal to paste the JSON in.
+// Whether to merge with the defaults is carried over to the modal through its custom ID.
+func (q *NAIQueue) processNovelAIRawCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	useDefaults := true
+	if option, ok := optionMap[novelaiUseDefaults]; ok {
+		useDefaults = option.BoolValue()
+	}
+
+	option, ok := optionMap[novelaiJSONFile]
+	if !ok {
+		customID := rawModal
+		if useDefaults {
+			customID = rawModalDefaults
+		}
+
+		return handlers.Wrap(s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID:   customID,
+				Title:      "Raw NovelAI JSON",
+				Components: []discordgo.MessageComponent{components[rawInput]},
+			},
+		}))
+	}
+
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	attachment, ok := i.ApplicationCommandData().Resolved.Attachments[option.Value.(string)]
+	if !ok || !strings.HasPrefix(attachment.ContentType, "application/json") {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a JSON file.")
+	}
+
+	resp, err := http.Get(attachment.URL)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error downloading attachment.", err)
+	}
+	defer resp.Body.Close()
+
+	blob, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error reading attachment.", err)
+	}
+
+	return q.rawToQueue(s, i, blob, useDefaults)
+}
+
+func (q *NAIQueue) processNovelAIRawModal(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	var blob string
+	for _, row := range i.ModalSubmitData().Components {
+		actionsRow, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actionsRow.Components {
+			if input, ok := component.(*discordgo.TextInput); ok && input.CustomID == rawInput {
+				blob = input.Value
+			}
+		}
+	}
+
+	if blob == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a JSON blob.")
+	}
+
+	return q.rawToQueue(s, i, []byte(blob), i.ModalSubmitData().CustomID == rawModalDefaults)
+}
+
+// mergeRaw unmarshals the blob on top of the request. The resolution preset of the defaults is dropped when
+// the blob sets its own size, as the preset would otherwise replace it once the request is initialized.
+// A blob setting only the width or the height keeps the other one from the preset.
+func mergeRaw(request *entities.NovelAIRequest, blob []byte) error {
+	var size struct {
+		Parameters struct {
+			ResolutionPreset json.RawMessage `json:"resolution_preset"`
+			Width            *int64          `json:"width"`
+			Height           *int64          `json:"height"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(blob, &size); err != nil {
+		return err
+	}
+
+	parameters := &request.Parameters
+	if preset := parameters.ResolutionPreset; preset != nil {
+		// the defaults point to the shared presets, which a preset in the blob would be unmarshalled into
+		copied := *preset
+		parameters.ResolutionPreset = &copied
+		if size.Parameters.ResolutionPreset == nil && (size.Parameters.Width != nil || size.Parameters.Height != nil) {
+			parameters.Width, parameters.Height = preset[0], preset[1]
+			parameters.ResolutionPreset = nil
+		}
+	}
+
+	return json.Unmarshal(blob, request)
+}
+
+// rawToQueue unmarshals the blob, optionally on top of the user's defaults, and validates it before adding it to the queue.
+func (q *NAIQueue) rawToQueue(s *discordgo.Session, i *discordgo.InteractionCreate, blob []byte, useDefaults bool) error {
+	var options []func(*NAIQueueItem)
+	if !useDefaults {
+		request, err := entities.UnmarshalNovelAIRequest(blob)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error parsing the JSON.", err)
+		}
+		options = append(options, WithRequest(&request))
+	}
+
+	item := q.NewItem(i.Interaction, options...)
+	if useDefaults {
+		if err := mergeRaw(item.Request, blob); err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error merging the JSON with the defaults.", err)
+		}
+	}
+	item.Type = ItemTypeRaw
+
+	// Reader initializes and validates the request, so check a copy to leave the queued request untouched.
+	validate := *item.Request
+	if _, err := validate.Reader(); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "The NovelAI request is invalid.", err)
+	}
+
+	log.Printf("Queueing raw NovelAI request (defaults: %t) for %s", useDefaults, item.user.Username)
+	if err := q.addToQueue(s, i, item); err != nil {
+		return fmt.Errorf("error adding raw request to queue: %w", err)
+	}
+
+	return nil
+}
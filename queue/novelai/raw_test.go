@@ -0,0 +1,38 @@
+package novelai
+
+import (
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestMergeRaw(t *testing.T) {
+	tests := []struct {
+		name   string
+		blob   string
+		width  int64
+		height int64
+	}{
+		{"defaults", `{"input":"1girl"}`, entities.ResolutionNormalSquare[0], entities.ResolutionNormalSquare[1]},
+		{"size", `{"parameters":{"width":832,"height":1216}}`, 832, 1216},
+		{"width only", `{"parameters":{"width":1216}}`, 1216, entities.ResolutionNormalSquare[1]},
+		{"preset", `{"parameters":{"width":832,"height":1216,"resolution_preset":[640,640]}}`, 640, 640},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := entities.DefaultNovelAIRequest()
+			if err := mergeRaw(request, []byte(tt.blob)); err != nil {
+				t.Fatalf("Expected nil, got %v", err)
+			}
+
+			request.Init()
+			if entities.ResolutionNormalSquare != [2]int64{1024, 1024} {
+				t.Fatalf("Expected the shared preset to be left untouched, got %v", entities.ResolutionNormalSquare)
+			}
+			if width, height := request.Parameters.Width, request.Parameters.Height; width != tt.width || height != tt.height {
+				t.Errorf("Expected %dx%d, got %dx%d", tt.width, tt.height, width, height)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package novelai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+// getSettings returns the member's NovelAI defaults, or nil if they haven't set any.
+func (q *NAIQueue) getSettings(memberID string) (*entities.NovelAISettings, error) {
+	if q.settingsRepo == nil {
+		return nil, nil
+	}
+
+	settings, err := q.settingsRepo.GetByMemberID(context.Background(), memberID)
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// processNovelAISettingsCommand stores the given options as the member's defaults for /novelai, then shows the current defaults.
+func (q *NAIQueue) processNovelAISettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	if q.settingsRepo == nil {
+		return handlers.ErrorEdit(s, i.Interaction, "NovelAI settings are not available.")
+	}
+
+	user := utils.GetUser(i.Interaction)
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	if option, ok := optionMap[novelaiResetOption]; ok && option.BoolValue() {
+		if err := q.settingsRepo.Delete(context.Background(), user.ID); err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error resetting your NovelAI settings.", err)
+		}
+
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, "Your NovelAI settings have been reset to the bot defaults.")
+		return err
+	}
+
+	settings, err := q.getSettings(user.ID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving your NovelAI settings.", err)
+	}
+	if settings == nil {
+		settings = &entities.NovelAISettings{MemberID: user.ID}
+	}
+
+	if len(optionMap) > 0 {
+		if option, ok := optionMap[novelaiModelOption]; ok {
+			settings.Model = option.StringValue()
+		}
+		if option, ok := optionMap[novelaiSizeOption]; ok {
+			settings.Size = option.StringValue()
+		}
+		if option, ok := optionMap[novelaiSamplerOption]; ok {
+			settings.Sampler = option.StringValue()
+		}
+		if option, ok := optionMap[novelaiScheduleOption]; ok {
+			settings.Schedule = option.StringValue()
+		}
+		if option, ok := optionMap[novelaiUCPresetOption]; ok {
+			value := option.IntValue()
+			settings.UcPreset = &value
+		}
+		if option, ok := optionMap[novelaiQualityOption]; ok {
+			value := option.BoolValue()
+			settings.QualityToggle = &value
+		}
+		if option, ok := optionMap[cfgScaleOption]; ok {
+			value := option.FloatValue()
+			settings.Scale = &value
+		}
+		if option, ok := optionMap[stepOption]; ok {
+			value := option.IntValue()
+			settings.Steps = &value
+		}
+
+		settings, err = q.settingsRepo.Upsert(context.Background(), settings)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error saving your NovelAI settings.", err)
+		}
+		log.Printf("Updated NovelAI settings for %s: %+v", user.Username, settings)
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction,
+		"Your defaults for the novelai command:",
+		settingsEmbed(settings),
+	)
+	return err
+}
+
+func settingsEmbed(settings *entities.NovelAISettings) discordgo.MessageEmbed {
+	defaults := entities.DefaultNovelAIRequest()
+	settings.Apply(defaults)
+
+	size := settings.Size
+	if size == "" {
+		size = "Default"
+	}
+
+	var uc int64 = -1
+	if defaults.Parameters.UcPreset != nil {
+		uc = *defaults.Parameters.UcPreset
+	}
+
+	return discordgo.MessageEmbed{
+		Title: "NovelAI Settings",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Model", Value: fmt.Sprintf("`%s`", defaults.Model), Inline: false},
+			{Name: "Size", Value: fmt.Sprintf("`%s`", size), Inline: true},
+			{Name: "Sampler", Value: fmt.Sprintf("`%s`", defaults.Parameters.Sampler), Inline: true},
+			{Name: "Schedule", Value: fmt.Sprintf("`%s`", defaults.Parameters.NoiseSchedule), Inline: true},
+			{Name: "UC Preset", Value: fmt.Sprintf("`%d`", uc), Inline: true},
+			{Name: "Quality Tags", Value: fmt.Sprintf("`%t`", defaults.Parameters.QualityToggle), Inline: true},
+			{Name: "CFG Scale", Value: fmt.Sprintf("`%0.1f`", defaults.Parameters.Scale), Inline: true},
+			{Name: "Steps", Value: fmt.Sprintf("`%d`", defaults.Parameters.Steps), Inline: true},
+		},
+	}
+}
@@ -1,6 +1,7 @@
 package novelai
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 
 	"stable_diffusion_bot/discord_bot/handlers"
 	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/utils"
 )
 
@@ -33,9 +35,15 @@ func (q *NAIQueue) processCurrentItem() (*discordgo.Interaction, error) {
 		return item.DiscordInteraction, errors.New("request is nil")
 	}
 
-	cost := request.CalculateCost(true)
-	if cost >= 10 {
-		return item.DiscordInteraction, fmt.Errorf("cost is %d", cost)
+	if item.Type != ItemTypeUpscale {
+		if !q.guilds.AllowsNSFW(q.botSession, item.DiscordInteraction) {
+			request.Parameters.NegativePrompt = queue.AvoidNSFW(request.Parameters.NegativePrompt)
+		}
+
+		cost := request.CalculateCost(true)
+		if cost >= 10 {
+			return item.DiscordInteraction, fmt.Errorf("cost is %d", cost)
+		}
 	}
 
 	promise := make(chan error)
@@ -70,8 +78,9 @@ func (q *NAIQueue) processImagineGrid(item *NAIQueueItem) error {
 	go q.updateProgressBar(item, generationDone)
 
 	switch item.Type {
-	case ItemTypeImage, ItemTypeVibeTransfer, ItemTypeImg2Img:
+	case ItemTypeImage, ItemTypeVibeTransfer, ItemTypeImg2Img, ItemTypeReroll, ItemTypeVariation, ItemTypeRaw:
 		item.Created = time.Now()
+		submitted := submittedRequest(item.Request)
 		images, err := q.client.Inference(item.Request)
 		generationDone <- true
 		if err != nil {
@@ -79,14 +88,23 @@ func (q *NAIQueue) processImagineGrid(item *NAIQueueItem) error {
 		}
 
 		message := fmt.Sprintf("%s\n\nUploading image...", imagineMessageSimple(item.Request, item.user))
-		_, err = q.botSession.InteractionResponseEdit(item.DiscordInteraction, &discordgo.WebhookEdit{
+		_, err = handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, &discordgo.WebhookEdit{
 			Content: &message,
 		})
 		if err != nil {
 			return err
 		}
 
-		return q.showFinalMessage(item, images, embed)
+		return q.showFinalMessage(item, submitted, images, embed)
+	case ItemTypeUpscale:
+		item.Created = time.Now()
+		images, err := q.upscale(item)
+		generationDone <- true
+		if err != nil {
+			return fmt.Errorf("error upscaling image: %w", err)
+		}
+
+		return q.showUpscaleMessage(item, images, embed)
 	default:
 		return fmt.Errorf("unknown item type: %s", item.Type)
 	}
@@ -144,7 +162,7 @@ Ticker:
 
 			elapsed = tick.Sub(start).Round(time.Second).String()
 			progress := fmt.Sprintf("\r%s\n\n%s Time elapsed: %s", message, visual[frame], elapsed)
-			_, progressErr := q.botSession.InteractionResponseEdit(item.DiscordInteraction, &discordgo.WebhookEdit{
+			_, progressErr := handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, &discordgo.WebhookEdit{
 				Content: &progress,
 			})
 			if progressErr != nil {
@@ -165,11 +183,17 @@ func nextFrame(current, length int) int {
 	return (current + 1) % length
 }
 
-func (q *NAIQueue) showFinalMessage(item *NAIQueueItem, response *entities.NovelAIResponse, embed *discordgo.MessageEmbed) error {
+func (q *NAIQueue) showFinalMessage(item *NAIQueueItem, submitted *entities.NovelAIRequest, response *entities.NovelAIResponse, embed *discordgo.MessageEmbed) error {
 	request := item.Request
 	totalImages := int(request.Parameters.ImageCount)
 
+	metadata, err := getMetadata(response)
+	if err != nil {
+		return err
+	}
 	imageBuffers, thumbnailBuffers := retrieveImagesFromResponse(response, item)
+	imageBuffers = imageBuffers[:min(len(imageBuffers), totalImages)]
+	metadata = metadata[:min(len(metadata), len(imageBuffers))]
 
 	var user *discordgo.User
 	if item.user != nil {
@@ -178,32 +202,74 @@ func (q *NAIQueue) showFinalMessage(item *NAIQueueItem, response *entities.Novel
 		user = &discordgo.User{ID: "unknown"}
 	}
 
+	// Buttons refer to each image by its attachment, which is lost when more than four images are tiled.
+	var buttons int
+	if len(imageBuffers) <= 4 {
+		buttons = len(imageBuffers)
+	}
+
 	mention := fmt.Sprintf("<@%v>", user.ID)
 	webhook := &discordgo.WebhookEdit{
 		Content:    &mention,
-		Components: &[]discordgo.MessageComponent{handlers.Components[handlers.DeleteGeneration]},
+		Components: rerollVariationComponents(buttons),
+	}
+
+	var first *meta.Metadata
+	if len(metadata) > 0 {
+		first = metadata[0]
 	}
 
-	embed = generationEmbedDetails(embed, item, getMetadata(response), item.Interrupt != nil, len(item.Request.Input) > 200)
-	err := utils.EmbedImages(webhook, embed, imageBuffers[:min(len(imageBuffers), totalImages)], thumbnailBuffers, q.compositor)
+	embed = generationEmbedDetails(embed, item, first, item.Interrupt != nil, len(item.Request.Input) > 200)
+	err = utils.EmbedImages(webhook, embed, imageBuffers, thumbnailBuffers, q.compositor)
 	if err != nil {
 		return fmt.Errorf("error creating image embed: %w", err)
 	}
 
-	_, err = handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, webhook)
-	return err
-}
+	message, err := q.editImages(item.DiscordInteraction, webhook)
+	if err != nil {
+		return err
+	}
+
+	// the images are already posted, so they're kept even if they can't be recorded
+	if err := q.recordGenerations(item, submitted, message, metadata); err != nil {
+		log.Printf("Error recording generations of %s: %v", item.DiscordInteraction.ID, err)
+	}
 
-// Deprecated: reading meta.Metadata is expensive
-func getMetadata(response *entities.NovelAIResponse) *meta.Metadata {
 	return nil
 }
 
+// getMetadata reads the metadata of each image in the response, which is expensive as every pixel is read.
+// The images are buffered and replaced in the response so that they can still be read afterward.
+// Images without valid metadata have a nil entry. An image that can't be read fails the whole response,
+// as skipping it would shift the attachments out of line with the recorded generations.
+func getMetadata(response *entities.NovelAIResponse) ([]*meta.Metadata, error) {
+	metadata := make([]*meta.Metadata, len(response.Images))
+	for idx, image := range response.Images {
+		data, err := io.ReadAll(image)
+		if err != nil {
+			return nil, fmt.Errorf("error reading image #%d: %w", idx+1, err)
+		}
+		response.Images[idx] = bytes.NewReader(data)
+
+		metadata[idx], err = meta.ExtractFromBytes(bytes.NewReader(data))
+		if err != nil || metadata[idx].Comment == nil {
+			log.Printf("Could not read metadata of image #%d: %v", idx, err)
+			metadata[idx] = nil
+		}
+	}
+
+	return metadata, nil
+}
+
 func retrieveImagesFromResponse(response *entities.NovelAIResponse, item *NAIQueueItem) (images []io.Reader, thumbnails []io.Reader) {
 	if image := item.Request.Parameters.VibeTransferImage; image != nil {
 		thumbnails = append(thumbnails, image)
 	}
 
+	for _, image := range item.Request.Parameters.ReferenceImageMultiple {
+		thumbnails = append(thumbnails, image)
+	}
+
 	if image := item.Request.Parameters.Img2Img; image != nil {
 		thumbnails = append(thumbnails, image)
 	}
@@ -342,6 +408,14 @@ func generationEmbedDetails(embed *discordgo.MessageEmbed, item *NAIQueueItem, m
 		}
 	}
 
+	if references := len(request.Parameters.ReferenceImageMultiple); references > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Vibe Transfer",
+			Value:  fmt.Sprintf("`%d` references", references),
+			Inline: true,
+		})
+	}
+
 	return embed
 }
 
@@ -372,28 +446,19 @@ func imagineMessageSimple(request *entities.NovelAIRequest, user *discordgo.User
 	return message.String()
 }
 
-// storeMessageInteraction stores the message interaction in the database to keep track of the message ID to recreate the message
+// storeMessageInteraction keeps track of the message ID so that the finished generation can be recorded against it
 func (q *NAIQueue) storeMessageInteraction(item *NAIQueueItem, message *discordgo.Message) (err error) {
-	// request := item.Request
-	//
-	// if item.DiscordInteraction == nil {
-	// 	return fmt.Errorf("item.DiscordInteraction is nil")
-	// }
-	//
-	// if message == nil {
-	// 	message, err = q.botSession.InteractionResponse(item.DiscordInteraction)
-	// 	if err != nil {
-	// 		return err
-	// 	}
-	// }
-	//
-	// // store message ID in c.DiscordInteraction.Message
-	// item.DiscordInteraction.Message = message
-	//
-	// request.InteractionID = item.DiscordInteraction.ID
-	// request.MessageID = item.DiscordInteraction.Message.ID
-	// request.MemberID = item.DiscordInteraction.Member.User.ID
-	// request.SortOrder = 0
-	// request.Processed = true
+	if item.DiscordInteraction == nil {
+		return fmt.Errorf("item.DiscordInteraction is nil")
+	}
+
+	if message == nil {
+		message, err = handlers.InteractionResponse(q.botSession, item.DiscordInteraction)
+		if err != nil {
+			return err
+		}
+	}
+
+	item.DiscordInteraction.Message = message
 	return nil
 }
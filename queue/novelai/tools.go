@@ -0,0 +1,47 @@
+package novelai
+
+import (
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+)
+
+// GenerateImage queues an image with the member's NovelAI settings on behalf of the interaction's user, for the generate_image tool of the LLM.
+// The image is refused where /novelai isn't allowed, as the bot only checks the commands sent by Discord.
+func (q *NAIQueue) GenerateImage(interaction *discordgo.Interaction, prompt, negativePrompt string) (int, error) {
+	if !q.guilds.Settings(interaction.GuildID).Allows(NovelAICommand) {
+		return -1, fmt.Errorf("`%s` is not allowed in this server", NovelAICommand)
+	}
+
+	item := q.NewItem(interaction, WithPrompt(prompt))
+	item.Type = ItemTypeImage
+	if negativePrompt != "" {
+		item.Request.Parameters.NegativePrompt = negativePrompt
+	}
+
+	position, err := q.Add(item)
+	if err != nil {
+		return -1, err
+	}
+
+	message, err := handlers.EditInteractionResponse(q.botSession, interaction, q.positionString(item), components[cancel])
+	if err != nil {
+		return position, err
+	}
+	if item.DiscordInteraction != nil && item.DiscordInteraction.Message == nil && message != nil {
+		item.DiscordInteraction.Message = message
+	}
+
+	return position, nil
+}
+
+// Pending returns the number of items waiting in the queue, including the one being generated.
+func (q *NAIQueue) Pending() int {
+	pending := len(q.queue)
+	if q.current != nil {
+		pending++
+	}
+	return pending
+}
@@ -0,0 +1,59 @@
+package novelai
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+func (q *NAIQueue) upscale(item *NAIQueueItem) (*entities.NovelAIResponse, error) {
+	if item.Image == nil {
+		return nil, errors.New("no image to upscale")
+	}
+
+	width, height, err := utils.GetImageSize(bytes.NewReader(item.Image.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("error reading image size: %w", err)
+	}
+
+	return q.client.Upscale(&entities.NovelAIUpscaleRequest{
+		Image:  item.Image,
+		Width:  width,
+		Height: height,
+		Scale:  upscaleFactor,
+	})
+}
+
+func (q *NAIQueue) showUpscaleMessage(item *NAIQueueItem, response *entities.NovelAIResponse, embed *discordgo.MessageEmbed) error {
+	if len(response.Images) == 0 {
+		return errors.New("no images returned from the upscaler")
+	}
+
+	var user *discordgo.User
+	if item.user != nil {
+		user = item.user
+	} else {
+		user = &discordgo.User{ID: "unknown"}
+	}
+
+	mention := fmt.Sprintf("<@%v>", user.ID)
+	webhook := &discordgo.WebhookEdit{
+		Content:    &mention,
+		Components: &[]discordgo.MessageComponent{handlers.Components[handlers.DeleteGeneration]},
+	}
+
+	embed = generationEmbedDetails(embed, item, nil, item.Interrupt != nil, len(item.Request.Input) > 200)
+	err := utils.EmbedImages(webhook, embed, response.Images[:1], nil, q.compositor)
+	if err != nil {
+		return fmt.Errorf("error creating image embed: %w", err)
+	}
+
+	_, err = q.editImages(item.DiscordInteraction, webhook)
+	return err
+}
@@ -0,0 +1,139 @@
+package novelai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+type vibeSlot struct {
+	image, information, strength string
+}
+
+// vibeSlots are the attachment options of /novelai, each with its own information extracted and strength option.
+var vibeSlots = []vibeSlot{
+	{novelaiVibeTransfer, novelaiInformation, novelaiReference},
+	{novelaiVibeTransfer2, novelaiInformation2, novelaiReference2},
+}
+
+// applyVibeTransfer adds the references of the requested vibe set and of every attached vibe transfer image to the item.
+// If save_vibe_set is set, all the references are stored under that name so that they can be reused on later generations.
+func (q *NAIQueue) applyVibeTransfer(item *NAIQueueItem, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption, attachments map[string]utils.AttachmentImage) error {
+	parameters := &item.Request.Parameters
+
+	var references []entities.VibeReference
+	if option, ok := optionMap[novelaiVibeSet]; ok {
+		if q.vibeSetRepo == nil {
+			return errors.New("vibe sets are not available")
+		}
+
+		set, err := q.vibeSetRepo.GetByName(context.Background(), item.user.ID, option.StringValue())
+		if err != nil {
+			return err
+		}
+
+		for _, reference := range set.References {
+			parameters.ReferenceImageMultiple = append(parameters.ReferenceImageMultiple, utils.ImageFromBytes(reference.Image))
+			parameters.ReferenceInformationExtractedMultiple = append(parameters.ReferenceInformationExtractedMultiple, reference.InformationExtracted)
+			parameters.ReferenceStrengthMultiple = append(parameters.ReferenceStrengthMultiple, reference.ReferenceStrength)
+		}
+		references = append(references, set.References...)
+	}
+
+	_, save := optionMap[novelaiSaveVibeSet]
+	for _, slot := range vibeSlots {
+		option, ok := optionMap[slot.image]
+		if !ok {
+			continue
+		}
+
+		attachment, ok := attachments[option.Value.(string)]
+		if !ok {
+			return fmt.Errorf("you need to provide an image for %s", slot.image)
+		}
+
+		reference := entities.VibeReference{
+			InformationExtracted: parameters.ReferenceInformationExtracted,
+			ReferenceStrength:    parameters.ReferenceStrength,
+		}
+		if option, ok := optionMap[slot.information]; ok {
+			reference.InformationExtracted = option.FloatValue()
+		}
+		if option, ok := optionMap[slot.strength]; ok {
+			reference.ReferenceStrength = option.FloatValue()
+		}
+		if save {
+			// Bytes waits for the download without draining the buffer, so the image can still be sent to NovelAI.
+			reference.Image = attachment.Image.Bytes()
+		}
+
+		parameters.ReferenceImageMultiple = append(parameters.ReferenceImageMultiple, attachment.Image)
+		parameters.ReferenceInformationExtractedMultiple = append(parameters.ReferenceInformationExtractedMultiple, reference.InformationExtracted)
+		parameters.ReferenceStrengthMultiple = append(parameters.ReferenceStrengthMultiple, reference.ReferenceStrength)
+		references = append(references, reference)
+	}
+
+	if len(references) > 0 {
+		item.Type = ItemTypeVibeTransfer
+	}
+
+	if option, ok := optionMap[novelaiSaveVibeSet]; ok {
+		if q.vibeSetRepo == nil {
+			return errors.New("vibe sets are not available")
+		}
+		if len(references) == 0 {
+			return errors.New("there are no vibe transfer references to save")
+		}
+
+		_, err := q.vibeSetRepo.Upsert(context.Background(), &entities.VibeSet{
+			MemberID:   item.user.ID,
+			Name:       option.StringValue(),
+			References: references,
+		})
+		if err != nil {
+			return fmt.Errorf("error saving vibe set: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (q *NAIQueue) processNovelAIAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, opt := range i.ApplicationCommandData().Options {
+		if !opt.Focused || opt.Name != novelaiVibeSet || q.vibeSetRepo == nil {
+			continue
+		}
+
+		sets, err := q.vibeSetRepo.ListByMemberID(context.Background(), utils.GetUser(i.Interaction).ID)
+		if err != nil {
+			return err
+		}
+
+		input := strings.ToLower(opt.StringValue())
+		for _, set := range sets {
+			if input != "" && !strings.Contains(strings.ToLower(set.Name), input) {
+				continue
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  set.Name,
+				Value: set.Name,
+			})
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices[:min(25, len(choices))],
+		},
+	})
+	return handlers.Wrap(err)
+}
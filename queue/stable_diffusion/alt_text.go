@@ -0,0 +1,16 @@
+package stable_diffusion
+
+import (
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/utils"
+)
+
+// editImages edits the response with the finished images, which are given alt text when there is a captioner.
+func (q *SDQueue) editImages(interaction *discordgo.Interaction, webhook *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	if q.captioner == nil {
+		return handlers.EditInteractionResponse(q.botSession, interaction, webhook)
+	}
+	return handlers.EditInteractionResponseAltText(q.botSession, interaction, webhook, utils.DescribeImages(webhook, q.captioner.Caption))
+}
@@ -23,9 +23,12 @@ func (q *SDQueue) commands() []*discordgo.ApplicationCommand {
 		},
 		{
 			Name:        ImagineSettingsCommand,
-			Description: "Change the default settings for the imagine command",
+			Description: "Change your default settings for the imagine command",
 			Type:        discordgo.ChatApplicationCommand,
+			Options:     imagineSettingsOptions,
 		},
+		styleCommand(),
+		wildcardCommand(),
 		{
 			Name:        RefreshCommand,
 			Description: "Refresh the loaded models from the API",
@@ -46,6 +49,25 @@ func (q *SDQueue) commands() []*discordgo.ApplicationCommand {
 				commandOptions[unsafeOption],
 			},
 		},
+		{
+			Name:        GalleryCommand,
+			Description: "Browse the images starred as favorites",
+			Type:        discordgo.ChatApplicationCommand,
+			Options:     galleryOptions,
+		},
+		{
+			Name:        HistoryCommand,
+			Description: "Browse your recent generations",
+			Type:        discordgo.ChatApplicationCommand,
+			Options:     historyOptions,
+		},
+		{
+			Name:        SearchCommand,
+			Description: "Search the prompts of past generations",
+			Type:        discordgo.ChatApplicationCommand,
+			Options:     searchOptions,
+		},
+		{Name: UseImageMessage, Type: discordgo.MessageApplicationCommand},
 	}
 }
 
@@ -53,6 +75,7 @@ func imagineOptions() (options []*discordgo.ApplicationCommandOption) {
 	options = []*discordgo.ApplicationCommandOption{
 		commandOptions[promptOption],
 		commandOptions[negativeOption],
+		commandOptions[styleOption],
 		commandOptions[stepOption],
 		commandOptions[seedOption],
 		commandOptions[checkpointOption],
@@ -67,8 +90,9 @@ func imagineOptions() (options []*discordgo.ApplicationCommandOption) {
 		// commandOptions[restoreFacesOption],
 		commandOptions[adModelOption],
 		commandOptions[vaeOption],
-		commandOptions[hypernetworkOption],
+		// commandOptions[hypernetworkOption],
 		commandOptions[embeddingOption],
+		commandOptions[enhanceOption],
 		commandOptions[img2imgOption],
 		commandOptions[denoisingOption],
 		commandOptions[controlnetImage],
@@ -100,7 +124,7 @@ var commandOptions = map[CommandOption]*discordgo.ApplicationCommandOption{
 	promptOption: {
 		Type:        discordgo.ApplicationCommandOptionString,
 		Name:        promptOption,
-		Description: "The text prompt to imagine",
+		Description: "The text prompt to imagine. Use {a|b} for one of the alternatives or __name__ for a wildcard",
 		Required:    true,
 	},
 	negativeOption: {
@@ -109,6 +133,13 @@ var commandOptions = map[CommandOption]*discordgo.ApplicationCommandOption{
 		Description: "Negative prompt",
 		Required:    false,
 	},
+	styleOption: {
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         styleOption,
+		Description:  "The style to apply, made with /style",
+		Required:     false,
+		Autocomplete: true,
+	},
 	stepOption: {
 		Type:        discordgo.ApplicationCommandOptionInteger,
 		Name:        stepOption,
@@ -141,6 +172,12 @@ var commandOptions = map[CommandOption]*discordgo.ApplicationCommandOption{
 		Required:     false,
 		Autocomplete: true,
 	},
+	enhanceOption: {
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        enhanceOption,
+		Description: "Expand your prompt with the LLM before imagining",
+		Required:    false,
+	},
 	embeddingOption: {
 		Type:         discordgo.ApplicationCommandOptionString,
 		Name:         embeddingOption,
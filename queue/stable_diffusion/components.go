@@ -29,13 +29,93 @@ const (
 	JSONInput customID = "raw"
 )
 
+const (
+	SettingsMoreButton  customID = "imagine_settings_more"
+	SettingsResetButton customID = "imagine_settings_reset"
+
+	SettingsModal customID = "imagine_settings_modal"
+
+	SettingsNegativeInput  customID = "imagine_settings_negative"
+	SettingsSamplingInput  customID = "imagine_settings_sampling"
+	SettingsHiresInput     customID = "imagine_settings_hires"
+	SettingsADetailerInput customID = "imagine_settings_adetailer"
+	SettingsBatchInput     customID = "imagine_settings_batch"
+)
+
 const (
 	RerollButton  customID = "imagine_reroll"
 	UpscaleButton customID = "imagine_upscale"
 	VariantButton customID = "imagine_variation"
+
+	OriginalPromptButton customID = "imagine_original_prompt"
+	EditButton           customID = "imagine_edit"
+	FavoriteButton       customID = "imagine_favorite"
+	FavoriteSelect       customID = "imagine_favorite_select"
+)
+
+const (
+	ImageSelect customID = "imagine_image_select"
+
+	PickUpscaleButton  customID = "imagine_pick_upscale"
+	PickVariantButton  customID = "imagine_pick_variation"
+	PickImg2ImgButton  customID = "imagine_pick_img2img"
+	PickDownloadButton customID = "imagine_pick_download"
+	PickFavoriteButton customID = "imagine_pick_favorite"
+)
+
+// UseImageSelect picks what to do with the message of UseImageMessage.
+const UseImageSelect customID = "imagine_use_image_select"
+
+const (
+	GalleryReimagineButton customID = "gallery_reimagine"
+	GalleryUpscaleButton   customID = "gallery_upscale"
+)
+
+const (
+	EditModal customID = "imagine_edit_modal"
+
+	EditPromptInput   customID = "imagine_edit_prompt"
+	EditNegativeInput customID = "imagine_edit_negative"
+	EditSettingsInput customID = "imagine_edit_settings"
+	EditSizeInput     customID = "imagine_edit_size"
+	EditExtrasInput   customID = "imagine_edit_extras"
 )
 
 var components = map[customID]discordgo.MessageComponent{
+	OriginalPromptButton: discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Regenerate with original prompt",
+				Style:    discordgo.SecondaryButton,
+				CustomID: OriginalPromptButton,
+				Emoji: &discordgo.ComponentEmoji{
+					Name: "📝",
+				},
+			},
+		},
+	},
+
+	EditButton: discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Edit",
+				Style:    discordgo.SecondaryButton,
+				CustomID: EditButton,
+				Emoji: &discordgo.ComponentEmoji{
+					Name: "✏️",
+				},
+			},
+			discordgo.Button{
+				Label:    "Favorite",
+				Style:    discordgo.SecondaryButton,
+				CustomID: FavoriteButton,
+				Emoji: &discordgo.ComponentEmoji{
+					Name: "⭐",
+				},
+			},
+		},
+	},
+
 	CheckpointSelect:   modelSelectMenu(CheckpointSelect),
 	VAESelect:          modelSelectMenu(VAESelect),
 	HypernetworkSelect: modelSelectMenu(HypernetworkSelect),
@@ -124,6 +204,27 @@ var components = map[customID]discordgo.MessageComponent{
 		},
 	},
 
+	SettingsMoreButton: discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "More settings",
+				Style:    discordgo.PrimaryButton,
+				CustomID: SettingsMoreButton,
+				Emoji: &discordgo.ComponentEmoji{
+					Name: "⚙️",
+				},
+			},
+			discordgo.Button{
+				Label:    "Reset",
+				Style:    discordgo.DangerButton,
+				CustomID: SettingsResetButton,
+				Emoji: &discordgo.ComponentEmoji{
+					Name: "🔄",
+				},
+			},
+		},
+	},
+
 	JSONInput: discordgo.ActionsRow{
 		Components: []discordgo.MessageComponent{
 			discordgo.TextInput{
@@ -165,6 +266,40 @@ func modelSelectMenu(ID customID) discordgo.ActionsRow {
 	}
 }
 
+// componentCommands are the commands the components and modals belong to, by the prefix of their custom ID.
+// The more specific prefixes come first, as most of the custom IDs start with imagine_.
+var componentCommands = []struct {
+	prefix  customID
+	command Command
+}{
+	{GalleryReimagineButton, GalleryCommand},
+	{GalleryUpscaleButton, GalleryCommand},
+	{HistoryReuseButton, HistoryCommand},
+	{HistoryEditButton, HistoryCommand},
+	{UseImageSelect, UseImageMessage},
+	{CheckpointSelect, ImagineSettingsCommand},
+	{VAESelect, ImagineSettingsCommand},
+	{HypernetworkSelect, ImagineSettingsCommand},
+	{DimensionSelect, ImagineSettingsCommand},
+	{BatchCountSelect, ImagineSettingsCommand},
+	{BatchSizeSelect, ImagineSettingsCommand},
+	{SettingsMoreButton, ImagineSettingsCommand},
+	{SettingsResetButton, ImagineSettingsCommand},
+	{SettingsModal, ImagineSettingsCommand},
+	{JSONInput, RawCommand},
+	{"imagine_", ImagineCommand},
+}
+
+// CommandOf returns the command the component or modal belongs to, see queue.CommandOwner.
+func (q *SDQueue) CommandOf(customID string) (queue.Command, bool) {
+	for _, owner := range componentCommands {
+		if strings.HasPrefix(customID, owner.prefix) {
+			return owner.command, true
+		}
+	}
+	return "", false
+}
+
 func (q *SDQueue) components() map[string]queue.Handler {
 	h := map[string]queue.Handler{
 		DimensionSelect: func(s *discordgo.Session, i *discordgo.InteractionCreate) error {
@@ -190,10 +325,13 @@ func (q *SDQueue) components() map[string]queue.Handler {
 			return q.processImagineDimensionSetting(s, i, widthInt, heightInt)
 		},
 
-		CheckpointSelect:   q.processImagineModelSetting,
-		VAESelect:          q.processImagineModelSetting,
+		CheckpointSelect:   q.processProfileModelSetting,
+		VAESelect:          q.processProfileModelSetting,
 		HypernetworkSelect: q.processImagineModelSetting,
 
+		SettingsMoreButton:  q.processSettingsMoreButton,
+		SettingsResetButton: q.processSettingsResetButton,
+
 		BatchCountSelect: func(s *discordgo.Session, i *discordgo.InteractionCreate) error {
 			if len(i.MessageComponentData().Values) == 0 {
 				return errors.New("no values for imagine batch count setting menu")
@@ -252,9 +390,25 @@ func (q *SDQueue) components() map[string]queue.Handler {
 			return q.processImagineBatchSetting(s, i, batchCountInt, batchSizeInt)
 		},
 
-		RerollButton:  q.processImagineReroll,
-		UpscaleButton: q.upscaleComponentHandler,
-		VariantButton: q.variantComponentHandler,
+		RerollButton:         q.processImagineReroll,
+		OriginalPromptButton: q.processImagineReroll,
+		EditButton:           q.processEditButton,
+		UpscaleButton:        q.upscaleComponentHandler,
+		VariantButton:        q.variantComponentHandler,
+
+		ImageSelect:        q.processImageSelect,
+		PickUpscaleButton:  q.processPickUpscale,
+		PickVariantButton:  q.processPickVariation,
+		PickImg2ImgButton:  q.processPickImg2Img,
+		PickDownloadButton: q.processPickDownload,
+		PickFavoriteButton: q.processPickFavorite,
+
+		UseImageSelect: q.processUseImageSelect,
+
+		FavoriteButton:              q.processFavoriteButton,
+		FavoriteSelect:              q.processFavoriteSelect,
+		handlers.PaginationPrevious: q.processPagination,
+		handlers.PaginationNext:     q.processPagination,
 
 		handlers.Cancel:    q.removeImagineFromQueue, // Cancel button is used when still in queue
 		handlers.Interrupt: q.interrupt,              // Interrupt button is used when currently generating, using the api.Interrupt() method
@@ -263,6 +417,13 @@ func (q *SDQueue) components() map[string]queue.Handler {
 	for i := range 4 {
 		h[UpscaleButton+"_"+strconv.Itoa(i+1)] = q.upscaleComponentHandler
 		h[VariantButton+"_"+strconv.Itoa(i+1)] = q.variantComponentHandler
+		h[GalleryReimagineButton+"_"+strconv.Itoa(i+1)] = q.processGalleryReimagine
+		h[GalleryUpscaleButton+"_"+strconv.Itoa(i+1)] = q.processGalleryUpscale
+	}
+
+	for i := range historyPageSize {
+		h[HistoryReuseButton+"_"+strconv.Itoa(i+1)] = q.processHistoryReuse
+		h[HistoryEditButton+"_"+strconv.Itoa(i+1)] = q.processHistoryEdit
 	}
 
 	return h
@@ -292,6 +453,14 @@ func (q *SDQueue) variantComponentHandler(s *discordgo.Session, i *discordgo.Int
 	return q.processImagineVariation(s, i, interactionIndexInt)
 }
 
+// rerollType returns ItemTypeOriginal when the original prompt button was pressed, to reroll without the LLM enhancement.
+func rerollType(i *discordgo.InteractionCreate) ItemType {
+	if i.MessageComponentData().CustomID == OriginalPromptButton {
+		return ItemTypeOriginal
+	}
+	return ItemTypeReroll
+}
+
 func (q *SDQueue) processImagineReroll(s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	position, queueError := q.Add(&SDQueueItem{
 		ImageGenerationRequest: &entities.ImageGenerationRequest{
@@ -303,7 +472,7 @@ func (q *SDQueue) processImagineReroll(s *discordgo.Session, i *discordgo.Intera
 			},
 			TextToImageRequest: new(entities.TextToImageRequest),
 		},
-		Type:               ItemTypeReroll,
+		Type:               rerollType(i),
 		DiscordInteraction: i.Interaction,
 	})
 	if queueError != nil {
@@ -371,29 +540,37 @@ func (q *SDQueue) processImagineVariation(s *discordgo.Session, i *discordgo.Int
 
 // check if the user using the cancel button is the same user that started the generation, then remove it from the queue
 func (q *SDQueue) removeImagineFromQueue(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if utils.GetUser(i.Interaction).ID != i.Message.InteractionMetadata.User.ID {
+	metadata := handlers.MessageInteractionMetadata(i.Message)
+	if metadata == nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Unable to determine who started this generation")
+	}
+	if utils.GetUser(i.Interaction).ID != metadata.User.ID {
 		return handlers.ErrorEphemeral(s, i.Interaction, "You can only cancel your own generations")
 	}
 
-	log.Printf("Removing imagine from queue: %#v", i.Message.InteractionMetadata)
+	log.Printf("Removing imagine from queue: %#v", metadata)
 
-	err := q.Remove(i.Message.InteractionMetadata)
+	err := q.Remove(metadata)
 	if err != nil {
 		log.Printf("Error removing imagine from queue: %v", err)
 		return handlers.ErrorEdit(s, i.Interaction, "Error removing imagine from queue")
 	}
-	log.Printf("Removed imagine from queue: %#v", i.Message.InteractionMetadata)
+	log.Printf("Removed imagine from queue: %#v", metadata)
 
 	return handlers.UpdateFromComponent(s, i.Interaction, "Generation cancelled", handlers.Components[handlers.DeleteButton])
 }
 
 // check if the user using the interrupt button is the same user that started the generation
 func (q *SDQueue) interrupt(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if utils.GetUser(i.Interaction).ID != i.Message.InteractionMetadata.User.ID {
+	metadata := handlers.MessageInteractionMetadata(i.Message)
+	if metadata == nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Unable to determine who started this generation")
+	}
+	if utils.GetUser(i.Interaction).ID != metadata.User.ID {
 		return handlers.ErrorEphemeral(s, i.Interaction, "You can only interrupt your own generations")
 	}
 
-	log.Printf("Interrupting generation: %#v", i.Message.InteractionMetadata)
+	log.Printf("Interrupting generation: %#v", metadata)
 
 	err := q.Interrupt(i.Interaction)
 	if err != nil {
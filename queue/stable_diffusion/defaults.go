@@ -1,14 +1,18 @@
 package stable_diffusion
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
 
 	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
 )
 
 func (q *SDQueue) fillInBotDefaults(settings *entities.DefaultSettings) (*entities.DefaultSettings, bool) {
@@ -186,6 +190,80 @@ func (q *SDQueue) UpdateModelName(modelName string) (*entities.DefaultSettings,
 	return newDefaultSettings, nil
 }
 
+// applyMemberDefaults resolves the defaults of the item for the member of its interaction:
+// their own settings come first, then the settings of the server, then the bot defaults of DefaultQueueItem.
+func (q *SDQueue) applyMemberDefaults(item *SDQueueItem) {
+	request := item.ImageGenerationRequest
+	if item.DiscordInteraction == nil || request == nil {
+		return
+	}
+
+	var memberCheckpoint string
+	if user := utils.GetUser(item.DiscordInteraction); user != nil {
+		settings, err := q.defaultSettingsRepo.GetByMemberID(context.Background(), user.ID)
+		switch {
+		case err == nil:
+			applyDefaultSettings(item, settings)
+			memberCheckpoint = settings.Checkpoint
+		case !errors.Is(err, &repositories.NotFoundError{}):
+			log.Printf("Error retrieving default settings for %s: %v", user.Username, err)
+		}
+	}
+
+	guild := q.guilds.Settings(item.DiscordInteraction.GuildID)
+	if memberCheckpoint == "" && guild.DefaultCheckpoint != "" {
+		checkpoint := guild.DefaultCheckpoint
+		request.Checkpoint = &checkpoint
+	}
+
+	// the defaults are shrunk to fit the server, while the sizes asked for are refused by checkSize instead
+	if guild.MaxWidth > 0 {
+		request.Width = min(request.Width, guild.MaxWidth)
+	}
+	if guild.MaxHeight > 0 {
+		request.Height = min(request.Height, guild.MaxHeight)
+	}
+}
+
+// applyNSFWPolicy keeps NSFW images out of the channels of servers that don't allow them.
+// It's applied when the item is processed, as rerolls take the prompts of the previous generation then.
+func (q *SDQueue) applyNSFWPolicy(item *SDQueueItem) {
+	if item.ImageGenerationRequest == nil || q.guilds.AllowsNSFW(q.botSession, item.DiscordInteraction) {
+		return
+	}
+	item.NegativePrompt = queue.AvoidNSFW(item.NegativePrompt)
+}
+
+// checkSize refuses images larger than the server of the item allows. It's checked right before the request is sent,
+// once the aspect ratio and the hires fix have been applied, as rerolls only get their size from the previous generation then.
+func (q *SDQueue) checkSize(item *SDQueueItem, width, height int) error {
+	guild := q.guilds.Settings(item.DiscordInteraction.GuildID)
+	if guild.FitsSize(width, height) {
+		return nil
+	}
+	return fmt.Errorf("%dx%d is larger than this server allows (%s)", width, height, maxSize(guild))
+}
+
+// outputSize is the size of the images generated for the request, after the hires fix upscaled them.
+func outputSize(textToImage *entities.TextToImageRequest) (width, height int) {
+	if !textToImage.EnableHr {
+		return textToImage.Width, textToImage.Height
+	}
+	return cmp.Or(textToImage.HrResizeX, scaleDimension(textToImage.Width, textToImage.HrScale)),
+		cmp.Or(textToImage.HrResizeY, scaleDimension(textToImage.Height, textToImage.HrScale))
+}
+
+// maxSize describes the size limits of the server.
+func maxSize(guild *entities.GuildSettings) string {
+	size := func(limit int) string {
+		if limit == 0 {
+			return "any"
+		}
+		return strconv.Itoa(limit)
+	}
+	return fmt.Sprintf("at most %sx%s", size(guild.MaxWidth), size(guild.MaxHeight))
+}
+
 // input is 2:3 for example, without the `--ar` part
 func aspectRatioCalculation(aspectRatio string, w, h int) (width, height int) {
 	// split
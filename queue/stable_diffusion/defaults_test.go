@@ -0,0 +1,48 @@
+package stable_diffusion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
+	"stable_diffusion_bot/repositories/guild_settings"
+)
+
+// fakeGuildSettings only returns the settings of the server it holds.
+type fakeGuildSettings struct {
+	guild_settings.Repository
+	settings *entities.GuildSettings
+}
+
+func (f fakeGuildSettings) GetByGuildID(context.Context, string) (*entities.GuildSettings, error) {
+	return f.settings, nil
+}
+
+func TestCheckSize(t *testing.T) {
+	q := &SDQueue{guilds: queue.NewGuilds(fakeGuildSettings{
+		settings: &entities.GuildSettings{GuildID: "guild", MaxWidth: 1024, MaxHeight: 1024},
+	})}
+	item := &SDQueueItem{DiscordInteraction: &discordgo.Interaction{GuildID: "guild"}}
+
+	for _, tt := range []struct {
+		name        string
+		textToImage entities.TextToImageRequest
+		fits        bool
+	}{
+		{"within the limit", entities.TextToImageRequest{Width: 1024, Height: 768}, true},
+		{"too wide", entities.TextToImageRequest{Width: 1152, Height: 768}, false},
+		{"hires fix", entities.TextToImageRequest{Width: 768, Height: 512, EnableHr: true, HrScale: 1.5}, false},
+		{"hires fix within the limit", entities.TextToImageRequest{Width: 512, Height: 512, EnableHr: true, HrScale: 2}, true},
+		{"hires resize", entities.TextToImageRequest{Width: 512, Height: 512, EnableHr: true, HrScale: 2, HrResizeX: 1536, HrResizeY: 1024}, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			width, height := outputSize(&tt.textToImage)
+			if err := q.checkSize(item, width, height); (err == nil) != tt.fits {
+				t.Errorf("Expected %dx%d to fit: %v, got %v", width, height, tt.fits, err)
+			}
+		})
+	}
+}
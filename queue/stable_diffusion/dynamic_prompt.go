@@ -0,0 +1,181 @@
+package stable_diffusion
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+// maxWildcardDepth limits how deep the values of wildcards can refer to other wildcards, in case they refer to each other.
+const maxWildcardDepth = 10
+
+// wildcardRegex matches a wildcard such as __hair_color__ or __colors/warm__.
+var wildcardRegex = regexp.MustCompile(`^__([\w\-/]+?)__`)
+
+// dynamicPrompt is the prompt of a single image after its dynamic syntax was expanded.
+type dynamicPrompt struct {
+	Prompt         string
+	NegativePrompt string
+}
+
+// promptExpander picks the alternatives of {a|b|c} and the values of __wildcards__ in a prompt.
+type promptExpander struct {
+	rand   *rand.Rand
+	lookup func(name string) []string // returns the values of the wildcard, or nil if it doesn't exist
+}
+
+// isDynamicPrompt reports whether the prompt might contain alternatives or wildcards.
+func isDynamicPrompt(prompt string) bool {
+	return (strings.Contains(prompt, "{") && strings.Contains(prompt, "|")) || strings.Contains(prompt, "__")
+}
+
+// expand replaces the dynamic syntax of the prompt. Braces without alternatives, such as {prompt},
+// and wildcards that don't exist are kept as they are.
+func (e *promptExpander) expand(prompt string, depth int) string {
+	var out strings.Builder
+	for i := 0; i < len(prompt); {
+		switch {
+		case prompt[i] == '{':
+			end := matchingBrace(prompt, i)
+			if end < 0 {
+				out.WriteByte(prompt[i])
+				i++
+				continue
+			}
+			options := splitAlternatives(prompt[i+1 : end])
+			if len(options) < 2 {
+				out.WriteByte(prompt[i])
+				i++
+				continue
+			}
+			out.WriteString(e.expand(options[e.rand.IntN(len(options))], depth))
+			i = end + 1
+		case strings.HasPrefix(prompt[i:], "__"):
+			match := wildcardRegex.FindStringSubmatch(prompt[i:])
+			if match == nil {
+				out.WriteString("__")
+				i += 2
+				continue
+			}
+			if values := e.lookup(match[1]); len(values) > 0 && depth < maxWildcardDepth {
+				out.WriteString(e.expand(values[e.rand.IntN(len(values))], depth+1))
+			} else {
+				out.WriteString(match[0])
+			}
+			i += len(match[0])
+		default:
+			out.WriteByte(prompt[i])
+			i++
+		}
+	}
+	return out.String()
+}
+
+// matchingBrace returns the index of the brace closing the one at start, or -1 if it's never closed.
+func matchingBrace(prompt string, start int) int {
+	depth := 0
+	for i := start; i < len(prompt); i++ {
+		switch prompt[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits the inside of braces by the | that aren't nested in other braces.
+func splitAlternatives(inner string) []string {
+	var options []string
+	depth, last := 0, 0
+	for i := 0; i < len(inner); i++ {
+		switch inner[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '|':
+			if depth == 0 {
+				options = append(options, inner[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(options, inner[last:])
+}
+
+// imageSeed is the seed the API uses for the image at idx of a batch starting at seed.
+// Variations keep the seed and change the subseed of each image instead.
+func imageSeed(request *entities.TextToImageRequest, idx int) int64 {
+	if request.SubseedStrength > 0 {
+		return request.Seed
+	}
+	return request.Seed + int64(idx)
+}
+
+// expandPrompts expands the prompts of each image of the request from the seed of the image,
+// so that the same seed always resolves to the same prompt.
+func expandPrompts(request *entities.TextToImageRequest, lookup func(name string) []string) []dynamicPrompt {
+	prompts := make([]dynamicPrompt, request.NIter*request.BatchSize)
+	for idx := range prompts {
+		expander := promptExpander{
+			rand:   rand.New(rand.NewPCG(uint64(imageSeed(request, idx)), 0)),
+			lookup: lookup,
+		}
+		prompts[idx] = dynamicPrompt{
+			Prompt:         expander.expand(request.Prompt, 0),
+			NegativePrompt: expander.expand(request.NegativePrompt, 0),
+		}
+	}
+	return prompts
+}
+
+// expandDynamicPrompts resolves the dynamic syntax of the prompts of the item for each of its images.
+// A random seed is picked beforehand so the prompts can be reproduced from the seeds of the images.
+func (q *SDQueue) expandDynamicPrompts(item *SDQueueItem) {
+	request := item.TextToImageRequest
+	if !isDynamicPrompt(request.Prompt) && !isDynamicPrompt(request.NegativePrompt) {
+		return
+	}
+
+	if request.Seed < 0 {
+		request.Seed = rand.Int64N(1 << 32)
+	}
+
+	totalImageCount(item.ImageGenerationRequest)
+	item.prompts = expandPrompts(request, q.wildcardLookup(item.DiscordInteraction.GuildID))
+}
+
+// wildcardLookup returns the values of the wildcards of the guild, remembering the ones already retrieved.
+func (q *SDQueue) wildcardLookup(guildID string) func(name string) []string {
+	retrieved := make(map[string][]string)
+	return func(name string) []string {
+		if values, ok := retrieved[name]; ok {
+			return values
+		}
+
+		var values []string
+		if q.wildcardRepo != nil && guildID != "" {
+			wildcard, err := q.wildcardRepo.GetByName(context.Background(), guildID, name)
+			switch {
+			case err == nil:
+				values = wildcard.Values
+			case !errors.Is(err, &repositories.NotFoundError{}):
+				log.Printf("Error retrieving wildcard %s: %v", name, err)
+			}
+		}
+
+		retrieved[name] = values
+		return values
+	}
+}
@@ -0,0 +1,53 @@
+package stable_diffusion
+
+import (
+	"strings"
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestExpandPrompts(t *testing.T) {
+	wildcards := map[string][]string{
+		"color":  {"red", "blue", "green"},
+		"animal": {"__color__ cat", "__color__ dog"},
+		"loop":   {"__loop__"},
+	}
+	lookup := func(name string) []string { return wildcards[name] }
+
+	request := &entities.TextToImageRequest{
+		Prompt:    "a __animal__ in a {sunny|rainy|{foggy|snowy}} {prompt} __unknown__, __loop__",
+		Seed:      1234,
+		BatchSize: 4,
+		NIter:     2,
+	}
+
+	prompts := expandPrompts(request, lookup)
+	if len(prompts) != 8 {
+		t.Fatalf("Expected a prompt for each of the 8 images, got %d", len(prompts))
+	}
+
+	for idx, prompt := range prompts {
+		if strings.ContainsAny(prompt.Prompt, "|") || strings.Contains(prompt.Prompt, "__animal__") || strings.Contains(prompt.Prompt, "__color__") {
+			t.Errorf("Expected the dynamic syntax of image %d to be expanded, got %q", idx, prompt.Prompt)
+		}
+		if !strings.Contains(prompt.Prompt, "{prompt} __unknown__, __loop__") {
+			t.Errorf("Expected the braces without alternatives and the unknown wildcards to be kept, got %q", prompt.Prompt)
+		}
+	}
+
+	// the prompt of an image only depends on its own seed
+	single := *request
+	single.Seed, single.BatchSize, single.NIter = request.Seed+3, 1, 1
+	if again := expandPrompts(&single, lookup); again[0] != prompts[3] {
+		t.Errorf("Expected seed %d to expand to %q again, got %q", single.Seed, prompts[3].Prompt, again[0].Prompt)
+	}
+
+	distinct := make(map[dynamicPrompt]bool)
+	for _, prompt := range prompts {
+		distinct[prompt] = true
+	}
+	if len(distinct) < 2 {
+		t.Errorf("Expected the images to have different prompts, got %q for all of them", prompts[0].Prompt)
+	}
+}
@@ -0,0 +1,211 @@
+package stable_diffusion
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+// processEditButton responds with a modal filled in with the settings of the generation of the message.
+func (q *SDQueue) processEditButton(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	generation, err := q.imageGenerationRepo.GetByMessage(context.Background(), i.Message.ID)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Could not find the generation of this message.", err)
+	}
+
+	return q.showEditModal(s, i, generation)
+}
+
+// showEditModal responds with the modal to edit the generation, which carries the message and the sort order
+// of the generation in its custom ID for processEditModal.
+func (q *SDQueue) showEditModal(s *discordgo.Session, i *discordgo.InteractionCreate, generation *entities.ImageGenerationRequest) error {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID:   handlers.WithArguments(EditModal, generation.MessageID, strconv.Itoa(generation.SortOrder)),
+			Title:      "Edit and rerun",
+			Components: editModalComponents(generation),
+		},
+	})
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error showing the edit modal.", err)
+	}
+	return nil
+}
+
+// editedGeneration returns the generation of the modal made by showEditModal.
+func (q *SDQueue) editedGeneration(customID string) (*entities.ImageGenerationRequest, error) {
+	_, arguments := handlers.Arguments(customID)
+	if len(arguments) != 2 {
+		return nil, fmt.Errorf("the modal %s has no generation", customID)
+	}
+
+	sortOrder, err := strconv.Atoi(arguments[1])
+	if err != nil {
+		return nil, err
+	}
+	return q.imageGenerationRepo.GetByMessageAndSort(context.Background(), arguments[0], sortOrder)
+}
+
+func editModalComponents(generation *entities.ImageGenerationRequest) []discordgo.MessageComponent {
+	textInput := func(id customID, label string, style discordgo.TextInputStyle, value string, required bool) discordgo.MessageComponent {
+		return discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:  id,
+					Label:     label,
+					Style:     style,
+					Value:     truncate(value, 4000),
+					Required:  required,
+					MaxLength: 4000,
+				},
+			},
+		}
+	}
+
+	return []discordgo.MessageComponent{
+		textInput(EditPromptInput, "Prompt", discordgo.TextInputParagraph, generation.Prompt, true),
+		textInput(EditNegativeInput, "Negative prompt", discordgo.TextInputParagraph, generation.NegativePrompt, false),
+		textInput(EditSettingsInput, "Steps, CFG scale and seed (-1 for random)", discordgo.TextInputShort, editSettings(generation), false),
+		textInput(EditSizeInput, "Size", discordgo.TextInputShort, fmt.Sprintf("%dx%d", generation.Width, generation.Height), false),
+		textInput(EditExtrasInput, "Extras, one \"Key: value\" per line", discordgo.TextInputParagraph, editExtras(generation), false),
+	}
+}
+
+// editSettings writes the steps, CFG scale and seed the same way as the parameters of the web UI.
+func editSettings(generation *entities.ImageGenerationRequest) string {
+	return fmt.Sprintf("Steps: %d, CFG scale: %s, Seed: %d",
+		generation.Steps,
+		strconv.FormatFloat(generation.CFGScale, 'f', -1, 64),
+		generation.Seed,
+	)
+}
+
+// editExtras writes the rest of the settings read by applyParameter, one per line.
+func editExtras(generation *entities.ImageGenerationRequest) string {
+	var lines []string
+	add := func(key, value string) {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+	}
+	formatFloat := func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }
+
+	if generation.SamplerName != "" {
+		add("Sampler", generation.SamplerName)
+	}
+	if checkpoint := safeDereference(generation.Checkpoint); checkpoint != "" {
+		add("Model", checkpoint)
+	}
+	if vae := safeDereference(generation.VAE); vae != "" {
+		add("VAE", vae)
+	}
+	if clipSkip := generation.OverrideSettings.CLIPStopAtLastLayers; clipSkip > 0 {
+		add("Clip skip", formatFloat(clipSkip))
+	}
+	if generation.EnableHr {
+		add("Hires upscale", formatFloat(generation.HrScale))
+		add("Hires upscaler", generation.HrUpscaler)
+		add("Hires steps", strconv.FormatInt(generation.HrSecondPassSteps, 10))
+		add("Denoising strength", formatFloat(generation.DenoisingStrength))
+	}
+	if generation.SubseedStrength > 0 {
+		add("Variation seed", strconv.FormatInt(generation.Subseed, 10))
+		add("Variation seed strength", formatFloat(generation.SubseedStrength))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// processEditModal queues the generation of the modal with its settings,
+// recording it as the parent of the new generation.
+func (q *SDQueue) processEditModal(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	user := utils.GetUser(i.Interaction)
+	parent, err := q.editedGeneration(i.ModalSubmitData().CustomID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "The generation to edit was not found, please press Edit again.", err)
+	}
+
+	modalData := getModalData(i.ModalSubmitData())
+	value := func(id customID) string {
+		if input, ok := modalData[handlers.Component(id)]; ok && input != nil {
+			return strings.TrimSpace(input.Value)
+		}
+		return ""
+	}
+
+	item := q.NewItem(i.Interaction)
+	textToImage := *parent.TextToImageRequest
+	item.ImageGenerationRequest = &entities.ImageGenerationRequest{
+		GenerationInfo: entities.GenerationInfo{
+			InteractionID: i.Interaction.ID,
+			MemberID:      user.ID,
+			Checkpoint:    parent.Checkpoint,
+			VAE:           parent.VAE,
+			Hypernetwork:  parent.Hypernetwork,
+			CreatedAt:     time.Now(),
+			ParentID:      parent.ID,
+			Style:         parent.Style,
+		},
+		TextToImageRequest: &textToImage,
+	}
+	request := item.ImageGenerationRequest
+
+	request.Prompt = cmp.Or(value(EditPromptInput), parent.Prompt)
+	request.NegativePrompt = value(EditNegativeInput)
+
+	for _, match := range parameterRegex.FindAllStringSubmatch(value(EditSettingsInput), -1) {
+		applyParameter(request, match[1], unquote(match[2]))
+	}
+	if size := value(EditSizeInput); size != "" {
+		applyParameter(request, "Size", size)
+	}
+
+	// the hires fix is only kept when it's still in the extras
+	request.EnableHr = false
+	request.SubseedStrength = 0
+	for _, line := range strings.Split(value(EditExtrasInput), "\n") {
+		key, setting, found := strings.Cut(line, ":")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !found || !applyParameter(request, strings.TrimSpace(key), strings.TrimSpace(setting)) {
+			return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("Unknown extra setting `%s`, use one \"Key: value\" per line.", strings.TrimSpace(line)))
+		}
+	}
+
+	position, err := q.Add(item)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error adding imagine to queue.", err)
+	}
+
+	queueString := fmt.Sprintf(
+		"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine \n```\n%s\n```",
+		position,
+		user.ID,
+		request.Prompt,
+	)
+
+	message, err := handlers.EditInteractionResponse(s, i.Interaction, queueString, handlers.Components[handlers.Cancel])
+	if err != nil {
+		return err
+	}
+	if item.DiscordInteraction.Message == nil && message != nil {
+		log.Printf("Setting message ID for interaction %v", item.DiscordInteraction.ID)
+		item.DiscordInteraction.Message = message
+	}
+
+	return nil
+}
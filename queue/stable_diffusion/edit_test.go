@@ -0,0 +1,88 @@
+package stable_diffusion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories/image_generations"
+)
+
+// fakeGenerations finds the generations it holds by their message and sort order.
+type fakeGenerations struct {
+	image_generations.Repository
+	generations []*entities.ImageGenerationRequest
+}
+
+func (f fakeGenerations) GetByMessageAndSort(_ context.Context, messageID string, sortOrder int) (*entities.ImageGenerationRequest, error) {
+	for _, generation := range f.generations {
+		if generation.MessageID == messageID && generation.SortOrder == sortOrder {
+			return generation, nil
+		}
+	}
+	return nil, fmt.Errorf("no generation %d of %s", sortOrder, messageID)
+}
+
+func TestEditExtras(t *testing.T) {
+	checkpoint := "sd_xl_base_1.0"
+	generation := &entities.ImageGenerationRequest{
+		GenerationInfo: entities.GenerationInfo{Checkpoint: &checkpoint},
+		TextToImageRequest: &entities.TextToImageRequest{
+			SamplerName:       "Euler a",
+			EnableHr:          true,
+			HrScale:           1.5,
+			HrUpscaler:        "Latent",
+			HrSecondPassSteps: 10,
+			DenoisingStrength: 0.4,
+			Subseed:           42,
+			SubseedStrength:   0.25,
+		},
+	}
+	generation.OverrideSettings.CLIPStopAtLastLayers = 2
+
+	request := &entities.ImageGenerationRequest{TextToImageRequest: &entities.TextToImageRequest{}}
+	for _, line := range strings.Split(editExtras(generation), "\n") {
+		key, value, _ := strings.Cut(line, ":")
+		if !applyParameter(request, key, strings.TrimSpace(value)) {
+			t.Errorf("Expected %q to be a known setting", key)
+		}
+	}
+
+	if request.SamplerName != "Euler a" || safeDereference(request.Checkpoint) != checkpoint || request.OverrideSettings.CLIPStopAtLastLayers != 2 {
+		t.Errorf("Expected Euler a on %s with clip skip 2, got %s on %s with clip skip %v",
+			checkpoint, request.SamplerName, safeDereference(request.Checkpoint), request.OverrideSettings.CLIPStopAtLastLayers)
+	}
+	if !request.EnableHr || request.HrScale != 1.5 || request.HrUpscaler != "Latent" || request.HrSecondPassSteps != 10 || request.DenoisingStrength != 0.4 {
+		t.Errorf("Expected the hires fix to be read back, got %+v", request.TextToImageRequest)
+	}
+	if request.Subseed != 42 || request.SubseedStrength != 0.25 {
+		t.Errorf("Expected variation seed 42 at 0.25, got %d at %v", request.Subseed, request.SubseedStrength)
+	}
+}
+
+func TestEditedGeneration(t *testing.T) {
+	generations := []*entities.ImageGenerationRequest{
+		{GenerationInfo: entities.GenerationInfo{ID: 1, MessageID: "message"}},
+		{GenerationInfo: entities.GenerationInfo{ID: 2, MessageID: "history", SortOrder: 3}},
+	}
+	q := &SDQueue{imageGenerationRepo: fakeGenerations{generations: generations}}
+
+	// modals opened one after the other each rerun their own generation
+	for _, want := range generations {
+		customID := handlers.WithArguments(EditModal, want.MessageID, fmt.Sprint(want.SortOrder))
+		generation, err := q.editedGeneration(customID)
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if generation.ID != want.ID {
+			t.Errorf("Expected generation %d for %s, got %d", want.ID, customID, generation.ID)
+		}
+	}
+
+	if _, err := q.editedGeneration(EditModal); err == nil {
+		t.Error("Expected an error for a modal without a generation")
+	}
+}
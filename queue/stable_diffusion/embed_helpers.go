@@ -3,6 +3,7 @@ package stable_diffusion
 import (
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -37,6 +38,8 @@ func generationEmbedDetails(embed *discordgo.MessageEmbed, queue *SDQueueItem, i
 		embed.Title = "Variation"
 	case queue.Type == ItemTypeReroll:
 		embed.Title = "Reroll"
+	case queue.Type == ItemTypeOriginal:
+		embed.Title = "Reroll (Original Prompt)"
 	case queue.Type == ItemTypeUpscale:
 		embed.Title = "Upscale"
 	case queue.Type == ItemTypeRaw:
@@ -116,6 +119,28 @@ func generationEmbedDetails(embed *discordgo.MessageEmbed, queue *SDQueueItem, i
 		},
 	}
 
+	if request.Style != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "Style",
+			Value:  fmt.Sprintf("`%v`", request.Style),
+			Inline: true,
+		})
+	}
+
+	if request.OriginalPrompt != "" {
+		embed.Fields = append(embed.Fields,
+			&discordgo.MessageEmbedField{
+				Name:  "Original Prompt",
+				Value: fmt.Sprintf("```\n%s\n```", truncate(request.OriginalPrompt, 1000)),
+			},
+			&discordgo.MessageEmbedField{
+				Name:  "Enhanced Prompt",
+				Value: fmt.Sprintf("```\n%s\n```", truncate(request.Prompt, 1000)),
+			},
+		)
+		return embed
+	}
+
 	// only add prompt if 200 or less and not in debug mode
 	if len(queue.Prompt) <= 200 && !(queue.Raw != nil && queue.Raw.Debug) {
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
@@ -127,6 +152,14 @@ func generationEmbedDetails(embed *discordgo.MessageEmbed, queue *SDQueueItem, i
 	return embed
 }
 
+func truncate(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) <= length {
+		return s
+	}
+	return string(runes[:length]) + "..."
+}
+
 // rerollVariationComponents returns a buttons with discordgo.MessageComponent with a specified image count.
 // A maximum of 4 buttons will be returned (due to Discord's limit) plus one "Re-roll" or "Delete" button.
 // If disable is true, the Variation and Upscale buttons will be disabled.
@@ -202,3 +235,29 @@ func rerollVariationComponents(amount int, disable bool) *[]discordgo.MessageCom
 
 	return &rows
 }
+
+// imageSelectComponent returns a select menu to pick any of the images for batches with more than four,
+// which get no buttons of their own as they're tiled into one image.
+// If disable is true, the select menu will be disabled the same as the buttons of rerollVariationComponents.
+func imageSelectComponent(amount int, disable bool) discordgo.ActionsRow {
+	options := make([]discordgo.SelectMenuOption, min(amount, 25))
+	for i := range options {
+		options[i] = discordgo.SelectMenuOption{
+			Label: fmt.Sprintf("Pick image %d", i+1),
+			Value: strconv.Itoa(i + 1),
+		}
+	}
+
+	return discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.SelectMenu{
+				CustomID:    ImageSelect,
+				Placeholder: "Pick an image to upscale, vary or download",
+				MinValues:   &minValues,
+				MaxValues:   1,
+				Options:     options,
+				Disabled:    disable,
+			},
+		},
+	}
+}
@@ -0,0 +1,101 @@
+package stable_diffusion
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/utils"
+)
+
+// PromptEnhancer expands a short idea into a detailed prompt.
+// It is implemented by the LLM queue, which calls then with the response once the request has been processed.
+type PromptEnhancer interface {
+	Enhance(interaction *discordgo.Interaction, request *llm.Request, then func(*llm.Response, error)) (int, error)
+}
+
+const enhanceSystemPrompt = `You write prompts for Stable Diffusion.
+Expand the idea of the user into a single detailed prompt made of comma separated tags,
+describing the subject, composition, lighting, colors, style and medium.
+Reply with the prompt only, without any explanation, quotes or line breaks.`
+
+// enhancePrompt sends the idea to the LLM queue first, and adds the item to this queue once the enhanced prompt is ready.
+// suffix is kept after the enhanced prompt, for the embeddings and LoRAs that were appended to the idea.
+func (q *SDQueue) enhancePrompt(s *discordgo.Session, i *discordgo.InteractionCreate, item *SDQueueItem, idea, suffix string) error {
+	if q.promptEnhancer == nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Prompt enhancement is not available, as there is no LLM configured.")
+	}
+
+	item.OriginalPrompt = idea
+	item.LLMRequest = &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.SystemRole, Content: enhanceSystemPrompt},
+			llm.UserMessage(idea),
+		},
+		Temperature: 0.7,
+		MaxTokens:   256,
+	}
+
+	position, err := q.promptEnhancer.Enhance(i.Interaction, item.LLMRequest, func(response *llm.Response, err error) {
+		if err != nil {
+			_ = handlers.ErrorEdit(s, i.Interaction, "Error enhancing the prompt.", err)
+			return
+		}
+
+		q.mu.Lock()
+		cancelled := q.cancelledItems[i.Interaction.ID]
+		delete(q.cancelledItems, i.Interaction.ID)
+		q.mu.Unlock()
+		if cancelled {
+			return
+		}
+
+		item.LLMCreated = time.Now()
+		enhanced := strings.Join(strings.Fields(response.Choices[0].Message.Content), " ")
+		item.Prompt = strings.Trim(enhanced, `"`) + suffix
+		item.applyStyleTemplates()
+		log.Printf("Enhanced prompt for %s: %q -> %q", utils.GetUsername(i.Interaction), idea, item.Prompt)
+
+		position, err := q.Add(item)
+		if err != nil {
+			_ = handlers.ErrorEdit(s, i.Interaction, "Error adding imagine to queue.", err)
+			return
+		}
+
+		queueString := fmt.Sprintf(
+			"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine \n```\n%s\n```\nEnhanced to\n```\n%s\n```",
+			position,
+			utils.GetUser(i.Interaction).ID,
+			idea,
+			item.Prompt,
+		)
+		if _, err := handlers.EditInteractionResponse(s, i.Interaction, queueString, handlers.Components[handlers.Cancel]); err != nil {
+			log.Printf("Error showing enhanced prompt: %v", err)
+		}
+	})
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error adding prompt enhancement to the LLM queue.", err)
+	}
+
+	queueString := fmt.Sprintf(
+		"I'm enhancing your prompt first. You are currently #%d in line for the LLM.\n<@%s> asked me to imagine \n```\n%s\n```",
+		position,
+		utils.GetUser(i.Interaction).ID,
+		idea,
+	)
+
+	message, err := handlers.EditInteractionResponse(s, i.Interaction, queueString, handlers.Components[handlers.Cancel])
+	if err != nil {
+		return err
+	}
+	if item.DiscordInteraction != nil && item.DiscordInteraction.Message == nil && message != nil {
+		item.DiscordInteraction.Message = message
+	}
+
+	return nil
+}
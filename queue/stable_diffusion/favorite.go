@@ -0,0 +1,113 @@
+package stable_diffusion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories/favorites"
+	"stable_diffusion_bot/utils"
+)
+
+// processFavoriteButton stars the image of the message, or asks the member which one to star when there are more.
+func (q *SDQueue) processFavoriteButton(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	generation, err := q.imageGenerationRepo.GetByMessage(context.Background(), i.Message.ID)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Could not find the generation of this message.", err)
+	}
+
+	total := totalImageCount(generation)
+	if total == 1 {
+		return q.saveFavorite(s, i, 1)
+	}
+
+	options := make([]discordgo.SelectMenuOption, min(total, 25))
+	for index := range options {
+		options[index] = discordgo.SelectMenuOption{
+			Label: fmt.Sprintf("Star image %d", index+1),
+			Value: strconv.Itoa(index + 1),
+		}
+	}
+
+	return handlers.Wrap(s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: "Which image would you like to add to your favorites?",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.SelectMenu{
+							CustomID:  handlers.WithArguments(FavoriteSelect, i.Message.ID),
+							MinValues: &minValues,
+							MaxValues: 1,
+							Options:   options,
+						},
+					},
+				},
+			},
+		},
+	}))
+}
+
+func (q *SDQueue) processFavoriteSelect(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if _, err := targetMessage(s, i, i.MessageComponentData().CustomID); err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The message was not found, please press Favorite again.", err)
+	}
+
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return handlers.ErrorEphemeral(s, i.Interaction, "No image was picked.")
+	}
+
+	index, err := strconv.Atoi(values[0])
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "error parsing image index", err)
+	}
+
+	return q.saveFavorite(s, i, index)
+}
+
+func (q *SDQueue) processPickFavorite(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, ok := pickedMessage(s, i)
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The picked image was not found, please pick it again.")
+	}
+	return q.saveFavorite(s, i, index)
+}
+
+// saveFavorite stars the image at index of the message of the interaction for the member.
+// Images of batches tiled into one image are referenced by the URL of the tile.
+func (q *SDQueue) saveFavorite(s *discordgo.Session, i *discordgo.InteractionCreate, index int) error {
+	attachments := imageAttachments(i.Message)
+	if len(attachments) == 0 {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The message has no images.")
+	}
+
+	attachment := attachments[0]
+	if len(attachments) > 1 && index <= len(attachments) {
+		attachment = attachments[index-1]
+	}
+
+	_, err := q.favoriteRepo.Create(context.Background(), &entities.Favorite{
+		MemberID:      utils.GetUser(i.Interaction).ID,
+		GuildID:       i.GuildID,
+		ChannelID:     i.Message.ChannelID,
+		MessageID:     i.Message.ID,
+		SortOrder:     index,
+		AttachmentURL: attachment.URL,
+	})
+	switch {
+	case errors.Is(err, favorites.ErrAlreadyFavorite):
+		return handlers.EphemeralContent(s, i.Interaction, fmt.Sprintf("Image %d is already in your favorites.", index))
+	case err != nil:
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error saving the favorite.", err)
+	}
+
+	return handlers.EphemeralContent(s, i.Interaction, fmt.Sprintf("⭐ Added image %d to your favorites. Use `/%s` to see them.", index, GalleryCommand))
+}
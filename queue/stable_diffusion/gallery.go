@@ -0,0 +1,314 @@
+package stable_diffusion
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories/favorites"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	GalleryCommand Command = "gallery"
+
+	galleryUserOption   = "user"
+	galleryServerOption = "server"
+	gallerySinceOption  = "since"
+
+	// galleryPageSize is the number of favorites on a page, the same as the images with buttons of a generation.
+	galleryPageSize = 4
+
+	// pageExpiry is how long the buttons of a page shown by /gallery or /history keep working.
+	// The pages are only kept in memory until then, so they are also forgotten on a restart.
+	pageExpiry = time.Hour
+)
+
+var galleryOptions = []*discordgo.ApplicationCommandOption{
+	{
+		Type:        discordgo.ApplicationCommandOptionUser,
+		Name:        galleryUserOption,
+		Description: "Show the favorites of another member of the server",
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        galleryServerOption,
+		Description: "Show the favorites of everyone in the server",
+	},
+	{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         checkpointOption,
+		Description:  "Only show images generated with this checkpoint",
+		Autocomplete: true,
+	},
+	{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         loraOption,
+		Description:  "Only show images generated with this LoRA",
+		Autocomplete: true,
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        gallerySinceOption,
+		Description: "Only show images generated since this date (YYYY-MM-DD)",
+	},
+}
+
+// gallery is a gallery shown by /gallery, kept to turn its pages and for the buttons of its favorites.
+type gallery struct {
+	filter    favorites.Filter
+	server    bool
+	owner     string // the member who used /gallery, who is the only one that can use its buttons
+	page      int
+	favorites []*entities.Favorite // the favorites shown on the page
+	shown     time.Time
+}
+
+func (q *SDQueue) processGalleryCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	g := &gallery{
+		filter: favorites.Filter{MemberID: utils.GetUser(i.Interaction).ID},
+		owner:  utils.GetUser(i.Interaction).ID,
+	}
+	if option, ok := optionMap[galleryUserOption]; ok {
+		g.filter.MemberID = option.UserValue(nil).ID
+	}
+	if option, ok := optionMap[galleryServerOption]; ok && option.BoolValue() {
+		g.server = true
+		g.filter.MemberID = ""
+	}
+	if g.server || g.filter.MemberID != utils.GetUser(i.Interaction).ID {
+		if i.GuildID == "" {
+			return handlers.ErrorEdit(s, i.Interaction, "The favorites of others can only be shown in a server.")
+		}
+		// the favorites of others are only shown in the server they were starred in
+		g.filter.GuildID = i.GuildID
+	}
+	if option, ok := optionMap[checkpointOption]; ok {
+		name, _, _ := strings.Cut(option.StringValue(), " [")
+		g.filter.Checkpoint = weightRegex.ReplaceAllString(name, "")
+	}
+	if option, ok := optionMap[loraOption]; ok {
+		g.filter.LoRA = weightRegex.ReplaceAllString(option.StringValue(), "")
+	}
+	if option, ok := optionMap[gallerySinceOption]; ok {
+		since, err := time.Parse(time.DateOnly, option.StringValue())
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("Invalid date `%s`, use YYYY-MM-DD.", option.StringValue()))
+		}
+		g.filter.Since = since
+	}
+
+	return q.showGallery(s, i, g)
+}
+
+// shownGallery returns the gallery of the message whose button was pressed,
+// or an error if it has expired or the member isn't the one who used /gallery.
+func (q *SDQueue) shownGallery(i *discordgo.InteractionCreate) (*gallery, error) {
+	q.mu.Lock()
+	g, ok := q.galleries[i.Message.ID]
+	q.mu.Unlock()
+	if !ok || time.Since(g.shown) > pageExpiry {
+		return nil, fmt.Errorf("this gallery has expired, use `/%s` again", GalleryCommand)
+	}
+	if g.owner != utils.GetUser(i.Interaction).ID {
+		return nil, fmt.Errorf("only <@%s> can use the buttons of this gallery, use `/%s` to see your own", g.owner, GalleryCommand)
+	}
+	return g, nil
+}
+
+// processGalleryPage turns the page of the gallery of the message with handlers.Pagination.
+func (q *SDQueue) processGalleryPage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	g, err := q.shownGallery(i)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		return handlers.Wrap(err)
+	}
+
+	page := g.page + 1
+	if i.MessageComponentData().CustomID == handlers.PaginationPrevious {
+		page = g.page - 1
+	}
+
+	return q.showGallery(s, i, &gallery{filter: g.filter, server: g.server, owner: g.owner, page: page})
+}
+
+// showGallery edits the response with the page of the gallery, and keeps the gallery for its buttons.
+func (q *SDQueue) showGallery(s *discordgo.Session, i *discordgo.InteractionCreate, g *gallery) error {
+	ctx := context.Background()
+	total, err := q.favoriteRepo.Count(ctx, g.filter)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error counting the favorites.", err)
+	}
+
+	if total == 0 {
+		content := "No favorites were found. Star an image with the ⭐ button under it to add it to the gallery."
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Embeds:     &[]*discordgo.MessageEmbed{},
+			Components: &[]discordgo.MessageComponent{},
+		})
+		return err
+	}
+
+	pages := (total + galleryPageSize - 1) / galleryPageSize
+	g.page = between(g.page, 0, pages-1)
+
+	g.favorites, err = q.favoriteRepo.List(ctx, g.filter, galleryPageSize, g.page*galleryPageSize)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error getting the favorites.", err)
+	}
+
+	embeds := make([]*discordgo.MessageEmbed, len(g.favorites))
+	reimagine := make([]discordgo.MessageComponent, len(g.favorites))
+	upscale := make([]discordgo.MessageComponent, len(g.favorites))
+	for index, favorite := range g.favorites {
+		embeds[index] = q.favoriteEmbed(favorite, g.page*galleryPageSize+index+1, g.server)
+		reimagine[index] = discordgo.Button{
+			Label:    strconv.Itoa(index + 1),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%v_%d", GalleryReimagineButton, index+1),
+			Emoji:    &discordgo.ComponentEmoji{Name: "🎲"},
+		}
+		upscale[index] = discordgo.Button{
+			Label:    strconv.Itoa(index + 1),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%v_%d", GalleryUpscaleButton, index+1),
+			Emoji:    &discordgo.ComponentEmoji{Name: "⬆️"},
+		}
+	}
+
+	content := fmt.Sprintf("Page %d of %d, %d favorites. Reimagine 🎲 or upscale ⬆️ any of them with the buttons below.", g.page+1, pages, total)
+	message, err := handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Embeds:  &embeds,
+		Components: &[]discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: reimagine},
+			discordgo.ActionsRow{Components: upscale},
+			handlers.Pagination(g.page, pages),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	g.shown = time.Now()
+	q.mu.Lock()
+	q.galleries[message.ID] = g
+	for id, shown := range q.galleries {
+		if time.Since(shown.shown) > pageExpiry {
+			delete(q.galleries, id)
+		}
+	}
+	q.mu.Unlock()
+
+	return nil
+}
+
+func (q *SDQueue) favoriteEmbed(favorite *entities.Favorite, number int, server bool) *discordgo.MessageEmbed {
+	link := fmt.Sprintf("https://discord.com/channels/%s/%s/%s", cmp.Or(favorite.GuildID, "@me"), favorite.ChannelID, favorite.MessageID)
+	description := fmt.Sprintf("[Image %d of the generation](%s)", favorite.SortOrder, link)
+	if server {
+		description += fmt.Sprintf(", starred by <@%s>", favorite.MemberID)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("#%d", number),
+		Description: description,
+		Image:       &discordgo.MessageEmbedImage{URL: favorite.AttachmentURL},
+		Timestamp:   favorite.CreatedAt.Format(time.RFC3339),
+	}
+
+	generation, err := q.imageGenerationRepo.GetByMessageAndSort(context.Background(), favorite.MessageID, favorite.SortOrder)
+	if err != nil {
+		return embed
+	}
+
+	embed.Fields = []*discordgo.MessageEmbedField{
+		{Name: "Prompt", Value: fmt.Sprintf("```\n%s\n```", truncate(cmp.Or(generation.Prompt, " "), 500))},
+		{Name: "Checkpoint", Value: fmt.Sprintf("`%s`", cmp.Or(safeDereference(generation.Checkpoint), "unknown")), Inline: true},
+		{Name: "Seed", Value: fmt.Sprintf("`%d`", generation.Seed), Inline: true},
+	}
+
+	return embed
+}
+
+// galleryFavorite returns the generation of the favorite whose button was pressed on the gallery.
+func (q *SDQueue) galleryFavorite(i *discordgo.InteractionCreate, button customID) (*entities.Favorite, *entities.ImageGenerationRequest, error) {
+	index, err := strconv.Atoi(strings.TrimPrefix(i.MessageComponentData().CustomID, button+"_"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing favorite index: %w", err)
+	}
+
+	g, err := q.shownGallery(i)
+	if err != nil {
+		return nil, nil, err
+	}
+	if index < 1 || index > len(g.favorites) {
+		return nil, nil, fmt.Errorf("there is no favorite %d on this page", index)
+	}
+
+	favorite := g.favorites[index-1]
+	generation, err := q.imageGenerationRepo.GetByMessageAndSort(context.Background(), favorite.MessageID, favorite.SortOrder)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting the generation of the favorite: %w", err)
+	}
+
+	return favorite, generation, nil
+}
+
+func (q *SDQueue) processGalleryReimagine(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	_, generation, err := q.galleryFavorite(i, GalleryReimagineButton)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	// the gallery isn't the message of the generation, so it's passed on instead of being looked up by the message
+	return q.queueMessageItem(s, i, &SDQueueItem{
+		ImageGenerationRequest: continueGeneration(generation, i),
+		Previous:               generation,
+		Type:                   ItemTypeReroll,
+		DiscordInteraction:     i.Interaction,
+	}, "reimagining")
+}
+
+func (q *SDQueue) processGalleryUpscale(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	favorite, generation, err := q.galleryFavorite(i, GalleryUpscaleButton)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	return q.queueMessageItem(s, i, &SDQueueItem{
+		ImageGenerationRequest: generation,
+		Previous:               generation,
+		Type:                   ItemTypeUpscale,
+		InteractionIndex:       favorite.SortOrder,
+		DiscordInteraction:     i.Interaction,
+	}, "upscaling")
+}
@@ -0,0 +1,53 @@
+package stable_diffusion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// pressed is a button pressed by the member on the message.
+func pressed(messageID, memberID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Message: &discordgo.Message{ID: messageID},
+		Member:  &discordgo.Member{User: &discordgo.User{ID: memberID}},
+	}}
+}
+
+func TestShownGallery(t *testing.T) {
+	q := &SDQueue{galleries: map[string]*gallery{
+		"shown":   {owner: "owner", shown: time.Now()},
+		"expired": {owner: "owner", shown: time.Now().Add(-pageExpiry - time.Minute)},
+	}}
+
+	if _, err := q.shownGallery(pressed("shown", "owner")); err != nil {
+		t.Errorf("Expected the owner to use the gallery, got %v", err)
+	}
+	if _, err := q.shownGallery(pressed("shown", "someone")); err == nil {
+		t.Error("Expected the buttons to be refused to anyone but the owner")
+	}
+	if _, err := q.shownGallery(pressed("expired", "owner")); err == nil {
+		t.Error("Expected the gallery to have expired")
+	}
+	if _, err := q.shownGallery(pressed("unknown", "owner")); err == nil {
+		t.Error("Expected a gallery that isn't kept to have expired")
+	}
+}
+
+func TestShownHistory(t *testing.T) {
+	q := &SDQueue{histories: map[string]*history{
+		"shown":   {owner: "owner", shown: time.Now()},
+		"expired": {owner: "owner", shown: time.Now().Add(-pageExpiry - time.Minute)},
+	}}
+
+	if _, err := q.shownHistory(pressed("shown", "owner")); err != nil {
+		t.Errorf("Expected the owner to use the history, got %v", err)
+	}
+	if _, err := q.shownHistory(pressed("shown", "someone")); err == nil {
+		t.Error("Expected the buttons to be refused to anyone but the owner")
+	}
+	if _, err := q.shownHistory(pressed("expired", "owner")); err == nil {
+		t.Error("Expected the history to have expired")
+	}
+}
@@ -1,6 +1,7 @@
 package stable_diffusion
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -29,6 +30,10 @@ const (
 	RawCommand             Command = JSONInput
 )
 
+// UseImageMessage is the only message command of the queue, which asks what to do with the message in a select,
+// as Discord allows only a few message commands for the whole bot.
+const UseImageMessage Command = "Use image as…"
+
 const (
 	// Command options
 	promptOption       = "prompt"
@@ -51,6 +56,7 @@ const (
 	batchSizeOption    = "batch_size"
 	clipSkipOption     = "clip_skip"
 	cfgRescaleOption   = "cfg_rescale"
+	enhanceOption      = "enhance"
 
 	img2imgOption   = "img2img"
 	denoisingOption = "denoising"
@@ -83,12 +89,25 @@ func (q *SDQueue) handlers() map[discordgo.InteractionType]map[string]queue.Hand
 			ImagineSettingsCommand: q.processImagineSettingsCommand,
 			RefreshCommand:         q.processRefreshCommand,
 			RawCommand:             q.processRawCommand,
+			GalleryCommand:         q.processGalleryCommand,
+			HistoryCommand:         q.processHistoryCommand,
+			SearchCommand:          q.processSearchCommand,
+			StyleCommand:           q.processStyleCommand,
+			WildcardCommand:        q.processWildcardCommand,
+			UseImageMessage:        q.processUseImageMessage,
 		},
 		discordgo.InteractionApplicationCommandAutocomplete: {
-			ImagineCommand: q.processImagineAutocomplete,
+			ImagineCommand:  q.processImagineAutocomplete,
+			GalleryCommand:  q.processImagineAutocomplete,
+			HistoryCommand:  q.processImagineAutocomplete,
+			StyleCommand:    q.processStyleAutocomplete,
+			WildcardCommand: q.processWildcardAutocomplete,
 		},
 		discordgo.InteractionModalSubmit: {
 			RawCommand: q.processRawModal,
+			EditModal:  q.processEditModal,
+
+			SettingsModal: q.processSettingsModal,
 		},
 	}
 }
@@ -102,16 +121,27 @@ func (q *SDQueue) processImagineCommand(s *discordgo.Session, i *discordgo.Inter
 
 	var position int
 	var item *SDQueueItem
+	var idea string
 
 	if option, ok := optionMap[promptOption]; !ok {
 		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a prompt.")
 	} else {
 		parameters, sanitized := utils.ExtractKeyValuePairsFromPrompt(option.StringValue())
 		item = q.NewItem(i.Interaction, WithPrompt(sanitized))
+		idea = sanitized
 		item.Type = ItemTypeImagine
 
+		style, err := q.commandStyle(i.Interaction, optionMap)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, err)
+		}
+		if style != nil {
+			applyStyle(item, style)
+		}
+
+		defaultNegative := item.NegativePrompt
 		if _, ok := interfaceConvertAuto[string, string](&item.NegativePrompt, negativeOption, optionMap, parameters); ok {
-			item.NegativePrompt = strings.ReplaceAll(item.NegativePrompt, "{DEFAULT}", DefaultNegative)
+			item.NegativePrompt = strings.ReplaceAll(item.NegativePrompt, "{DEFAULT}", defaultNegative)
 		}
 
 		interfaceConvertAuto[string, string](&item.SamplerName, samplerOption, optionMap, parameters)
@@ -138,8 +168,9 @@ func (q *SDQueue) processImagineCommand(s *discordgo.Session, i *discordgo.Inter
 		if config, err := q.stableDiffusionAPI.GetConfig(); err != nil {
 			_ = handlers.ErrorEdit(s, i.Interaction, "Error retrieving config.", err)
 		} else {
-			item.Checkpoint = config.SDModelCheckpoint
-			item.VAE = config.SDVae
+			// the checkpoint and VAE of the defaults are kept
+			item.Checkpoint = cmp.Or(item.Checkpoint, config.SDModelCheckpoint)
+			item.VAE = cmp.Or(item.VAE, config.SDVae)
 			item.Hypernetwork = config.SDHypernetwork
 		}
 
@@ -303,6 +334,11 @@ func (q *SDQueue) processImagineCommand(s *discordgo.Session, i *discordgo.Inter
 			}
 		}
 
+		if option, ok := optionMap[enhanceOption]; ok && option.BoolValue() {
+			return q.enhancePrompt(s, i, item, idea, strings.TrimPrefix(item.Prompt, idea))
+		}
+
+		item.applyStyleTemplates()
 		position, err = q.Add(item)
 		if err != nil {
 			return handlers.ErrorEdit(s, i.Interaction, "Error adding imagine to queue.", err)
@@ -330,7 +366,7 @@ func (q *SDQueue) processImagineCommand(s *discordgo.Session, i *discordgo.Inter
 
 var weightRegex = regexp.MustCompile(`.+\\|\.(?:safetensors|ckpt|pth?)|(:[\d.]+$)`)
 
-func (q *SDQueue) processImagineAutocomplete(_ *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (q *SDQueue) processImagineAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	data := i.ApplicationCommandData()
 	log.Printf("running autocomplete handler")
 	for optionIndex, opt := range data.Options {
@@ -351,6 +387,8 @@ func (q *SDQueue) processImagineAutocomplete(_ *discordgo.Session, i *discordgo.
 			return q.autocompleteModels(i, opt, stable_diffusion_api.HypernetworkCache)
 		case embeddingOption:
 			return q.autocompleteModels(i, opt, stable_diffusion_api.EmbeddingCache)
+		case styleOption:
+			return q.autocompleteStyles(s, i, opt)
 		case controlnetPreprocessor:
 			return q.autocompleteControlnet(i, opt, stable_diffusion_api.ControlnetModulesCache)
 		case controlnetModel:
@@ -610,38 +648,49 @@ func sanitizeTooltip(input string) string {
 	return input
 }
 
+// processImagineSettingsCommand shows the panel to edit the member's own defaults, or the bot defaults for the owner of the bot.
 func (q *SDQueue) processImagineSettingsCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	if err := handlers.ThinkResponse(s, i); err != nil {
+	if err := handlers.EphemeralThink(s, i); err != nil {
 		return err
 	}
 
-	botSettings, err := q.GetBotDefaultSettings()
+	user := utils.GetUser(i.Interaction)
+	memberID := user.ID
+	if option, ok := utils.GetOpts(i.ApplicationCommandData())[settingsBotOption]; ok && option.BoolValue() {
+		owner, err := handlers.IsBotOwner(s, i.Interaction)
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, "Error checking the owner of the bot.", err)
+		}
+		if !owner {
+			return handlers.ErrorEdit(s, i.Interaction, "Only the owner of the bot can change the bot defaults.")
+		}
+		memberID = botID
+	}
+
+	settings, err := q.profile(memberID)
 	if err != nil {
-		return fmt.Errorf("error getting default settings for settings command: %w", err)
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving default settings.", err)
 	}
 
-	messageComponents := q.settingsMessageComponents(botSettings)
+	q.mu.Lock()
+	q.profiles[user.ID] = memberID
+	q.mu.Unlock()
 
 	_, err = handlers.EditInteractionResponse(s, i.Interaction,
-		"Choose default settings for the imagine command:",
-		messageComponents,
+		settingsContent(settings),
+		defaultSettingsEmbed(settings),
+		q.settingsMessageComponents(settings),
 	)
 	return err
-	// err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-	// 	Type: discordgo.InteractionResponseChannelMessageWithSource,
-	// 	Data: &discordgo.InteractionResponseData{
-	// 		Title:      "Settings",
-	// 		Content:    "Choose default settings for the imagine command:",
-	// 		Components: messageComponents,
-	// 	},
-	// })
-	// if err != nil {
-	// 	log.Printf("Error responding to interaction: %v", err)
-	// }
 }
 
 func (q *SDQueue) processImagineDimensionSetting(s *discordgo.Session, i *discordgo.InteractionCreate, height, width int) error {
-	botSettings, err := q.UpdateDefaultDimensions(width, height)
+	settings, err := q.profile(q.profileID(i.Interaction))
+	if err == nil {
+		settings.Width = width
+		settings.Height = height
+		settings, err = q.saveProfile(settings)
+	}
 	if err != nil {
 		log.Printf("error updating default dimensions: %v", err)
 
@@ -658,20 +707,16 @@ func (q *SDQueue) processImagineDimensionSetting(s *discordgo.Session, i *discor
 		return nil
 	}
 
-	messageComponents := q.settingsMessageComponents(botSettings)
-
-	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseUpdateMessage,
-		Data: &discordgo.InteractionResponseData{
-			Content:    "Choose defaults settings for the imagine command:",
-			Components: messageComponents,
-		},
-	})
-	return handlers.Wrap(err)
+	return q.updateSettingsPanel(s, i, settings)
 }
 
 func (q *SDQueue) processImagineBatchSetting(s *discordgo.Session, i *discordgo.InteractionCreate, batchCount, batchSize int) error {
-	botSettings, err := q.UpdateDefaultBatch(batchCount, batchSize)
+	settings, err := q.profile(q.profileID(i.Interaction))
+	if err == nil {
+		settings.BatchCount = batchCount
+		settings.BatchSize = batchSize
+		settings, err = q.saveProfile(settings)
+	}
 	if err != nil {
 		log.Printf("error updating batch settings: %v", err)
 
@@ -688,16 +733,7 @@ func (q *SDQueue) processImagineBatchSetting(s *discordgo.Session, i *discordgo.
 		return nil
 	}
 
-	messageComponents := q.settingsMessageComponents(botSettings)
-
-	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-		Type: discordgo.InteractionResponseUpdateMessage,
-		Data: &discordgo.InteractionResponseData{
-			Content:    "Choose default settings for the imagine command:",
-			Components: messageComponents,
-		},
-	})
-	return handlers.Wrap(err)
+	return q.updateSettingsPanel(s, i, settings)
 }
 
 func (q *SDQueue) processImagineModelSetting(s *discordgo.Session, i *discordgo.InteractionCreate) error {
@@ -769,9 +805,17 @@ func (q *SDQueue) settingsMessageComponents(settings *entities.DefaultSettings)
 	if err != nil {
 		log.Printf("Error retrieving config: %v", err)
 	} else {
-		populateOption(q.stableDiffusionAPI, CheckpointSelect, stable_diffusion_api.CheckpointCache, config)
-		populateOption(q.stableDiffusionAPI, VAESelect, stable_diffusion_api.VAECache, config)
-		populateOption(q.stableDiffusionAPI, HypernetworkSelect, stable_diffusion_api.HypernetworkCache, config)
+		// the models of the defaults are marked, or the loaded ones when they have none
+		current := *config
+		if settings.Checkpoint != "" {
+			current.SDModelCheckpoint = &settings.Checkpoint
+		}
+		if settings.VAE != "" {
+			current.SDVae = &settings.VAE
+		}
+		populateOption(q.stableDiffusionAPI, CheckpointSelect, stable_diffusion_api.CheckpointCache, &current)
+		populateOption(q.stableDiffusionAPI, VAESelect, stable_diffusion_api.VAECache, &current)
+		populateOption(q.stableDiffusionAPI, HypernetworkSelect, stable_diffusion_api.HypernetworkCache, &current)
 	}
 
 	// set default dimension from config
@@ -813,7 +857,8 @@ func (q *SDQueue) settingsMessageComponents(settings *entities.DefaultSettings)
 		components[HypernetworkSelect],
 		components[DimensionSelect],
 		// Components[BatchCountSelect],
-		components[BatchSizeSelect],
+		// the batch size is in the modal of SettingsMoreButton, as a message has at most 5 rows
+		components[SettingsMoreButton],
 	}
 }
 
@@ -0,0 +1,312 @@
+package stable_diffusion
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	HistoryCommand Command = "history"
+
+	historyPromptOption = "prompt"
+	historyUntilOption  = "until"
+
+	// historyPageSize is the number of generations on a page, as many as the buttons that fit in a row.
+	historyPageSize = 5
+)
+
+const (
+	HistoryReuseButton customID = "history_reuse"
+	HistoryEditButton  customID = "history_edit"
+)
+
+var historyOptions = []*discordgo.ApplicationCommandOption{
+	{
+		Type:        discordgo.ApplicationCommandOptionUser,
+		Name:        galleryUserOption,
+		Description: "Show the history of another member of the server (admins only)",
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        historyPromptOption,
+		Description: "Only show generations with this text in the prompt",
+	},
+	{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         checkpointOption,
+		Description:  "Only show generations with this checkpoint",
+		Autocomplete: true,
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        gallerySinceOption,
+		Description: "Only show generations since this date (YYYY-MM-DD)",
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        historyUntilOption,
+		Description: "Only show generations until this date (YYYY-MM-DD)",
+	},
+}
+
+// history is a history shown by /history, kept to turn its pages and for the buttons of its generations.
+type history struct {
+	filter      image_generations.Filter
+	owner       string // the member who used /history, who is the only one that can use its buttons
+	page        int
+	generations []*entities.ImageGenerationRequest // the generations shown on the page
+	shown       time.Time
+}
+
+func (q *SDQueue) processHistoryCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	h := &history{
+		filter: image_generations.Filter{MemberID: utils.GetUser(i.Interaction).ID},
+		owner:  utils.GetUser(i.Interaction).ID,
+	}
+	if option, ok := optionMap[galleryUserOption]; ok && option.UserValue(nil).ID != h.filter.MemberID {
+		if !handlers.CanManageGuild(i.Interaction) {
+			return handlers.ErrorEdit(s, i.Interaction, "Only admins can view the history of other members.")
+		}
+		h.filter.MemberID = option.UserValue(nil).ID
+		// admins only see what was generated in their server
+		h.filter.GuildID = i.GuildID
+	}
+	if option, ok := optionMap[historyPromptOption]; ok {
+		h.filter.Prompt = option.StringValue()
+	}
+	if option, ok := optionMap[checkpointOption]; ok {
+		name, _, _ := strings.Cut(option.StringValue(), " [")
+		h.filter.Checkpoint = weightRegex.ReplaceAllString(name, "")
+	}
+	for name, date := range map[string]*time.Time{gallerySinceOption: &h.filter.From, historyUntilOption: &h.filter.To} {
+		option, ok := optionMap[name]
+		if !ok {
+			continue
+		}
+		parsed, err := time.Parse(time.DateOnly, option.StringValue())
+		if err != nil {
+			return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("Invalid date `%s`, use YYYY-MM-DD.", option.StringValue()))
+		}
+		*date = parsed
+	}
+	if !h.filter.To.IsZero() {
+		// include the whole day
+		h.filter.To = h.filter.To.AddDate(0, 0, 1)
+	}
+
+	return q.showHistory(s, i, h)
+}
+
+// shownHistory returns the history of the message whose button was pressed,
+// or an error if it has expired or the member isn't the one who used /history.
+func (q *SDQueue) shownHistory(i *discordgo.InteractionCreate) (*history, error) {
+	q.mu.Lock()
+	h, ok := q.histories[i.Message.ID]
+	q.mu.Unlock()
+	if !ok || time.Since(h.shown) > pageExpiry {
+		return nil, fmt.Errorf("this history has expired, use `/%s` again", HistoryCommand)
+	}
+	if h.owner != utils.GetUser(i.Interaction).ID {
+		return nil, fmt.Errorf("only <@%s> can use the buttons of this history, use `/%s` to see your own", h.owner, HistoryCommand)
+	}
+	return h, nil
+}
+
+// processPagination turns the page of the /gallery or /history of the message with handlers.Pagination.
+func (q *SDQueue) processPagination(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	q.mu.Lock()
+	_, isHistory := q.histories[i.Message.ID]
+	_, isGallery := q.galleries[i.Message.ID]
+	q.mu.Unlock()
+	switch {
+	case isGallery:
+		return q.processGalleryPage(s, i)
+	case !isHistory:
+		return handlers.ErrorEphemeral(s, i.Interaction, fmt.Sprintf("These pages have expired, use `/%s` or `/%s` again.", GalleryCommand, HistoryCommand))
+	}
+
+	h, err := q.shownHistory(i)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil {
+		return handlers.Wrap(err)
+	}
+
+	page := h.page + 1
+	if i.MessageComponentData().CustomID == handlers.PaginationPrevious {
+		page = h.page - 1
+	}
+
+	return q.showHistory(s, i, &history{filter: h.filter, owner: h.owner, page: page})
+}
+
+// showHistory edits the response with the page of the history, and keeps the history for its buttons.
+func (q *SDQueue) showHistory(s *discordgo.Session, i *discordgo.InteractionCreate, h *history) error {
+	ctx := context.Background()
+	total, err := q.imageGenerationRepo.Count(ctx, h.filter)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error counting the generations.", err)
+	}
+
+	if total == 0 {
+		content := "No generations were found."
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+			Content:    &content,
+			Embeds:     &[]*discordgo.MessageEmbed{},
+			Components: &[]discordgo.MessageComponent{},
+		})
+		return err
+	}
+
+	pages := (total + historyPageSize - 1) / historyPageSize
+	h.page = between(h.page, 0, pages-1)
+
+	h.generations, err = q.imageGenerationRepo.Search(ctx, h.filter, historyPageSize, h.page*historyPageSize)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error getting the generations.", err)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  fmt.Sprintf("History of %s", utils.GetUser(i.Interaction).Username),
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d of %d, %d generations", h.page+1, pages, total)},
+	}
+	if h.filter.MemberID != utils.GetUser(i.Interaction).ID {
+		embed.Title = "History"
+		embed.Description = fmt.Sprintf("Generations of <@%s>", h.filter.MemberID)
+	}
+
+	reuse := make([]discordgo.MessageComponent, len(h.generations))
+	edit := make([]discordgo.MessageComponent, len(h.generations))
+	for index, generation := range h.generations {
+		embed.Fields = append(embed.Fields, historyField(generation, h.page*historyPageSize+index+1))
+		reuse[index] = discordgo.Button{
+			Label:    strconv.Itoa(index + 1),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%v_%d", HistoryReuseButton, index+1),
+			Emoji:    &discordgo.ComponentEmoji{Name: "🎲"},
+		}
+		edit[index] = discordgo.Button{
+			Label:    strconv.Itoa(index + 1),
+			Style:    discordgo.SecondaryButton,
+			CustomID: fmt.Sprintf("%v_%d", HistoryEditButton, index+1),
+			Emoji:    &discordgo.ComponentEmoji{Name: "✏️"},
+		}
+	}
+
+	content := "Reimagine 🎲 or edit ✏️ any of them with the buttons below."
+	message, err := handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Embeds:  &[]*discordgo.MessageEmbed{embed},
+		Components: &[]discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: reuse},
+			discordgo.ActionsRow{Components: edit},
+			handlers.Pagination(h.page, pages),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	h.shown = time.Now()
+	q.mu.Lock()
+	q.histories[message.ID] = h
+	for id, shown := range q.histories {
+		if time.Since(shown.shown) > pageExpiry {
+			delete(q.histories, id)
+		}
+	}
+	q.mu.Unlock()
+
+	return nil
+}
+
+// historyField is a compact summary of the generation, with a link to its message when it's known where it was posted.
+func historyField(generation *entities.ImageGenerationRequest, number int) *discordgo.MessageEmbedField {
+	value := fmt.Sprintf("`%s` %d image(s), %dx%d, seed `%d` <t:%d:R>",
+		cmp.Or(safeDereference(generation.Checkpoint), "unknown"),
+		totalImageCount(generation),
+		generation.Width, generation.Height,
+		generation.Seed,
+		generation.CreatedAt.Unix(),
+	)
+	if generation.ChannelID != "" {
+		value += fmt.Sprintf(" [Jump](https://discord.com/channels/%s/%s/%s)", cmp.Or(generation.GuildID, "@me"), generation.ChannelID, generation.MessageID)
+	}
+
+	return &discordgo.MessageEmbedField{
+		Name:  fmt.Sprintf("%d. %s", number, truncate(strings.ReplaceAll(cmp.Or(generation.Prompt, "(no prompt)"), "\n", " "), 200)),
+		Value: value,
+	}
+}
+
+// historyGeneration returns the generation whose button was pressed on the history.
+func (q *SDQueue) historyGeneration(i *discordgo.InteractionCreate, button customID) (*entities.ImageGenerationRequest, error) {
+	index, err := strconv.Atoi(strings.TrimPrefix(i.MessageComponentData().CustomID, button+"_"))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing generation index: %w", err)
+	}
+
+	h, err := q.shownHistory(i)
+	if err != nil {
+		return nil, err
+	}
+	if index < 1 || index > len(h.generations) {
+		return nil, fmt.Errorf("there is no generation %d on this page", index)
+	}
+
+	// copy the generation as it's changed when it's queued again
+	generation := *h.generations[index-1]
+	textToImage := *generation.TextToImageRequest
+	generation.TextToImageRequest = &textToImage
+	return &generation, nil
+}
+
+func (q *SDQueue) processHistoryReuse(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	generation, err := q.historyGeneration(i, HistoryReuseButton)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	// the history isn't the message of the generation, so it's passed on instead of being looked up by the message
+	return q.queueMessageItem(s, i, &SDQueueItem{
+		ImageGenerationRequest: continueGeneration(generation, i),
+		Previous:               generation,
+		Type:                   ItemTypeReroll,
+		DiscordInteraction:     i.Interaction,
+	}, "reimagining")
+}
+
+func (q *SDQueue) processHistoryEdit(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	generation, err := q.historyGeneration(i, HistoryEditButton)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	return q.showEditModal(s, i, generation)
+}
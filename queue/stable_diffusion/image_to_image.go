@@ -24,6 +24,9 @@ func (q *SDQueue) imageToImage() ([]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := q.checkSize(queue, *img2img.Width, *img2img.Height); err != nil {
+		return nil, err
+	}
 
 	resp, err := q.stableDiffusionAPI.ImageToImageRequest(&img2img)
 	if err != nil {
@@ -1,6 +1,7 @@
 package stable_diffusion
 
 import (
+	"cmp"
 	"log"
 	"time"
 
@@ -16,6 +17,9 @@ type SDQueueItem struct {
 	Type ItemType
 
 	*entities.ImageGenerationRequest
+	// Previous is the generation to continue from when it was found before queueing, such as by a message command.
+	// Otherwise the generation is looked up by the message of the interaction.
+	Previous *entities.ImageGenerationRequest
 
 	LLMRequest *llm.Request
 	LLMCreated time.Time
@@ -26,6 +30,9 @@ type SDQueueItem struct {
 
 	ADetailerString string // use AppendSegModelByString
 
+	style   *entities.Style // the style whose templates are applied to the prompt by applyStyleTemplates
+	prompts []dynamicPrompt // the prompt of each image when the prompt has dynamic syntax, see expandDynamicPrompts
+
 	Img2ImgItem
 	ControlnetItem
 
@@ -58,6 +65,7 @@ func (q *SDQueueItem) Interaction() *discordgo.Interaction {
 func (q *SDQueue) NewItem(interaction *discordgo.Interaction, options ...func(*SDQueueItem)) *SDQueueItem {
 	item := q.DefaultQueueItem()
 	item.DiscordInteraction = interaction
+	q.applyMemberDefaults(item)
 
 	for _, option := range options {
 		option(item)
@@ -78,8 +86,9 @@ func WithCurrentModels(api stable_diffusion_api.StableDiffusionAPI) func(*SDQueu
 		if err != nil {
 			log.Printf("Error getting config: %v", err)
 		} else {
-			q.ImageGenerationRequest.Checkpoint = config.SDModelCheckpoint
-			q.VAE = config.SDVae
+			// the checkpoint and VAE of the defaults are kept
+			q.ImageGenerationRequest.Checkpoint = cmp.Or(q.ImageGenerationRequest.Checkpoint, config.SDModelCheckpoint)
+			q.VAE = cmp.Or(q.VAE, config.SDVae)
 			q.Hypernetwork = config.SDHypernetwork
 		}
 	}
@@ -114,7 +123,7 @@ func (q *SDQueue) DefaultQueueItem() *SDQueueItem {
 		defaultHeight = 512
 	}
 
-	return &SDQueueItem{
+	item := &SDQueueItem{
 		Type: ItemTypeImagine,
 
 		ImageGenerationRequest: &entities.ImageGenerationRequest{
@@ -147,4 +156,10 @@ func (q *SDQueue) DefaultQueueItem() *SDQueueItem {
 			ResizeMode:  entities.ResizeModeScaleToFit,
 		},
 	}
+
+	if botDefaultSettings, err := q.GetBotDefaultSettings(); err == nil {
+		applyDefaultSettings(item, botDefaultSettings)
+	}
+
+	return item
 }
@@ -0,0 +1,345 @@
+package stable_diffusion
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+var errNoSettings = errors.New("the message is not a generation of the bot and has no image with stable diffusion parameters")
+
+// The actions of UseImageSelect.
+const (
+	useReimagine  = "reimagine"
+	useSettings   = "settings"
+	useImg2Img    = "img2img"
+	useUpscale    = "upscale"
+	useControlnet = "controlnet"
+)
+
+// messageTarget returns the message the context menu was used on.
+func messageTarget(i *discordgo.InteractionCreate) (*discordgo.Message, bool) {
+	data := i.ApplicationCommandData()
+	message, ok := data.Resolved.Messages[data.TargetID]
+	if !ok {
+		return nil, false
+	}
+	if message.ChannelID == "" {
+		message.ChannelID = i.ChannelID
+	}
+	return message, true
+}
+
+// processUseImageMessage responds to the member only with the actions for the message.
+func (q *SDQueue) processUseImageMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	message, ok := messageTarget(i)
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Could not find the message.")
+	}
+
+	return handlers.Wrap(s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:      discordgo.MessageFlagsEphemeral,
+			Content:    "What would you like to use the image as?",
+			Components: useImageComponents(message.ID),
+		},
+	}))
+}
+
+// useImageComponents are the actions for the message, which the select carries in its custom ID.
+func useImageComponents(messageID string) []discordgo.MessageComponent {
+	option := func(value, label, description, emoji string) discordgo.SelectMenuOption {
+		return discordgo.SelectMenuOption{
+			Label:       label,
+			Value:       value,
+			Description: description,
+			Emoji:       &discordgo.ComponentEmoji{Name: emoji},
+		}
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    handlers.WithArguments(UseImageSelect, messageID),
+					Placeholder: "Use the image as...",
+					Options: []discordgo.SelectMenuOption{
+						option(useReimagine, "Reimagine", "Generate it again with a new seed", "🎲"),
+						option(useSettings, "Show settings", "Show its generation settings to you only", "📝"),
+						option(useImg2Img, "img2img input", "Generate from the first image", "🖼️"),
+						option(useUpscale, "Upscale first image", "Upscale the first image of the generation", "⬆️"),
+						option(useControlnet, "ControlNet input", "Use the first image as the ControlNet input", "🎛️"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// processUseImageSelect runs the action picked for the message of UseImageMessage. The interaction is pointed at
+// that message, the same as a button pressed on it, as the select is on the ephemeral message instead.
+func (q *SDQueue) processUseImageSelect(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Nothing was picked.")
+	}
+
+	if _, err := targetMessage(s, i, i.MessageComponentData().CustomID); err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, fmt.Sprintf("The message was not found, please use `%s` on it again.", UseImageMessage), err)
+	}
+
+	switch values[0] {
+	case useReimagine:
+		return q.processReimagineMessage(s, i)
+	case useSettings:
+		return q.processSettingsMessage(s, i)
+	case useImg2Img:
+		return q.processImg2ImgMessage(s, i)
+	case useUpscale:
+		return q.processUpscaleMessage(s, i)
+	case useControlnet:
+		return q.processControlnetMessage(s, i)
+	default:
+		return handlers.ErrorEphemeral(s, i.Interaction, fmt.Sprintf("Unknown action %s.", values[0]))
+	}
+}
+
+// firstImage downloads the first image attached to the message, leaving out the thumbnail added by utils.EmbedImages.
+func firstImage(message *discordgo.Message) (*utils.Image, error) {
+	for _, attachment := range message.Attachments {
+		if attachment.Filename == "thumbnail.png" || !strings.HasPrefix(attachment.ContentType, "image/") {
+			continue
+		}
+		image := utils.AsyncImage(attachment.URL)
+		if image.Len() == 0 {
+			return nil, fmt.Errorf("could not download %s", attachment.Filename)
+		}
+		return image, nil
+	}
+	return nil, errors.New("the message has no images")
+}
+
+// messageGeneration finds the settings of the images in the message. Generations of the bot are looked up by the message,
+// while other images fall back to the parameters the web UI writes in the PNG metadata.
+// image is the first image of the message, which is downloaded for the metadata and can be nil for generations of the bot.
+func (q *SDQueue) messageGeneration(message *discordgo.Message, image *utils.Image) (*entities.ImageGenerationRequest, error) {
+	generation, err := q.imageGenerationRepo.GetByMessage(context.Background(), message.ID)
+	if err == nil {
+		return generation, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Error getting the generation of message %v: %v", message.ID, err)
+	}
+
+	if image == nil {
+		if image, err = firstImage(message); err != nil {
+			return nil, errNoSettings
+		}
+	}
+
+	chunks, err := utils.PNGTextChunks(image.Bytes())
+	if err != nil || chunks["parameters"] == "" {
+		return nil, errNoSettings
+	}
+
+	generation = q.DefaultQueueItem().ImageGenerationRequest
+	if err := parseParameters(chunks["parameters"], generation); err != nil {
+		return nil, errNoSettings
+	}
+
+	return generation, nil
+}
+
+// metadataGeneration returns the generation when it was read from the metadata, to be set as SDQueueItem.Previous.
+// Generations of the bot are looked up by the message instead, the same as when a button on the message is pressed.
+func metadataGeneration(generation *entities.ImageGenerationRequest) *entities.ImageGenerationRequest {
+	if generation.ID != 0 {
+		return nil
+	}
+	return generation
+}
+
+// continueGeneration prepares a generation found by messageGeneration to be generated again by the member of i.
+func continueGeneration(generation *entities.ImageGenerationRequest, i *discordgo.InteractionCreate) *entities.ImageGenerationRequest {
+	generation.InteractionID = i.Interaction.ID
+	generation.MemberID = utils.GetUser(i.Interaction).ID
+	generation.CreatedAt = time.Now()
+	generation.Seed = -1
+	return generation
+}
+
+// queueMessageItem adds the item and shows its position in the response, which the queue edits once it's processed.
+func (q *SDQueue) queueMessageItem(s *discordgo.Session, i *discordgo.InteractionCreate, item *SDQueueItem, action string) error {
+	position, err := q.Add(item)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error adding imagine to queue.", err)
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction,
+		fmt.Sprintf("I'm %s that for you... You are currently #%d in line.", action, position),
+		handlers.Components[handlers.Cancel],
+	)
+	return err
+}
+
+func (q *SDQueue) processReimagineMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	generation, err := q.messageGeneration(i.Message, nil)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	return q.queueMessageItem(s, i, &SDQueueItem{
+		ImageGenerationRequest: continueGeneration(generation, i),
+		Previous:               metadataGeneration(generation),
+		Type:                   ItemTypeReroll,
+		DiscordInteraction:     i.Interaction,
+	}, "reimagining")
+}
+
+func (q *SDQueue) processUpscaleMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	generation, err := q.messageGeneration(i.Message, nil)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	// the upscale generates the first image again from its seed, so the seed is kept
+	return q.queueMessageItem(s, i, &SDQueueItem{
+		ImageGenerationRequest: generation,
+		Previous:               metadataGeneration(generation),
+		Type:                   ItemTypeUpscale,
+		InteractionIndex:       1,
+		DiscordInteraction:     i.Interaction,
+	}, "upscaling")
+}
+
+// processImg2ImgMessage uses the first image of the message as the input of an img2img generation.
+// The settings of the image are used when they can be found, or the defaults otherwise.
+func (q *SDQueue) processImg2ImgMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	item, err := q.inputItem(s, i)
+	if item == nil {
+		return err
+	}
+
+	asImg2Img(item)
+	return q.queueMessageItem(s, i, item, "imagining")
+}
+
+// asImg2Img makes the item an img2img generation of its Img2ImgItem.Image.
+func asImg2Img(item *SDQueueItem) {
+	item.Type = ItemTypeImg2Img
+	// the denoising strength of the settings is for the hires fix, which is left out when it wasn't used
+	if item.TextToImageRequest.DenoisingStrength == 0 {
+		item.TextToImageRequest.DenoisingStrength = item.Img2ImgItem.DenoisingStrength
+	}
+	item.Img2ImgItem.DenoisingStrength = item.TextToImageRequest.DenoisingStrength
+}
+
+// processControlnetMessage uses the first image of the message as the ControlNet input of a generation.
+func (q *SDQueue) processControlnetMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	item, err := q.inputItem(s, i)
+	if item == nil {
+		return err
+	}
+
+	item.ControlnetItem.Image = item.Img2ImgItem.Image
+	item.ControlnetItem.Enabled = true
+	item.Img2ImgItem.Image = nil
+	return q.queueMessageItem(s, i, item, "imagining")
+}
+
+// inputItem responds to the interaction and returns an item with the first image of the message as its img2img image.
+// A nil item means the interaction was already responded to with an error.
+func (q *SDQueue) inputItem(s *discordgo.Session, i *discordgo.InteractionCreate) (*SDQueueItem, error) {
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return nil, err
+	}
+
+	image, err := firstImage(i.Message)
+	if err != nil {
+		return nil, handlers.ErrorEdit(s, i.Interaction, "Error getting the image.", err)
+	}
+
+	item := q.NewItem(i.Interaction)
+	item.Img2ImgItem.Image = image
+
+	generation, err := q.messageGeneration(i.Message, image)
+	if err != nil {
+		log.Printf("Using the default settings for the input image: %v", err)
+	} else {
+		item.ImageGenerationRequest = continueGeneration(generation, i)
+	}
+
+	return item, nil
+}
+
+// processSettingsMessage shows the settings of the images in the message to the member only.
+func (q *SDQueue) processSettingsMessage(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	generation, err := q.messageGeneration(i.Message, nil)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{settingsEmbed(generation)},
+	})
+	return err
+}
+
+func settingsEmbed(generation *entities.ImageGenerationRequest) *discordgo.MessageEmbed {
+	source := "Read from the image metadata"
+	if generation.ID != 0 {
+		source = fmt.Sprintf("Generated by the bot for <@%s>", generation.MemberID)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "Generation settings",
+		Description: source,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Prompt", Value: fmt.Sprintf("```\n%s\n```", truncate(cmp.Or(generation.Prompt, " "), 1000))},
+		},
+	}
+	if generation.NegativePrompt != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Negative prompt", Value: fmt.Sprintf("```\n%s\n```", truncate(generation.NegativePrompt, 1000)),
+		})
+	}
+	embed.Fields = append(embed.Fields,
+		&discordgo.MessageEmbedField{Name: "Checkpoint", Value: fmt.Sprintf("`%s`", cmp.Or(safeDereference(generation.Checkpoint), "unknown")), Inline: false},
+		&discordgo.MessageEmbedField{Name: "Seed", Value: fmt.Sprintf("`%d`", generation.Seed), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Sampler", Value: fmt.Sprintf("`%s`", generation.SamplerName), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Steps", Value: fmt.Sprintf("`%d`", generation.Steps), Inline: true},
+		&discordgo.MessageEmbedField{Name: "CFG Scale", Value: fmt.Sprintf("`%0.1f`", generation.CFGScale), Inline: true},
+		&discordgo.MessageEmbedField{Name: "Size", Value: fmt.Sprintf("`%dx%d`", generation.Width, generation.Height), Inline: true},
+	)
+	if generation.EnableHr {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name: "Hires fix", Value: fmt.Sprintf("`x%0.1f` with `%s`", generation.HrScale, generation.HrUpscaler), Inline: true,
+		})
+	}
+
+	return embed
+}
@@ -0,0 +1,124 @@
+package stable_diffusion
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"stable_diffusion_bot/entities"
+)
+
+// parameterRegex matches the "Key: value" pairs of the last line of the parameters, where values with commas are quoted.
+// It's the same pattern the web UI uses to read them back.
+var parameterRegex = regexp.MustCompile(`\s*(\w[\w \-/]+):\s*("(?:\\.|[^\\"])+"|[^,]*)(?:,|$)`)
+
+// parseParameters reads the "parameters" PNG text chunk written by the web UI into request, such as:
+//
+//	a cat sitting on a windowsill
+//	Negative prompt: blurry, watermark
+//	Steps: 20, Sampler: Euler a, CFG scale: 7, Seed: 1234, Size: 512x768, Model: sd_xl_base_1.0
+//
+// Only the settings the bot can generate with are read, and the rest of request is left as is.
+func parseParameters(parameters string, request *entities.ImageGenerationRequest) error {
+	lines := strings.Split(strings.TrimSpace(parameters), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return errors.New("the parameters are empty")
+	}
+
+	settings := map[string]string{}
+	if last := lines[len(lines)-1]; len(parameterRegex.FindAllString(last, -1)) >= 3 {
+		for _, match := range parameterRegex.FindAllStringSubmatch(last, -1) {
+			settings[match[1]] = unquote(match[2])
+		}
+		lines = lines[:len(lines)-1]
+	}
+
+	var prompt, negative []string
+	for _, line := range lines {
+		if after, ok := strings.CutPrefix(line, "Negative prompt:"); ok {
+			negative = append(negative, strings.TrimSpace(after))
+			continue
+		}
+		if negative != nil {
+			negative = append(negative, line)
+		} else {
+			prompt = append(prompt, line)
+		}
+	}
+
+	textToImage := request.TextToImageRequest
+	textToImage.Prompt = strings.TrimSpace(strings.Join(prompt, "\n"))
+	textToImage.NegativePrompt = strings.TrimSpace(strings.Join(negative, "\n"))
+
+	for key, value := range settings {
+		applyParameter(request, key, value)
+	}
+
+	// the batch of the original generation isn't part of the parameters, which are written per image
+	textToImage.NIter = 1
+	textToImage.BatchSize = 1
+
+	return nil
+}
+
+// applyParameter sets the setting of request named by key the same way the web UI writes it in the parameters.
+// It reports whether the key is a setting the bot can generate with.
+func applyParameter(request *entities.ImageGenerationRequest, key, value string) bool {
+	textToImage := request.TextToImageRequest
+	switch key {
+	case "Steps":
+		parseInto(value, &textToImage.Steps, strconv.Atoi)
+	case "Sampler":
+		textToImage.SamplerName = value
+	case "CFG scale":
+		parseInto(value, &textToImage.CFGScale, parseFloat)
+	case "Seed":
+		parseInto(value, &textToImage.Seed, parseInt)
+	case "Variation seed":
+		parseInto(value, &textToImage.Subseed, parseInt)
+	case "Variation seed strength":
+		parseInto(value, &textToImage.SubseedStrength, parseFloat)
+	case "Size":
+		if width, height, ok := strings.Cut(value, "x"); ok {
+			parseInto(width, &textToImage.Width, strconv.Atoi)
+			parseInto(height, &textToImage.Height, strconv.Atoi)
+		}
+	case "Denoising strength":
+		parseInto(value, &textToImage.DenoisingStrength, parseFloat)
+	case "Hires upscale":
+		textToImage.EnableHr = true
+		parseInto(value, &textToImage.HrScale, parseFloat)
+	case "Hires upscaler":
+		textToImage.HrUpscaler = value
+	case "Hires steps":
+		parseInto(value, &textToImage.HrSecondPassSteps, parseInt)
+	case "Clip skip":
+		parseInto(value, &textToImage.OverrideSettings.CLIPStopAtLastLayers, parseFloat)
+	case "Model":
+		request.Checkpoint = &value
+	case "VAE":
+		request.VAE = &value
+	default:
+		return false
+	}
+	return true
+}
+
+// parseInto sets field to the parsed value, and leaves it unchanged when the value is invalid.
+func parseInto[T any](value string, field *T, parse func(string) (T, error)) {
+	if parsed, err := parse(strings.TrimSpace(value)); err == nil {
+		*field = parsed
+	}
+}
+
+func parseFloat(value string) (float64, error) { return strconv.ParseFloat(value, 64) }
+
+func parseInt(value string) (int64, error) { return strconv.ParseInt(value, 10, 64) }
+
+func unquote(value string) string {
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return value
+}
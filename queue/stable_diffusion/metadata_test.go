@@ -0,0 +1,57 @@
+package stable_diffusion
+
+import (
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestParseParameters(t *testing.T) {
+	parameters := "a cat sitting on a windowsill,\nsunset\n" +
+		"Negative prompt: blurry, watermark\n" +
+		`Steps: 30, Sampler: DPM++ 2M Karras, CFG scale: 6.5, Seed: 1234, Size: 512x768, Model hash: 31e35c80fc, ` +
+		`Model: sd_xl_base_1.0, Denoising strength: 0.45, Clip skip: 2, Hires upscale: 2, Hires upscaler: Latent, ` +
+		`Lora hashes: "add_detail: 7c6bad76eb54, pixel: 1a2b3c", Version: v1.9.4`
+
+	request := &entities.ImageGenerationRequest{TextToImageRequest: &entities.TextToImageRequest{NIter: 4, BatchSize: 4}}
+	if err := parseParameters(parameters, request); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+
+	if want := "a cat sitting on a windowsill,\nsunset"; request.Prompt != want {
+		t.Errorf("Expected prompt %q, got %q", want, request.Prompt)
+	}
+	if want := "blurry, watermark"; request.NegativePrompt != want {
+		t.Errorf("Expected negative prompt %q, got %q", want, request.NegativePrompt)
+	}
+	if request.Steps != 30 || request.SamplerName != "DPM++ 2M Karras" || request.CFGScale != 6.5 || request.Seed != 1234 {
+		t.Errorf("Expected 30 steps of DPM++ 2M Karras at 6.5 with seed 1234, got %d steps of %s at %v with seed %d",
+			request.Steps, request.SamplerName, request.CFGScale, request.Seed)
+	}
+	if request.Width != 512 || request.Height != 768 {
+		t.Errorf("Expected 512x768, got %dx%d", request.Width, request.Height)
+	}
+	if !request.EnableHr || request.HrScale != 2 || request.HrUpscaler != "Latent" || request.DenoisingStrength != 0.45 {
+		t.Errorf("Expected the hires fix at x2 with Latent and 0.45 denoising, got %v x%v %s %v",
+			request.EnableHr, request.HrScale, request.HrUpscaler, request.DenoisingStrength)
+	}
+	if request.OverrideSettings.CLIPStopAtLastLayers != 2 {
+		t.Errorf("Expected clip skip 2, got %v", request.OverrideSettings.CLIPStopAtLastLayers)
+	}
+	if request.Checkpoint == nil || *request.Checkpoint != "sd_xl_base_1.0" {
+		t.Errorf("Expected checkpoint sd_xl_base_1.0, got %v", safeDereference(request.Checkpoint))
+	}
+	if request.NIter != 1 || request.BatchSize != 1 {
+		t.Errorf("Expected a single image, got %d x %d", request.NIter, request.BatchSize)
+	}
+}
+
+func TestParseParametersPromptOnly(t *testing.T) {
+	request := &entities.ImageGenerationRequest{TextToImageRequest: &entities.TextToImageRequest{Steps: 20}}
+	if err := parseParameters("just a prompt, with commas: and colons", request); err != nil {
+		t.Fatalf("Expected nil, got %v", err)
+	}
+	if request.Prompt != "just a prompt, with commas: and colons" || request.Steps != 20 {
+		t.Errorf("Expected the prompt with the steps left as is, got %q and %d", request.Prompt, request.Steps)
+	}
+}
@@ -0,0 +1,207 @@
+package stable_diffusion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/composite_renderer"
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/utils"
+)
+
+// processImageSelect responds to the member only with the actions for the picked image.
+func (q *SDQueue) processImageSelect(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	values := i.MessageComponentData().Values
+	if len(values) == 0 {
+		return handlers.ErrorEphemeral(s, i.Interaction, "No image was picked.")
+	}
+
+	index, err := strconv.Atoi(values[0])
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "error parsing image index", err)
+	}
+
+	return handlers.Wrap(s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:      discordgo.MessageFlagsEphemeral,
+			Content:    fmt.Sprintf("What would you like to do with image %d?", index),
+			Components: pickComponents(i.Message.ID, index),
+		},
+	}))
+}
+
+// pickComponents are the actions for the image at index of the message, which the buttons carry in their custom IDs.
+// The index is the sort order of the image, starting from 1 like the buttons of rerollVariationComponents.
+func pickComponents(messageID string, index int) []discordgo.MessageComponent {
+	button := func(id customID, label, emoji string) discordgo.Button {
+		return discordgo.Button{
+			Label:    label,
+			Style:    discordgo.SecondaryButton,
+			CustomID: handlers.WithArguments(id, messageID, strconv.Itoa(index)),
+			Emoji:    &discordgo.ComponentEmoji{Name: emoji},
+		}
+	}
+
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				button(PickUpscaleButton, "Upscale", "⬆️"),
+				button(PickVariantButton, "Variation", "♻️"),
+				button(PickImg2ImgButton, "Use as img2img input", "🖼️"),
+				button(PickDownloadButton, "Download", "💾"),
+				button(PickFavoriteButton, "Favorite", "⭐"),
+			},
+		},
+	}
+}
+
+// targetMessage points the interaction at the message whose ID is the first argument of the custom ID,
+// as the components acting on it are on an ephemeral message instead, and returns the other arguments.
+func targetMessage(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) ([]string, error) {
+	_, arguments := handlers.Arguments(customID)
+	if len(arguments) == 0 {
+		return nil, errors.New("the custom ID has no message")
+	}
+
+	message, err := s.ChannelMessage(i.ChannelID, arguments[0])
+	if err != nil {
+		return nil, err
+	}
+
+	i.Interaction.Message = message
+	return arguments[1:], nil
+}
+
+// pickedMessage points the interaction at the message the image was picked from, as the buttons are on the
+// ephemeral message instead, and returns the index of the image.
+func pickedMessage(s *discordgo.Session, i *discordgo.InteractionCreate) (int, bool) {
+	arguments, err := targetMessage(s, i, i.MessageComponentData().CustomID)
+	if err != nil || len(arguments) == 0 {
+		return 0, false
+	}
+
+	index, err := strconv.Atoi(arguments[0])
+	return index, err == nil
+}
+
+func (q *SDQueue) processPickUpscale(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, ok := pickedMessage(s, i)
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The picked image was not found, please pick it again.")
+	}
+	return q.processImagineUpscale(s, i, index)
+}
+
+func (q *SDQueue) processPickVariation(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, ok := pickedMessage(s, i)
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The picked image was not found, please pick it again.")
+	}
+	return q.processImagineVariation(s, i, index)
+}
+
+func (q *SDQueue) processPickImg2Img(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, ok := pickedMessage(s, i)
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The picked image was not found, please pick it again.")
+	}
+
+	if err := handlers.ThinkResponse(s, i); err != nil {
+		return err
+	}
+
+	generation, image, err := q.imageAt(i.Message, index)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error getting the image.", err)
+	}
+
+	item := q.NewItem(i.Interaction)
+	item.ImageGenerationRequest = continueGeneration(generation, i)
+	item.Img2ImgItem.Image = utils.ImageFromBytes(image)
+	asImg2Img(item)
+	return q.queueMessageItem(s, i, item, "imagining")
+}
+
+func (q *SDQueue) processPickDownload(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	index, ok := pickedMessage(s, i)
+	if !ok {
+		return handlers.ErrorEphemeral(s, i.Interaction, "The picked image was not found, please pick it again.")
+	}
+
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	generation, image, err := q.imageAt(i.Message, index)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error getting the image.", err)
+	}
+
+	content := fmt.Sprintf("Image %d (seed: %d)", index, generation.Seed)
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Files: []*discordgo.File{{
+			Name:        fmt.Sprintf("%s-%d.png", i.Message.ID, index),
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(image),
+		}},
+	})
+	return err
+}
+
+// imageAt returns the generation and the image at index of a message made by showFinalMessage.
+// Up to four images are attached one by one, while larger batches are tiled into one image by utils.EmbedImages,
+// which is cut back apart with the size of the images.
+func (q *SDQueue) imageAt(message *discordgo.Message, index int) (*entities.ImageGenerationRequest, []byte, error) {
+	generation, err := q.imageGenerationRepo.GetByMessageAndSort(context.Background(), message.ID, index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting the generation of image %d: %w", index, err)
+	}
+
+	attachments := imageAttachments(message)
+	total := totalImageCount(generation)
+	switch {
+	case len(attachments) == 0:
+		return nil, nil, errors.New("the message has no images")
+	case len(attachments) == 1 && total > 1:
+		tile := utils.AsyncImage(attachments[0].URL)
+		if tile.Len() == 0 {
+			return nil, nil, fmt.Errorf("could not download %s", attachments[0].Filename)
+		}
+		cell, err := composite_renderer.TileCell(tile, index-1, total, generation.Width > generation.Height)
+		if err != nil {
+			return nil, nil, err
+		}
+		image, err := io.ReadAll(cell)
+		return generation, image, err
+	case index > len(attachments):
+		return nil, nil, fmt.Errorf("the message has no image %d", index)
+	default:
+		image := utils.AsyncImage(attachments[index-1].URL)
+		if image.Len() == 0 {
+			return nil, nil, fmt.Errorf("could not download %s", attachments[index-1].Filename)
+		}
+		return generation, image.Bytes(), nil
+	}
+}
+
+// imageAttachments returns the images attached to the message, leaving out the thumbnail added by utils.EmbedImages.
+func imageAttachments(message *discordgo.Message) []*discordgo.MessageAttachment {
+	var attachments []*discordgo.MessageAttachment
+	for _, attachment := range message.Attachments {
+		if attachment.Filename == "thumbnail.png" || !strings.HasPrefix(attachment.ContentType, "image/") {
+			continue
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments
+}
@@ -0,0 +1,99 @@
+package stable_diffusion
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+)
+
+// fakeMessages answers the messages fetched from channels with a message of the requested ID.
+func fakeMessages(t *testing.T) *discordgo.Session {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		channelID, messageID, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/channels/"), "/messages/")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(discordgo.Message{ID: messageID, ChannelID: channelID})
+	}))
+	t.Cleanup(server.Close)
+
+	endpoint := discordgo.EndpointChannelMessage
+	discordgo.EndpointChannelMessage = func(channelID, messageID string) string {
+		return server.URL + "/channels/" + channelID + "/messages/" + messageID
+	}
+	t.Cleanup(func() { discordgo.EndpointChannelMessage = endpoint })
+
+	session, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestTargetMessage(t *testing.T) {
+	s := fakeMessages(t)
+
+	// the ephemeral components of two messages in a row each act on their own message
+	for _, messageID := range []string{"first", "second"} {
+		i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			ChannelID: "channel",
+			Message:   &discordgo.Message{ID: "ephemeral"},
+		}}
+
+		arguments, err := targetMessage(s, i, handlers.WithArguments(PickUpscaleButton, messageID, "3"))
+		if err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if i.Message.ID != messageID || !slices.Equal(arguments, []string{"3"}) {
+			t.Errorf("Expected message %s with [3], got %s with %v", messageID, i.Message.ID, arguments)
+		}
+	}
+
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{ChannelID: "channel"}}
+	if _, err := targetMessage(s, i, PickUpscaleButton); err == nil {
+		t.Error("Expected an error for a custom ID without a message")
+	}
+}
+
+func TestPickedMessage(t *testing.T) {
+	s := fakeMessages(t)
+
+	for _, picked := range []struct {
+		messageID string
+		index     int
+	}{{"first", 6}, {"second", 2}} {
+		row := pickComponents(picked.messageID, picked.index)[0].(discordgo.ActionsRow)
+		i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionMessageComponent,
+			ChannelID: "channel",
+			Data:      discordgo.MessageComponentInteractionData{CustomID: row.Components[0].(discordgo.Button).CustomID},
+		}}
+
+		index, ok := pickedMessage(s, i)
+		if !ok || index != picked.index || i.Message.ID != picked.messageID {
+			t.Errorf("Expected image %d of %s, got %d of %v", picked.index, picked.messageID, index, i.Message)
+		}
+	}
+}
+
+func TestUseImageTarget(t *testing.T) {
+	s := fakeMessages(t)
+
+	for _, messageID := range []string{"first", "second"} {
+		row := useImageComponents(messageID)[0].(discordgo.ActionsRow)
+		i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{ChannelID: "channel"}}
+
+		if _, err := targetMessage(s, i, row.Components[0].(discordgo.SelectMenu).CustomID); err != nil {
+			t.Fatalf("Expected nil, got %v", err)
+		}
+		if i.Message.ID != messageID {
+			t.Errorf("Expected the select to act on %s, got %s", messageID, i.Message.ID)
+		}
+	}
+}
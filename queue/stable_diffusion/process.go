@@ -49,7 +49,7 @@ func (q *SDQueue) next() error {
 	switch q.currentImagine.Type {
 	case ItemTypeImagine, ItemTypeRaw:
 		err = q.processCurrentImagine()
-	case ItemTypeReroll, ItemTypeVariation:
+	case ItemTypeReroll, ItemTypeVariation, ItemTypeOriginal:
 		err = q.processVariation()
 	case ItemTypeImg2Img:
 		err = q.processImg2ImgImagine()
@@ -100,6 +100,8 @@ func (q *SDQueue) processCurrentImagine() error {
 }
 
 func (q *SDQueue) done() {
+	q.guilds.Done(q.currentImagine.DiscordInteraction)
+
 	q.mu.Lock()
 	q.currentImagine = nil
 	q.mu.Unlock()
@@ -115,6 +117,10 @@ func betweenPtr[T cmp.Ordered](value, minimum, maximum T) *T {
 }
 
 func (q *SDQueue) getPreviousGeneration(queue *SDQueueItem) (*entities.ImageGenerationRequest, error) {
+	if queue.Previous != nil {
+		return queue.Previous, nil
+	}
+
 	if queue.DiscordInteraction == nil {
 		return nil, errors.New("interaction is nil")
 	}
@@ -0,0 +1,438 @@
+package stable_diffusion
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+const settingsBotOption = "bot"
+
+var imagineSettingsOptions = []*discordgo.ApplicationCommandOption{
+	{
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        settingsBotOption,
+		Description: "Change the bot defaults used by everyone instead of your own (bot owner only)",
+	},
+}
+
+// samplingKeys and hiresKeys are the keys of applyParameter read by the inputs of the settings modal.
+var (
+	samplingKeys = []string{"Sampler", "Steps", "CFG scale", "Clip skip"}
+	hiresKeys    = []string{"Hires upscale", "Hires upscaler", "Hires steps", "Denoising strength"}
+)
+
+// applyDefaultSettings sets the fields of the item that the defaults have, leaving the others as they are.
+func applyDefaultSettings(item *SDQueueItem, settings *entities.DefaultSettings) {
+	request := item.ImageGenerationRequest
+	if settings == nil || request == nil || request.TextToImageRequest == nil {
+		return
+	}
+	textToImage := request.TextToImageRequest
+
+	textToImage.Width = cmp.Or(settings.Width, textToImage.Width)
+	textToImage.Height = cmp.Or(settings.Height, textToImage.Height)
+	textToImage.NIter = cmp.Or(settings.BatchCount, textToImage.NIter)
+	textToImage.BatchSize = cmp.Or(settings.BatchSize, textToImage.BatchSize)
+
+	textToImage.SamplerName = cmp.Or(settings.SamplerName, textToImage.SamplerName)
+	textToImage.Steps = cmp.Or(settings.Steps, textToImage.Steps)
+	textToImage.CFGScale = cmp.Or(settings.CFGScale, textToImage.CFGScale)
+	textToImage.NegativePrompt = cmp.Or(settings.NegativePrompt, textToImage.NegativePrompt)
+	if settings.CLIPSkip > 0 {
+		textToImage.OverrideSettings.CLIPStopAtLastLayers = float64(settings.CLIPSkip)
+	}
+
+	if settings.EnableHr != nil {
+		textToImage.EnableHr = *settings.EnableHr
+	}
+	textToImage.HrScale = cmp.Or(settings.HrScale, textToImage.HrScale)
+	textToImage.HrUpscaler = cmp.Or(settings.HrUpscaler, textToImage.HrUpscaler)
+	textToImage.HrSecondPassSteps = cmp.Or(settings.HrSteps, textToImage.HrSecondPassSteps)
+	textToImage.DenoisingStrength = cmp.Or(settings.DenoisingStrength, textToImage.DenoisingStrength)
+
+	if settings.Checkpoint != "" {
+		checkpoint := settings.Checkpoint
+		request.Checkpoint = &checkpoint
+	}
+	if settings.VAE != "" {
+		vae := settings.VAE
+		request.VAE = &vae
+	}
+
+	item.ADetailerString = cmp.Or(settings.ADetailer, item.ADetailerString)
+}
+
+// profileID returns whose defaults the member edits in the imagine_settings panel,
+// which are their own unless they're an admin who chose the bot defaults.
+func (q *SDQueue) profileID(i *discordgo.Interaction) string {
+	user := utils.GetUser(i)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return cmp.Or(q.profiles[user.ID], user.ID)
+}
+
+// profile returns the defaults of the member, which are empty if they haven't set any, or the bot defaults for botID.
+func (q *SDQueue) profile(memberID string) (*entities.DefaultSettings, error) {
+	if memberID == botID {
+		botDefaultSettings, err := q.GetBotDefaultSettings()
+		if err != nil {
+			return nil, err
+		}
+		// the cached defaults are only replaced once the changes are saved
+		settings := *botDefaultSettings
+		return &settings, nil
+	}
+
+	settings, err := q.defaultSettingsRepo.GetByMemberID(context.Background(), memberID)
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return &entities.DefaultSettings{MemberID: memberID}, nil
+		}
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// saveProfile stores the defaults, keeping the cached bot defaults up to date.
+func (q *SDQueue) saveProfile(settings *entities.DefaultSettings) (*entities.DefaultSettings, error) {
+	settings, err := q.defaultSettingsRepo.Upsert(context.Background(), settings)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings.MemberID == botID {
+		q.botDefaultSettings = settings
+	}
+
+	return settings, nil
+}
+
+// updateSettingsPanel shows the defaults in the imagine_settings panel of the component or modal.
+func (q *SDQueue) updateSettingsPanel(s *discordgo.Session, i *discordgo.InteractionCreate, settings *entities.DefaultSettings) error {
+	return handlers.UpdateFromComponent(s, i.Interaction,
+		settingsContent(settings),
+		defaultSettingsEmbed(settings),
+		q.settingsMessageComponents(settings),
+	)
+}
+
+func settingsContent(settings *entities.DefaultSettings) string {
+	if settings.MemberID == botID {
+		return "Choose the bot default settings for the imagine command:"
+	}
+	return "Choose your default settings for the imagine command, the empty ones use the bot defaults:"
+}
+
+func defaultSettingsEmbed(settings *entities.DefaultSettings) discordgo.MessageEmbed {
+	orDefault := func(value string) string {
+		if value == "" || value == "0" {
+			return "Default"
+		}
+		return value
+	}
+	formatFloat := func(value float64) string { return strconv.FormatFloat(value, 'f', -1, 64) }
+
+	size := "Default"
+	if settings.Width > 0 && settings.Height > 0 {
+		size = fmt.Sprintf("%dx%d", settings.Width, settings.Height)
+	}
+	batch := "Default"
+	if settings.BatchCount > 0 && settings.BatchSize > 0 {
+		batch = fmt.Sprintf("%d x %d", settings.BatchCount, settings.BatchSize)
+	}
+
+	hires := "Default"
+	if settings.EnableHr != nil {
+		hires = "Off"
+		if *settings.EnableHr {
+			hires = strings.ReplaceAll(hiresSettings(settings), "\n", ", ")
+		}
+	}
+
+	return discordgo.MessageEmbed{
+		Title: "Default settings",
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Checkpoint", Value: orDefault(settings.Checkpoint), Inline: true},
+			{Name: "VAE", Value: orDefault(settings.VAE), Inline: true},
+			{Name: "Size", Value: size, Inline: true},
+			{Name: "Batch", Value: batch, Inline: true},
+			{Name: "Sampler", Value: orDefault(settings.SamplerName), Inline: true},
+			{Name: "Steps", Value: orDefault(strconv.Itoa(settings.Steps)), Inline: true},
+			{Name: "CFG scale", Value: orDefault(formatFloat(settings.CFGScale)), Inline: true},
+			{Name: "Clip skip", Value: orDefault(strconv.Itoa(settings.CLIPSkip)), Inline: true},
+			{Name: "ADetailer", Value: orDefault(settings.ADetailer), Inline: true},
+			{Name: "Hires fix", Value: hires},
+			{Name: "Negative prompt", Value: truncate(orDefault(settings.NegativePrompt), 1000)},
+		},
+	}
+}
+
+// samplingSettings writes the sampling settings of the defaults the same way as the parameters of the web UI.
+func samplingSettings(settings *entities.DefaultSettings) string {
+	var pairs []string
+	add := func(key, value string) {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", key, value))
+	}
+
+	if settings.SamplerName != "" {
+		add("Sampler", settings.SamplerName)
+	}
+	if settings.Steps > 0 {
+		add("Steps", strconv.Itoa(settings.Steps))
+	}
+	if settings.CFGScale > 0 {
+		add("CFG scale", strconv.FormatFloat(settings.CFGScale, 'f', -1, 64))
+	}
+	if settings.CLIPSkip > 0 {
+		add("Clip skip", strconv.Itoa(settings.CLIPSkip))
+	}
+
+	return strings.Join(pairs, ", ")
+}
+
+// batchSettings writes the batch size of the defaults, or nothing when they don't have one.
+func batchSettings(settings *entities.DefaultSettings) string {
+	if settings.BatchSize == 0 {
+		return ""
+	}
+	return strconv.Itoa(settings.BatchSize)
+}
+
+// hiresSettings writes the hires fix of the defaults one setting per line, or "off" when it's turned off.
+func hiresSettings(settings *entities.DefaultSettings) string {
+	if settings.EnableHr == nil {
+		return ""
+	}
+	if !*settings.EnableHr {
+		return "off"
+	}
+
+	var lines []string
+	add := func(key, value string) {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+	}
+	if settings.HrScale > 0 {
+		add("Hires upscale", strconv.FormatFloat(settings.HrScale, 'f', -1, 64))
+	}
+	if settings.HrUpscaler != "" {
+		add("Hires upscaler", settings.HrUpscaler)
+	}
+	if settings.HrSteps > 0 {
+		add("Hires steps", strconv.FormatInt(settings.HrSteps, 10))
+	}
+	if settings.DenoisingStrength > 0 {
+		add("Denoising strength", strconv.FormatFloat(settings.DenoisingStrength, 'f', -1, 64))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// readParameters reads the "Key: value" pairs into a blank request, so that the settings left out stay empty.
+func readParameters(pairs [][2]string, keys []string) (*entities.TextToImageRequest, error) {
+	request := &entities.ImageGenerationRequest{TextToImageRequest: &entities.TextToImageRequest{}}
+	for _, pair := range pairs {
+		if !slices.Contains(keys, pair[0]) || !applyParameter(request, pair[0], pair[1]) {
+			return nil, fmt.Errorf("unknown setting `%s`, use one of %s", pair[0], strings.Join(keys, ", "))
+		}
+	}
+	return request.TextToImageRequest, nil
+}
+
+// processProfileModelSetting sets the checkpoint or VAE of the defaults edited in the panel, where None clears it.
+func (q *SDQueue) processProfileModelSetting(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.MessageComponentData()
+	if len(data.Values) == 0 {
+		return fmt.Errorf("no values for %v", data.CustomID)
+	}
+
+	settings, err := q.profile(q.profileID(i.Interaction))
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error retrieving default settings.", err)
+	}
+
+	model := data.Values[0]
+	if model == "None" {
+		model = ""
+	}
+	switch data.CustomID {
+	case CheckpointSelect:
+		settings.Checkpoint = model
+	case VAESelect:
+		settings.VAE = model
+	}
+
+	settings, err = q.saveProfile(settings)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error updating default settings.", err)
+	}
+
+	return q.updateSettingsPanel(s, i, settings)
+}
+
+// processSettingsMoreButton responds with a modal filled in with the defaults edited in the panel.
+func (q *SDQueue) processSettingsMoreButton(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	settings, err := q.profile(q.profileID(i.Interaction))
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error retrieving default settings.", err)
+	}
+
+	textInput := func(id customID, label, placeholder string, style discordgo.TextInputStyle, value string) discordgo.MessageComponent {
+		return discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.TextInput{
+					CustomID:    id,
+					Label:       label,
+					Style:       style,
+					Placeholder: placeholder,
+					Value:       truncate(value, 4000),
+					MaxLength:   4000,
+				},
+			},
+		}
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: SettingsModal,
+			Title:    "Default settings",
+			Components: []discordgo.MessageComponent{
+				textInput(SettingsNegativeInput, "Negative prompt", "Empty to use the default negative prompt",
+					discordgo.TextInputParagraph, settings.NegativePrompt),
+				textInput(SettingsSamplingInput, "Sampler, steps, CFG scale and clip skip", "Sampler: Euler a, Steps: 20, CFG scale: 7, Clip skip: 2",
+					discordgo.TextInputShort, samplingSettings(settings)),
+				textInput(SettingsHiresInput, "Hires fix, one \"Key: value\" per line, or off", "Hires upscale: 2\nHires upscaler: R-ESRGAN 2x+\nHires steps: 20\nDenoising strength: 0.7",
+					discordgo.TextInputParagraph, hiresSettings(settings)),
+				textInput(SettingsADetailerInput, "ADetailer models, separated by commas", "face_yolov8n.pt,person_yolov8n-seg.pt",
+					discordgo.TextInputShort, settings.ADetailer),
+				textInput(SettingsBatchInput, "Batch size, making 4 images in all", "1, 2 or 4",
+					discordgo.TextInputShort, batchSettings(settings)),
+			},
+		},
+	})
+	return handlers.Wrap(err)
+}
+
+// processSettingsModal stores the settings of the modal in the defaults edited in the panel,
+// where the inputs left empty fall back to the defaults again.
+func (q *SDQueue) processSettingsModal(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	settings, err := q.profile(q.profileID(i.Interaction))
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error retrieving default settings.", err)
+	}
+
+	modalData := getModalData(i.ModalSubmitData())
+	value := func(id customID) string {
+		if input, ok := modalData[handlers.Component(id)]; ok && input != nil {
+			return strings.TrimSpace(input.Value)
+		}
+		return ""
+	}
+
+	var pairs [][2]string
+	for _, match := range parameterRegex.FindAllStringSubmatch(value(SettingsSamplingInput), -1) {
+		pairs = append(pairs, [2]string{strings.TrimSpace(match[1]), unquote(match[2])})
+	}
+	sampling, err := readParameters(pairs, samplingKeys)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, err)
+	}
+
+	hires := &entities.TextToImageRequest{}
+	if input := value(SettingsHiresInput); input != "" && !strings.EqualFold(input, "off") {
+		pairs = nil
+		for _, line := range strings.Split(input, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			key, setting, found := strings.Cut(line, ":")
+			if !found {
+				return handlers.ErrorEphemeral(s, i.Interaction, fmt.Sprintf("Unknown hires setting `%s`, use one \"Key: value\" per line.", strings.TrimSpace(line)))
+			}
+			pairs = append(pairs, [2]string{strings.TrimSpace(key), strings.TrimSpace(setting)})
+		}
+		hires, err = readParameters(pairs, hiresKeys)
+		if err != nil {
+			return handlers.ErrorEphemeral(s, i.Interaction, err)
+		}
+		hires.EnableHr = true
+	}
+
+	var batchCount, batchSize int
+	if input := value(SettingsBatchInput); input != "" {
+		batchSize, err = strconv.Atoi(input)
+		if err != nil || !slices.Contains([]int{1, 2, 4}, batchSize) {
+			return handlers.ErrorEphemeral(s, i.Interaction, fmt.Sprintf("Unknown batch size `%s`, use 1, 2 or 4.", input))
+		}
+		batchCount = 4 / batchSize
+	}
+
+	settings.NegativePrompt = value(SettingsNegativeInput)
+
+	settings.SamplerName = sampling.SamplerName
+	settings.Steps = sampling.Steps
+	settings.CFGScale = sampling.CFGScale
+	settings.CLIPSkip = int(sampling.OverrideSettings.CLIPStopAtLastLayers)
+
+	settings.EnableHr = nil
+	if input := value(SettingsHiresInput); input != "" {
+		settings.EnableHr = &hires.EnableHr
+	}
+	settings.HrScale = hires.HrScale
+	settings.HrUpscaler = hires.HrUpscaler
+	settings.HrSteps = hires.HrSecondPassSteps
+	settings.DenoisingStrength = hires.DenoisingStrength
+
+	var models []string
+	for _, model := range strings.Split(value(SettingsADetailerInput), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	settings.ADetailer = strings.Join(models, ",")
+
+	settings.BatchCount = batchCount
+	settings.BatchSize = batchSize
+
+	settings, err = q.saveProfile(settings)
+	if err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error updating default settings.", err)
+	}
+
+	return q.updateSettingsPanel(s, i, settings)
+}
+
+// processSettingsResetButton clears the defaults edited in the panel, which brings the bot defaults back to the built-in ones.
+func (q *SDQueue) processSettingsResetButton(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	memberID := q.profileID(i.Interaction)
+	if err := q.defaultSettingsRepo.Delete(context.Background(), memberID); err != nil {
+		return handlers.ErrorEphemeral(s, i.Interaction, "Error resetting default settings.", err)
+	}
+
+	settings := &entities.DefaultSettings{MemberID: memberID}
+	if memberID == botID {
+		q.botDefaultSettings = nil
+		botDefaultSettings, err := q.initializeOrGetBotDefaults()
+		if err != nil {
+			return handlers.ErrorEphemeral(s, i.Interaction, "Error resetting default settings.", err)
+		}
+		q.botDefaultSettings = botDefaultSettings
+		settings = botDefaultSettings
+	}
+
+	return q.updateSettingsPanel(s, i, settings)
+}
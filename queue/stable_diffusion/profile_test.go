@@ -0,0 +1,44 @@
+package stable_diffusion
+
+import (
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestApplyDefaultSettings(t *testing.T) {
+	item := &SDQueueItem{
+		ImageGenerationRequest: &entities.ImageGenerationRequest{
+			TextToImageRequest: &entities.TextToImageRequest{
+				SamplerName: "Euler a",
+				Steps:       20,
+				CFGScale:    7,
+				EnableHr:    true,
+				HrScale:     1.5,
+			},
+		},
+	}
+
+	off := false
+	applyDefaultSettings(item, &entities.DefaultSettings{
+		Steps:      30,
+		Checkpoint: "sd_xl_base_1.0",
+		CLIPSkip:   2,
+		EnableHr:   &off,
+		ADetailer:  "face_yolov8n.pt",
+	})
+
+	if item.SamplerName != "Euler a" || item.CFGScale != 7 {
+		t.Errorf("Expected the settings missing from the defaults to be kept, got %s with CFG scale %v", item.SamplerName, item.CFGScale)
+	}
+	if item.Steps != 30 || safeDereference(item.Checkpoint) != "sd_xl_base_1.0" || item.OverrideSettings.CLIPStopAtLastLayers != 2 {
+		t.Errorf("Expected 30 steps on sd_xl_base_1.0 with clip skip 2, got %d on %s with clip skip %v",
+			item.Steps, safeDereference(item.Checkpoint), item.OverrideSettings.CLIPStopAtLastLayers)
+	}
+	if item.EnableHr || item.HrScale != 1.5 {
+		t.Errorf("Expected the hires fix to be turned off and its scale kept, got %v at %v", item.EnableHr, item.HrScale)
+	}
+	if item.ADetailerString != "face_yolov8n.pt" {
+		t.Errorf("Expected the ADetailer model of the defaults, got %q", item.ADetailerString)
+	}
+}
@@ -12,7 +12,10 @@ import (
 	"stable_diffusion_bot/entities"
 	"stable_diffusion_bot/queue"
 	"stable_diffusion_bot/repositories/default_settings"
+	"stable_diffusion_bot/repositories/favorites"
 	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/repositories/styles"
+	"stable_diffusion_bot/repositories/wildcards"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -28,6 +31,15 @@ type SDQueue struct {
 	defaultSettingsRepo default_settings.Repository
 	botDefaultSettings  *entities.DefaultSettings
 	cancelledItems      map[string]bool
+	profiles            map[string]string // whose defaults each member edits in the imagine_settings panel
+	favoriteRepo        favorites.Repository
+	styleRepo           styles.Repository
+	wildcardRepo        wildcards.Repository
+	galleries           map[string]*gallery // the galleries shown by /gallery, by their message
+	histories           map[string]*history // the histories shown by /history, by their message
+	promptEnhancer      PromptEnhancer
+	captioner           queue.Captioner
+	guilds              *queue.Guilds
 
 	stop chan os.Signal
 }
@@ -36,6 +48,12 @@ type Config struct {
 	StableDiffusionAPI  stable_diffusion_api.StableDiffusionAPI
 	ImageGenerationRepo image_generations.Repository
 	DefaultSettingsRepo default_settings.Repository
+	FavoriteRepo        favorites.Repository
+	Guilds              *queue.Guilds        // optional, shared by the queues, enables the settings of each server
+	StyleRepo           styles.Repository    // optional, enables /style and the style option of /imagine
+	WildcardRepo        wildcards.Repository // optional, enables /wildcard and the wildcards of prompts
+	PromptEnhancer      PromptEnhancer       // optional, enables the enhance option of /imagine
+	Captioner           queue.Captioner      // optional, gives the finished images alt text
 }
 
 func New(cfg Config) (queue.Queue[*SDQueueItem], error) {
@@ -51,6 +69,15 @@ func New(cfg Config) (queue.Queue[*SDQueueItem], error) {
 		return nil, errors.New("missing default settings repository")
 	}
 
+	if cfg.FavoriteRepo == nil {
+		return nil, errors.New("missing favorite repository")
+	}
+
+	guilds := cfg.Guilds
+	if guilds == nil {
+		guilds = queue.NewGuilds(nil)
+	}
+
 	return &SDQueue{
 		stableDiffusionAPI:  cfg.StableDiffusionAPI,
 		imageGenerationRepo: cfg.ImageGenerationRepo,
@@ -58,6 +85,15 @@ func New(cfg Config) (queue.Queue[*SDQueueItem], error) {
 		compositor:          composite_renderer.Compositor(),
 		defaultSettingsRepo: cfg.DefaultSettingsRepo,
 		cancelledItems:      make(map[string]bool),
+		profiles:            make(map[string]string),
+		favoriteRepo:        cfg.FavoriteRepo,
+		styleRepo:           cfg.StyleRepo,
+		wildcardRepo:        cfg.WildcardRepo,
+		galleries:           make(map[string]*gallery),
+		histories:           make(map[string]*history),
+		promptEnhancer:      cfg.PromptEnhancer,
+		captioner:           cfg.Captioner,
+		guilds:              guilds,
 	}, nil
 }
 
@@ -73,7 +109,8 @@ const (
 	ItemTypeUpscale
 	ItemTypeVariation
 	ItemTypeImg2Img
-	ItemTypeRaw // raw JSON
+	ItemTypeRaw      // raw JSON
+	ItemTypeOriginal // reroll with the prompt from before it was enhanced
 )
 
 func (q *SDQueue) Add(queue *SDQueueItem) (int, error) {
@@ -81,6 +118,10 @@ func (q *SDQueue) Add(queue *SDQueueItem) (int, error) {
 		return -1, errors.New("queue is full")
 	}
 
+	if err := q.guilds.Wait(queue.DiscordInteraction); err != nil {
+		return -1, err
+	}
+
 	q.queue <- queue
 
 	linePosition := len(q.queue)
@@ -0,0 +1,113 @@
+package stable_diffusion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories/image_generations"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	SearchCommand Command = "search"
+
+	searchQueryOption = "query"
+
+	// searchResults is the number of best matches shown, each with the thumbnail of its images.
+	searchResults = 5
+)
+
+var searchOptions = []*discordgo.ApplicationCommandOption{
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        searchQueryOption,
+		Description: "The words to look for in the prompts",
+		Required:    true,
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionUser,
+		Name:        galleryUserOption,
+		Description: "Only search the generations of this member",
+	},
+}
+
+// processSearchCommand shows the generations whose prompts best match the query.
+// In a server the generations made in it are searched, and in direct messages only the generations of the member.
+func (q *SDQueue) processSearchCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	optionMap := utils.GetOpts(i.ApplicationCommandData())
+
+	var query string
+	if option, ok := optionMap[searchQueryOption]; ok {
+		query = option.StringValue()
+	}
+
+	filter := image_generations.Filter{GuildID: i.GuildID}
+	if i.GuildID == "" {
+		filter.MemberID = utils.GetUser(i.Interaction).ID
+	}
+	if option, ok := optionMap[galleryUserOption]; ok {
+		if i.GuildID == "" && option.UserValue(nil).ID != filter.MemberID {
+			return handlers.ErrorEdit(s, i.Interaction, "The generations of others can only be searched in a server.")
+		}
+		filter.MemberID = option.UserValue(nil).ID
+	}
+
+	generations, err := q.imageGenerationRepo.SearchText(context.Background(), query, filter, searchResults)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error searching the generations.", err)
+	}
+
+	if len(generations) == 0 {
+		content := fmt.Sprintf("No generations were found for `%s`.", query)
+		_, err := handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{Content: &content})
+		return err
+	}
+
+	embeds := make([]*discordgo.MessageEmbed, len(generations))
+	for index, generation := range generations {
+		embeds[index] = searchEmbed(s, generation, index+1)
+	}
+
+	content := fmt.Sprintf("Best matches for `%s`", query)
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, &discordgo.WebhookEdit{
+		Content: &content,
+		Embeds:  &embeds,
+	})
+	return err
+}
+
+// searchEmbed shows the generation like historyField, with the first image of its message as the thumbnail
+// when the message can still be found.
+func searchEmbed(s *discordgo.Session, generation *entities.ImageGenerationRequest, number int) *discordgo.MessageEmbed {
+	field := historyField(generation, number)
+	embed := &discordgo.MessageEmbed{
+		Title:       field.Name,
+		Description: field.Value,
+	}
+	if generation.NegativePrompt != "" {
+		embed.Fields = []*discordgo.MessageEmbedField{
+			{Name: "Negative prompt", Value: fmt.Sprintf("```\n%s\n```", truncate(generation.NegativePrompt, 500))},
+		}
+	}
+
+	if generation.ChannelID == "" {
+		return embed
+	}
+	message, err := s.ChannelMessage(generation.ChannelID, generation.MessageID)
+	if err != nil {
+		return embed
+	}
+	if attachments := imageAttachments(message); len(attachments) > 0 {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: attachments[0].URL}
+	}
+
+	return embed
+}
@@ -0,0 +1,480 @@
+package stable_diffusion
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	StyleCommand Command = "style"
+
+	styleCreate = "create"
+	styleEdit   = "edit"
+	styleDelete = "delete"
+	styleList   = "list"
+
+	styleOption       = "style"
+	styleNameOption   = "name"
+	styleWidthOption  = "width"
+	styleHeightOption = "height"
+	styleLorasOption  = "loras"
+	styleSharedOption = "shared"
+
+	// stylePromptLength is the length of the templates shown when listing styles.
+	stylePromptLength = 200
+)
+
+func styleCommand() *discordgo.ApplicationCommand {
+	name := discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         styleNameOption,
+		Description:  "The name of the style",
+		Required:     true,
+		Autocomplete: true,
+		MaxLength:    100,
+	}
+	newName := name
+	newName.Autocomplete = false
+
+	prompt := discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        promptOption,
+		Description: "The prompt template, where {prompt} is replaced by the prompt of /imagine",
+	}
+	newPrompt := prompt
+	newPrompt.Required = true
+
+	minSize := 64.0
+	settings := []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        negativeOption,
+			Description: "The negative prompt template, where {prompt} is replaced by the negative prompt",
+		},
+		commandOptions[samplerOption],
+		commandOptions[stepOption],
+		commandOptions[cfgScaleOption],
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        styleWidthOption,
+			Description: "The width of the images",
+			MinValue:    &minSize,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        styleHeightOption,
+			Description: "The height of the images",
+			MinValue:    &minSize,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        styleLorasOption,
+			Description: "The LoRAs to add to the prompt as name:strength, separated by commas",
+		},
+	}
+
+	shared := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionBoolean,
+		Name:        styleSharedOption,
+		Description: "Share the style with everyone in this server instead of keeping it to yourself",
+	}
+
+	return &discordgo.ApplicationCommand{
+		Name:        StyleCommand,
+		Description: "Manage the styles that can be applied with /imagine",
+		Type:        discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        styleCreate,
+				Description: "Create a style with its own prompt template and settings",
+				Options:     append(append([]*discordgo.ApplicationCommandOption{&newName, &newPrompt}, settings...), shared),
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        styleEdit,
+				Description: "Change a style you created",
+				Options:     append([]*discordgo.ApplicationCommandOption{&name, &prompt}, settings...),
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        styleDelete,
+				Description: "Delete a style you created",
+				Options:     []*discordgo.ApplicationCommandOption{&name},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        styleList,
+				Description: "List your styles and the ones shared in this server",
+			},
+		},
+	}
+}
+
+// commandStyle returns the style picked in the options of /imagine, or nil if none was picked.
+func (q *SDQueue) commandStyle(i *discordgo.Interaction, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*entities.Style, error) {
+	option, ok := optionMap[styleOption]
+	if !ok || option.StringValue() == "" {
+		return nil, nil
+	}
+	if q.styleRepo == nil {
+		return nil, errors.New("styles are not available")
+	}
+
+	style, err := q.styleRepo.GetByName(context.Background(), i.GuildID, utils.GetUser(i).ID, option.StringValue())
+	if errors.Is(err, &repositories.NotFoundError{}) {
+		return nil, fmt.Errorf("there is no style named `%s`", option.StringValue())
+	}
+	return style, err
+}
+
+// applyStyle sets the settings of the style on the item, which the options of /imagine can still change.
+// Its templates are only applied by applyStyleTemplates once the prompt is final.
+func applyStyle(item *SDQueueItem, style *entities.Style) {
+	item.style = style
+	item.Style = style.Name
+
+	item.SamplerName = cmp.Or(style.SamplerName, item.SamplerName)
+	item.Steps = cmp.Or(style.Steps, item.Steps)
+	item.CFGScale = cmp.Or(style.CFGScale, item.CFGScale)
+	item.Width = cmp.Or(style.Width, item.Width)
+	item.Height = cmp.Or(style.Height, item.Height)
+}
+
+// applyStyleTemplates puts the prompts of the item in the templates of its style and adds the LoRAs of the style.
+func (q *SDQueueItem) applyStyleTemplates() {
+	style := q.style
+	if style == nil {
+		return
+	}
+	q.style = nil
+
+	q.Prompt = style.Apply(q.Prompt)
+	for _, lora := range strings.Split(style.Loras, ",") {
+		if lora = strings.TrimSpace(lora); lora != "" {
+			q.Prompt += ", <lora:" + styleLora(lora) + ">"
+		}
+	}
+	q.NegativePrompt = style.ApplyNegative(q.NegativePrompt)
+	if q.OriginalPrompt != "" {
+		// so that regenerating with the original prompt keeps the style
+		q.OriginalPrompt = style.Apply(q.OriginalPrompt)
+	}
+}
+
+var loraStrengthRegex = regexp.MustCompile(`:[\d.]+$`)
+
+// styleLora adds the default strength of 1 to the LoRA if it has none.
+func styleLora(lora string) string {
+	if loraStrengthRegex.MatchString(lora) {
+		return lora
+	}
+	return lora + ":1"
+}
+
+// autocompleteStyles suggests the styles the member can use, where a personal style hides the shared style of the same name.
+func (q *SDQueue) autocompleteStyles(s *discordgo.Session, i *discordgo.InteractionCreate, opt *discordgo.ApplicationCommandInteractionDataOption) error {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
+	if q.styleRepo != nil {
+		styles, err := q.styleRepo.List(context.Background(), i.GuildID, utils.GetUser(i.Interaction).ID)
+		if err != nil {
+			log.Printf("Error retrieving styles: %v", err)
+		}
+
+		input := strings.ToLower(opt.StringValue())
+		for _, style := range styles {
+			if input != "" && !strings.Contains(strings.ToLower(style.Name), input) {
+				continue
+			}
+			if slices.ContainsFunc(choices, func(choice *discordgo.ApplicationCommandOptionChoice) bool { return choice.Value == style.Name }) {
+				continue
+			}
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  styleLabel(style),
+				Value: style.Name,
+			})
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices[:min(25, len(choices))],
+		},
+	})
+	return handlers.Wrap(err)
+}
+
+func (q *SDQueue) processStyleAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.ApplicationCommandData()
+	if len(data.Options) > 0 {
+		for _, opt := range data.Options[0].Options {
+			if opt.Focused && opt.Name == styleNameOption {
+				return q.autocompleteStyles(s, i, opt)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (q *SDQueue) processStyleCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	if q.styleRepo == nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Styles are not available.")
+	}
+
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to pick a subcommand.")
+	}
+	subcommand := data.Options[0]
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(subcommand.Options))
+	for _, opt := range subcommand.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	switch subcommand.Name {
+	case styleCreate:
+		return q.createStyle(s, i, optionMap)
+	case styleEdit:
+		return q.editStyle(s, i, optionMap)
+	case styleDelete:
+		return q.deleteStyle(s, i, optionMap)
+	case styleList:
+		return q.listStyles(s, i)
+	}
+
+	return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("Unknown subcommand %s.", subcommand.Name))
+}
+
+// setStyleOptions copies the given options onto the style.
+func setStyleOptions(style *entities.Style, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) {
+	if option, ok := optionMap[promptOption]; ok {
+		style.Prompt = option.StringValue()
+	}
+	if option, ok := optionMap[negativeOption]; ok {
+		style.NegativePrompt = option.StringValue()
+	}
+	if option, ok := optionMap[samplerOption]; ok {
+		style.SamplerName = option.StringValue()
+	}
+	if option, ok := optionMap[stepOption]; ok {
+		style.Steps = int(option.IntValue())
+	}
+	if option, ok := optionMap[cfgScaleOption]; ok {
+		style.CFGScale = option.FloatValue()
+	}
+	if option, ok := optionMap[styleWidthOption]; ok {
+		style.Width = int(option.IntValue())
+	}
+	if option, ok := optionMap[styleHeightOption]; ok {
+		style.Height = int(option.IntValue())
+	}
+	if option, ok := optionMap[styleLorasOption]; ok {
+		var loras []string
+		for _, lora := range strings.Split(option.StringValue(), ",") {
+			if lora = strings.TrimSpace(lora); lora != "" {
+				loras = append(loras, lora)
+			}
+		}
+		style.Loras = strings.Join(loras, ", ")
+	}
+}
+
+func (q *SDQueue) createStyle(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	option, ok := optionMap[styleNameOption]
+	if !ok || strings.TrimSpace(option.StringValue()) == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a name.")
+	}
+
+	style := &entities.Style{
+		Name:     strings.TrimSpace(option.StringValue()),
+		MemberID: utils.GetUser(i.Interaction).ID,
+	}
+	if option, ok := optionMap[styleSharedOption]; ok && option.BoolValue() {
+		if i.GuildID == "" {
+			return handlers.ErrorEdit(s, i.Interaction, "Styles can only be shared in a server.")
+		}
+		style.GuildID = i.GuildID
+	}
+
+	styles, err := q.styleRepo.List(context.Background(), i.GuildID, style.MemberID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving the styles.", err)
+	}
+	if slices.ContainsFunc(styles, func(existing *entities.Style) bool {
+		return existing.Name == style.Name && existing.GuildID == style.GuildID
+	}) {
+		return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("A %s named `%s` already exists.", styleKind(style), style.Name))
+	}
+
+	setStyleOptions(style, optionMap)
+
+	style, err = q.styleRepo.Create(context.Background(), style)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error saving the style.", err)
+	}
+	log.Printf("Created %s %s by %s", styleKind(style), style.Name, style.MemberID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, "Created the style:", styleEmbed(style))
+	return err
+}
+
+// managedStyle returns the style named in the options if the member created it,
+// or if it's shared in the guild and they can manage the guild.
+func (q *SDQueue) managedStyle(i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*entities.Style, error) {
+	option, ok := optionMap[styleNameOption]
+	if !ok {
+		return nil, errors.New("you need to provide a name")
+	}
+
+	style, err := q.styleRepo.GetByName(context.Background(), i.GuildID, utils.GetUser(i.Interaction).ID, option.StringValue())
+	if err != nil {
+		if errors.Is(err, &repositories.NotFoundError{}) {
+			return nil, fmt.Errorf("there is no style named `%s`", option.StringValue())
+		}
+		return nil, err
+	}
+
+	if style.MemberID != utils.GetUser(i.Interaction).ID && !(style.Shared() && handlers.CanManageGuild(i.Interaction)) {
+		return nil, errors.New("you can only change the styles you created")
+	}
+
+	return style, nil
+}
+
+func (q *SDQueue) editStyle(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	style, err := q.managedStyle(i, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	setStyleOptions(style, optionMap)
+
+	style, err = q.styleRepo.Update(context.Background(), style)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error saving the style.", err)
+	}
+	log.Printf("Updated %s %s by %s", styleKind(style), style.Name, style.MemberID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, "Updated the style:", styleEmbed(style))
+	return err
+}
+
+func (q *SDQueue) deleteStyle(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	style, err := q.managedStyle(i, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	if err := q.styleRepo.Delete(context.Background(), style.ID); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error deleting the style.", err)
+	}
+	log.Printf("Deleted %s %s by %s", styleKind(style), style.Name, style.MemberID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, fmt.Sprintf("Deleted the style `%s`.", style.Name))
+	return err
+}
+
+func (q *SDQueue) listStyles(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	styles, err := q.styleRepo.List(context.Background(), i.GuildID, utils.GetUser(i.Interaction).ID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving the styles.", err)
+	}
+	if len(styles) == 0 {
+		_, err = handlers.EditInteractionResponse(s, i.Interaction, "There are no styles yet, create one with `/style create`.")
+		return err
+	}
+
+	embed := discordgo.MessageEmbed{Title: "Styles"}
+	for _, style := range styles[:min(25, len(styles))] {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  styleLabel(style),
+			Value: fmt.Sprintf("```\n%s\n```-# %s", truncate(style.Prompt, stylePromptLength), styleSettings(style)),
+		})
+	}
+	if len(styles) > 25 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Showing 25 of %d styles", len(styles))}
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, embed)
+	return err
+}
+
+func styleKind(style *entities.Style) string {
+	if style.Shared() {
+		return "shared style"
+	}
+	return "personal style"
+}
+
+func styleLabel(style *entities.Style) string {
+	if style.Shared() {
+		return style.Name + " (shared)"
+	}
+	return style.Name
+}
+
+// styleSettings describes the settings of the style that aren't left to the defaults.
+func styleSettings(style *entities.Style) string {
+	var settings []string
+	add := func(format string, value any) {
+		settings = append(settings, fmt.Sprintf(format, value))
+	}
+
+	if style.SamplerName != "" {
+		add("sampler `%s`", style.SamplerName)
+	}
+	if style.Steps > 0 {
+		add("`%d` steps", style.Steps)
+	}
+	if style.CFGScale > 0 {
+		add("CFG scale `%v`", style.CFGScale)
+	}
+	if style.Width > 0 || style.Height > 0 {
+		add("size `%s`", fmt.Sprintf("%dx%d", style.Width, style.Height))
+	}
+	if style.Loras != "" {
+		add("LoRAs `%s`", style.Loras)
+	}
+
+	if len(settings) == 0 {
+		return "Default settings"
+	}
+	return strings.Join(settings, ", ")
+}
+
+func styleEmbed(style *entities.Style) discordgo.MessageEmbed {
+	negative := style.NegativePrompt
+	if negative == "" {
+		negative = "None"
+	}
+
+	return discordgo.MessageEmbed{
+		Title:       styleLabel(style),
+		Description: fmt.Sprintf("```\n%s\n```", truncate(style.Prompt, 3900)),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Negative prompt", Value: fmt.Sprintf("```\n%s\n```", truncate(negative, 1000))},
+			{Name: "Settings", Value: styleSettings(style)},
+			{Name: "Created by", Value: fmt.Sprintf("<@%s>", style.MemberID), Inline: true},
+		},
+	}
+}
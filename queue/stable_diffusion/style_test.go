@@ -0,0 +1,49 @@
+package stable_diffusion
+
+import (
+	"testing"
+
+	"stable_diffusion_bot/entities"
+)
+
+func TestApplyStyle(t *testing.T) {
+	item := &SDQueueItem{
+		ImageGenerationRequest: &entities.ImageGenerationRequest{
+			TextToImageRequest: &entities.TextToImageRequest{
+				Prompt:         "a cat",
+				NegativePrompt: "blurry",
+				SamplerName:    "Euler a",
+				Steps:          20,
+				Width:          512,
+				Height:         512,
+			},
+		},
+	}
+
+	applyStyle(item, &entities.Style{
+		Name:           "watercolor",
+		Prompt:         "watercolor painting of {prompt}, soft colors",
+		NegativePrompt: "photo",
+		Steps:          30,
+		Width:          768,
+		Loras:          "paper:0.6, ink",
+	})
+
+	if item.Style != "watercolor" || item.Steps != 30 || item.Width != 768 || item.Height != 512 || item.SamplerName != "Euler a" {
+		t.Errorf("Expected the settings of the style over the defaults, got %s with %d steps at %dx%d with %s",
+			item.Style, item.Steps, item.Width, item.Height, item.SamplerName)
+	}
+	if item.Prompt != "a cat" {
+		t.Errorf("Expected the templates to wait for the final prompt, got %q", item.Prompt)
+	}
+
+	item.applyStyleTemplates()
+	item.applyStyleTemplates()
+
+	if expected := "watercolor painting of a cat, soft colors, <lora:paper:0.6>, <lora:ink:1>"; item.Prompt != expected {
+		t.Errorf("Expected %q, got %q", expected, item.Prompt)
+	}
+	if expected := "blurry, photo"; item.NegativePrompt != expected {
+		t.Errorf("Expected %q, got %q", expected, item.NegativePrompt)
+	}
+}
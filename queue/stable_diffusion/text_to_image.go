@@ -2,11 +2,13 @@ package stable_diffusion
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"log"
+	"slices"
 	"strings"
 	"time"
 
@@ -19,8 +21,15 @@ import (
 )
 
 func (q *SDQueue) processImagineGrid(queue *SDQueueItem) error {
+	q.applyNSFWPolicy(queue)
 	request := queue.ImageGenerationRequest
 	textToImage := request.TextToImageRequest
+	if queue.Type != ItemTypeImg2Img {
+		width, height := outputSize(textToImage)
+		if err := q.checkSize(queue, width, height); err != nil {
+			return err
+		}
+	}
 	config, originalConfig, err := q.switchToModels(queue)
 	if err != nil {
 		return fmt.Errorf("error switching to models: %w", err)
@@ -38,13 +47,17 @@ func (q *SDQueue) processImagineGrid(queue *SDQueueItem) error {
 		return fmt.Errorf("error recording to repository: %w", err)
 	}
 
+	if queue.Type != ItemTypeRaw && queue.Type != ItemTypeImg2Img {
+		q.expandDynamicPrompts(queue)
+	}
+
 	generationDone := make(chan bool, 1)
 	defer close(generationDone)
 
 	go q.updateProgressBar(queue, generationDone, webhook)
 
 	switch queue.Type {
-	case ItemTypeImagine, ItemTypeReroll, ItemTypeVariation, ItemTypeRaw:
+	case ItemTypeImagine, ItemTypeReroll, ItemTypeVariation, ItemTypeRaw, ItemTypeOriginal:
 		response, err := q.textInference(queue)
 		generationDone <- true
 		if err != nil {
@@ -55,7 +68,7 @@ func (q *SDQueue) processImagineGrid(queue *SDQueueItem) error {
 			return fmt.Errorf("response of type %v is nil: %v", queue.Type, err)
 		}
 
-		q.recordSeeds(response, request, config)
+		q.recordSeeds(response, request, config, queue.prompts)
 
 		err = q.showFinalMessage(queue, response, embed, webhook)
 		if err != nil {
@@ -117,7 +130,7 @@ func (q *SDQueue) storeMessageInteraction(queue *SDQueueItem, message *discordgo
 	}
 
 	if message == nil {
-		message, err = q.botSession.InteractionResponse(queue.DiscordInteraction)
+		message, err = handlers.InteractionResponse(q.botSession, queue.DiscordInteraction)
 		if err != nil {
 			return err
 		}
@@ -129,6 +142,8 @@ func (q *SDQueue) storeMessageInteraction(queue *SDQueueItem, message *discordgo
 	request.InteractionID = queue.DiscordInteraction.ID
 	request.MessageID = queue.DiscordInteraction.Message.ID
 	request.MemberID = utils.GetUser(queue.DiscordInteraction).ID
+	request.GuildID = queue.DiscordInteraction.GuildID
+	request.ChannelID = cmp.Or(message.ChannelID, queue.DiscordInteraction.ChannelID)
 	request.SortOrder = 0
 	request.Processed = true
 	return nil
@@ -144,24 +159,44 @@ func (q *SDQueue) showFinalMessage(queue *SDQueueItem, response *entities.TextTo
 	// get new embed from generationEmbedDetails as q.imageGenerationRepo.Create has filled in newGeneration.CreatedAt and interrupted
 	embed = generationEmbedDetails(embed, queue, queue.Interrupt != nil)
 
+	disable := queue.Type == ItemTypeImg2Img || (queue.Raw != nil && queue.Raw.Debug)
+	rows := rerollVariationComponents(min(len(imageBuffers), totalImages), disable)
+	if amount := min(len(imageBuffers), totalImages); amount > 4 {
+		*rows = append(*rows, imageSelectComponent(amount, disable))
+	}
+	if request.OriginalPrompt != "" {
+		*rows = append(*rows, components[OriginalPromptButton])
+	}
+	*rows = append(*rows, components[EditButton])
+
 	webhook = &discordgo.WebhookEdit{
 		Content:    &mention,
-		Components: rerollVariationComponents(min(len(imageBuffers), totalImages), queue.Type == ItemTypeImg2Img || (queue.Raw != nil && queue.Raw.Debug)),
+		Components: rows,
 	}
 
 	if err := utils.EmbedImages(webhook, embed, imageBuffers[:min(len(imageBuffers), totalImages)], thumbnailBuffers, q.compositor); err != nil {
 		return fmt.Errorf("error creating image embed: %w", err)
 	}
 
-	_, err := handlers.EditInteractionResponse(q.botSession, queue.DiscordInteraction, webhook)
+	_, err := q.editImages(queue.DiscordInteraction, webhook)
 	return err
 }
 
-func (q *SDQueue) recordSeeds(response *entities.TextToImageResponse, request *entities.ImageGenerationRequest, config *entities.Config) {
+// recordSeeds records each image of the response, with its own prompt when the prompt had dynamic syntax.
+func (q *SDQueue) recordSeeds(response *entities.TextToImageResponse, request *entities.ImageGenerationRequest, config *entities.Config, prompts []dynamicPrompt) {
 	log.Printf("Seeds: %v Subseeds:%v", response.Seeds, response.Subseeds)
+	// the prompts with the dynamic syntax are kept to be shown in the final message
+	defer func(prompt, negative string) {
+		request.Prompt, request.NegativePrompt = prompt, negative
+	}(request.Prompt, request.NegativePrompt)
+
 	for idx := range *response.Seeds {
 		subGeneration := request
 		subGeneration.SortOrder = idx + 1
+		if idx < len(prompts) {
+			subGeneration.Prompt = prompts[idx].Prompt
+			subGeneration.NegativePrompt = prompts[idx].NegativePrompt
+		}
 		subGeneration.Seed = (*response.Seeds)[idx]
 		subGeneration.Subseed = (*response.Subseeds)[idx]
 		subGeneration.Checkpoint = response.Info.SDModelName
@@ -233,11 +268,71 @@ func (q *SDQueue) textInference(queue *SDQueueItem) (response *entities.TextToIm
 			response, err = q.stableDiffusionAPI.TextToImageRaw(marshal)
 		}
 	default:
+		if queue.prompts != nil {
+			return q.dynamicInference(queue)
+		}
 		response, err = q.stableDiffusionAPI.TextToImageRequest(generation.TextToImageRequest)
 	}
 	return response, err
 }
 
+// dynamicInference generates the images of the item with their expanded prompts.
+// As the API uses a single prompt for a batch, a request is sent for each image unless they all ended up with the same prompt.
+func (q *SDQueue) dynamicInference(queue *SDQueueItem) (*entities.TextToImageResponse, error) {
+	request := queue.TextToImageRequest
+	prompts := queue.prompts
+
+	if !slices.ContainsFunc(prompts, func(prompt dynamicPrompt) bool { return prompt != prompts[0] }) {
+		batch := *request
+		batch.Prompt, batch.NegativePrompt = prompts[0].Prompt, prompts[0].NegativePrompt
+		return q.stableDiffusionAPI.TextToImageRequest(&batch)
+	}
+
+	var images, extras []string
+	var seeds, subseeds []int64
+	var info entities.Info
+	for idx, prompt := range prompts {
+		single := *request
+		single.Prompt, single.NegativePrompt = prompt.Prompt, prompt.NegativePrompt
+		single.BatchSize, single.NIter = 1, 1
+		single.Seed = imageSeed(request, idx)
+		if request.SubseedStrength > 0 && request.Subseed >= 0 {
+			single.Subseed = request.Subseed + int64(idx)
+		}
+
+		response, err := q.stableDiffusionAPI.TextToImageRequest(&single)
+		if err != nil {
+			return nil, fmt.Errorf("error generating image %d: %w", idx+1, err)
+		}
+		if len(response.Images) == 0 || response.Seeds == nil || response.Subseeds == nil {
+			return nil, fmt.Errorf("no image was generated for image %d", idx+1)
+		}
+
+		images = append(images, response.Images[0])
+		if idx == 0 {
+			// such as the maps of ControlNet, which are the same for every image
+			extras = response.Images[1:]
+		}
+		seeds = append(seeds, (*response.Seeds)[0])
+		subseeds = append(subseeds, (*response.Subseeds)[0])
+		info = response.Info
+
+		if queue.Interrupt != nil {
+			log.Printf("Interrupted after %d of %d images", idx+1, len(prompts))
+			// the extra images would otherwise be shown as the missing images
+			extras = nil
+			break
+		}
+	}
+
+	return &entities.TextToImageResponse{
+		Images:   append(images, extras...),
+		Seeds:    &seeds,
+		Subseeds: &subseeds,
+		Info:     info,
+	}, nil
+}
+
 func (q *SDQueue) recordToRepository(request *entities.ImageGenerationRequest, err error) (*entities.ImageGenerationRequest, error) {
 	var ok bool
 	if request.Prompt, ok = strings.CutSuffix(request.Prompt, "{DEBUG}"); ok {
@@ -309,8 +404,7 @@ func (q *SDQueue) updateProgressBar(item *SDQueueItem, generationDone chan bool,
 
 			progressContent := imagineMessageSimple(request, utils.GetUser(item.DiscordInteraction), progress.Progress, ram, cuda)
 
-			// TODO: Use handlers.Responses[handlers.EditInteractionResponse] instead and adjust to return errors
-			_, progressErr = q.botSession.InteractionResponseEdit(item.DiscordInteraction, &discordgo.WebhookEdit{
+			_, progressErr = handlers.EditInteractionResponse(q.botSession, item.DiscordInteraction, &discordgo.WebhookEdit{
 				Content: &progressContent,
 			})
 			if progressErr != nil {
@@ -0,0 +1,87 @@
+package stable_diffusion
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/api/stable_diffusion_api"
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/utils"
+)
+
+// GenerateImage queues an imagine with the bot defaults on behalf of the interaction's user, for the generate_image tool of the LLM.
+// The image is refused where /imagine isn't allowed, as the bot only checks the commands sent by Discord.
+func (q *SDQueue) GenerateImage(interaction *discordgo.Interaction, prompt, negativePrompt string) (int, error) {
+	if !q.guilds.Settings(interaction.GuildID).Allows(ImagineCommand) {
+		return -1, fmt.Errorf("`%s` is not allowed in this server", ImagineCommand)
+	}
+
+	item := q.NewItem(interaction, WithPrompt(prompt), WithCurrentModels(q.stableDiffusionAPI))
+	item.Type = ItemTypeImagine
+	if negativePrompt != "" {
+		item.NegativePrompt = negativePrompt
+	}
+
+	position, err := q.Add(item)
+	if err != nil {
+		return -1, err
+	}
+
+	queueString := fmt.Sprintf(
+		"I'm dreaming something up for you. You are currently #%d in line.\n<@%s> asked me to imagine \n```\n%s\n```",
+		position,
+		utils.GetUser(interaction).ID,
+		item.Prompt,
+	)
+
+	message, err := handlers.EditInteractionResponse(q.botSession, interaction, queueString, handlers.Components[handlers.Cancel])
+	if err != nil {
+		return position, err
+	}
+	if item.DiscordInteraction != nil && item.DiscordInteraction.Message == nil && message != nil {
+		log.Printf("Setting message ID for interaction %v", item.DiscordInteraction.ID)
+		item.DiscordInteraction.Message = message
+	}
+
+	return position, nil
+}
+
+// Pending returns the number of items waiting in the queue, including the one being generated.
+func (q *SDQueue) Pending() int {
+	pending := len(q.queue)
+	if q.currentImagine != nil {
+		pending++
+	}
+	return pending
+}
+
+// Loras returns the names of the loras available on the stable diffusion API, as used in <lora:name:weight>.
+func (q *SDQueue) Loras() ([]string, error) {
+	cache, err := stable_diffusion_api.LoraCache.GetCache(q.stableDiffusionAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	loras := *cache.(*stable_diffusion_api.LoraModels)
+	names := make([]string, len(loras))
+	for i, lora := range loras {
+		names[i] = lora.Name
+	}
+	return names, nil
+}
+
+// Checkpoints returns the titles of the checkpoints available on the stable diffusion API.
+func (q *SDQueue) Checkpoints() ([]string, error) {
+	cache, err := stable_diffusion_api.CheckpointCache.GetCache(q.stableDiffusionAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, cache.Len())
+	for i := range names {
+		names[i] = cache.String(i)
+	}
+	return names, nil
+}
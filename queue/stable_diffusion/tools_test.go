@@ -0,0 +1,20 @@
+package stable_diffusion
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/queue"
+)
+
+func TestGenerateImageNotAllowed(t *testing.T) {
+	q := &SDQueue{guilds: queue.NewGuilds(fakeGuildSettings{
+		settings: &entities.GuildSettings{GuildID: "guild", AllowedCommands: []string{"llm"}},
+	})}
+
+	if _, err := q.GenerateImage(&discordgo.Interaction{GuildID: "guild"}, "a cat", ""); err == nil {
+		t.Error("Expected the image to be refused where /imagine isn't allowed")
+	}
+}
@@ -17,6 +17,9 @@ import (
 	"stable_diffusion_bot/utils"
 )
 
+// upscaleResize is how much the upscale buttons enlarge the generated image.
+const upscaleResize = 2
+
 func (q *SDQueue) processUpscaleImagine() error {
 	queue := q.currentImagine
 	var err error
@@ -25,12 +28,18 @@ func (q *SDQueue) processUpscaleImagine() error {
 		return handlers.ErrorEdit(q.botSession, queue.DiscordInteraction, fmt.Errorf("error getting prompt for upscale: %w", err))
 	}
 
+	q.applyNSFWPolicy(queue)
 	request := queue.ImageGenerationRequest
 	textToImage := request.TextToImageRequest
 	if textToImage == nil {
 		return handlers.ErrorEdit(q.botSession, queue.DiscordInteraction, fmt.Errorf("textToImageRequest of type %v is nil", queue.Type))
 	}
 
+	width, height := outputSize(textToImage)
+	if err := q.checkSize(queue, width*upscaleResize, height*upscaleResize); err != nil {
+		return handlers.ErrorEdit(q.botSession, queue.DiscordInteraction, err)
+	}
+
 	config, originalConfig, err := q.switchToModels(queue)
 	if err != nil {
 		return handlers.ErrorEdit(q.botSession, queue.DiscordInteraction, fmt.Errorf("error switching to models: %w", err))
@@ -39,12 +48,12 @@ func (q *SDQueue) processUpscaleImagine() error {
 	newContent := upscaleMessageContent(utils.GetUser(queue.DiscordInteraction), 0, 0)
 	embed := generationEmbedDetails(&discordgo.MessageEmbed{}, queue, queue.Interrupt != nil)
 
-	_, err = q.botSession.InteractionResponseEdit(queue.DiscordInteraction, &discordgo.WebhookEdit{
+	_, err = handlers.EditInteractionResponse(q.botSession, queue.DiscordInteraction, &discordgo.WebhookEdit{
 		Content: &newContent,
 		Embeds:  &[]*discordgo.MessageEmbed{embed},
 	})
 	if err != nil {
-		return handlers.Wrap(err)
+		return err
 	}
 
 	generationDone := make(chan bool, 1)
@@ -86,7 +95,7 @@ func (q *SDQueue) upscale(request *entities.ImageGenerationRequest) (*stable_dif
 
 	return q.stableDiffusionAPI.UpscaleImage(&stable_diffusion_api.UpscaleRequest{
 		ResizeMode:         0,
-		UpscalingResize:    2,
+		UpscalingResize:    upscaleResize,
 		Upscaler1:          "R-ESRGAN 2x+",
 		TextToImageRequest: textToImage,
 	})
@@ -148,7 +157,7 @@ func (q *SDQueue) finalUpscaleMessage(queue *SDQueueItem, resp *stable_diffusion
 		return err
 	}
 
-	_, err := handlers.EditInteractionResponse(q.botSession, queue.DiscordInteraction, webhook)
+	_, err := q.editImages(queue.DiscordInteraction, webhook)
 	return err
 }
 
@@ -199,7 +208,7 @@ func (q *SDQueue) updateUpscaleProgress(queue *SDQueueItem, generationDone chan
 			lastProgress = progress.Progress
 			progressContent := upscaleMessageContent(utils.GetUser(queue.DiscordInteraction), fetchProgress, upscaleProgress)
 
-			_, progressErr = q.botSession.InteractionResponseEdit(queue.DiscordInteraction, &discordgo.WebhookEdit{
+			_, progressErr = handlers.EditInteractionResponse(q.botSession, queue.DiscordInteraction, &discordgo.WebhookEdit{
 				Content: &progressContent,
 			})
 			if progressErr != nil {
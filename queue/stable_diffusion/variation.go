@@ -30,10 +30,15 @@ func (q *SDQueue) processVariation() error {
 	// for variations, we need random subseeds
 	request.Subseed = -1
 
-	if c.Type == ItemTypeReroll {
+	if c.Type == ItemTypeReroll || c.Type == ItemTypeOriginal {
 		request.Seed = -1
 	}
 
+	if c.Type == ItemTypeOriginal && request.OriginalPrompt != "" {
+		request.Prompt = request.OriginalPrompt
+		request.OriginalPrompt = ""
+	}
+
 	// for variations, the subseed strength determines how much variation we get
 	if c.Type == ItemTypeVariation {
 		request.SubseedStrength = 0.15
@@ -0,0 +1,322 @@
+package stable_diffusion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"stable_diffusion_bot/discord_bot/handlers"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+	"stable_diffusion_bot/utils"
+)
+
+const (
+	WildcardCommand Command = "wildcard"
+
+	wildcardUpload = "upload"
+	wildcardShow   = "show"
+	wildcardDelete = "delete"
+	wildcardList   = "list"
+
+	wildcardNameOption = "name"
+	wildcardFileOption = "file"
+
+	// maxWildcardSize is the largest file that can be uploaded as a wildcard.
+	maxWildcardSize = 1 << 20
+	// wildcardPreviewLength is the length of the values shown when listing wildcards.
+	wildcardPreviewLength = 200
+)
+
+var wildcardNameRegex = regexp.MustCompile(`^[\w\-/]+$`)
+
+func wildcardCommand() *discordgo.ApplicationCommand {
+	name := discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         wildcardNameOption,
+		Description:  "The name of the wildcard, used as __name__ in prompts",
+		Required:     true,
+		Autocomplete: true,
+		MaxLength:    100,
+	}
+	newName := name
+	newName.Required = false
+	newName.Autocomplete = false
+	newName.Description = "The name of the wildcard, used as __name__ in prompts. Defaults to the name of the file"
+
+	return &discordgo.ApplicationCommand{
+		Name:        WildcardCommand,
+		Description: "Manage the wildcards that can be used as __name__ in the prompts of this server",
+		Type:        discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        wildcardUpload,
+				Description: "Upload a text file with one value per line, replacing the wildcard of the same name",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type:        discordgo.ApplicationCommandOptionAttachment,
+						Name:        wildcardFileOption,
+						Description: "A text file with one value per line. Empty lines and lines starting with # are skipped",
+						Required:    true,
+					},
+					&newName,
+				},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        wildcardShow,
+				Description: "Show the values of a wildcard",
+				Options:     []*discordgo.ApplicationCommandOption{&name},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        wildcardDelete,
+				Description: "Delete a wildcard",
+				Options:     []*discordgo.ApplicationCommandOption{&name},
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionSubCommand,
+				Name:        wildcardList,
+				Description: "List the wildcards of this server",
+			},
+		},
+	}
+}
+
+func (q *SDQueue) processWildcardAutocomplete(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.ApplicationCommandData()
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
+	if len(data.Options) > 0 && q.wildcardRepo != nil && i.GuildID != "" {
+		for _, opt := range data.Options[0].Options {
+			if !opt.Focused || opt.Name != wildcardNameOption {
+				continue
+			}
+
+			wildcards, err := q.wildcardRepo.List(context.Background(), i.GuildID)
+			if err != nil {
+				log.Printf("Error retrieving wildcards: %v", err)
+			}
+
+			input := strings.ToLower(opt.StringValue())
+			for _, wildcard := range wildcards {
+				if input != "" && !strings.Contains(strings.ToLower(wildcard.Name), input) {
+					continue
+				}
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  wildcard.Name,
+					Value: wildcard.Name,
+				})
+			}
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices[:min(25, len(choices))],
+		},
+	})
+	return handlers.Wrap(err)
+}
+
+func (q *SDQueue) processWildcardCommand(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	if err := handlers.EphemeralThink(s, i); err != nil {
+		return err
+	}
+
+	if q.wildcardRepo == nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Wildcards are not available.")
+	}
+	if i.GuildID == "" {
+		return handlers.ErrorEdit(s, i.Interaction, "Wildcards can only be used in a server.")
+	}
+
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to pick a subcommand.")
+	}
+	subcommand := data.Options[0]
+	optionMap := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(subcommand.Options))
+	for _, opt := range subcommand.Options {
+		optionMap[opt.Name] = opt
+	}
+
+	switch subcommand.Name {
+	case wildcardUpload:
+		return q.uploadWildcard(s, i, optionMap)
+	case wildcardShow:
+		return q.showWildcard(s, i, optionMap)
+	case wildcardDelete:
+		return q.deleteWildcard(s, i, optionMap)
+	case wildcardList:
+		return q.listWildcards(s, i)
+	}
+
+	return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("Unknown subcommand %s.", subcommand.Name))
+}
+
+func (q *SDQueue) uploadWildcard(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	if !handlers.CanManageGuild(i.Interaction) {
+		return handlers.ErrorEdit(s, i.Interaction, "Only members who can manage the server can upload wildcards.")
+	}
+
+	option, ok := optionMap[wildcardFileOption]
+	if !ok {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a text file.")
+	}
+	attachment, ok := i.ApplicationCommandData().Resolved.Attachments[option.Value.(string)]
+	if !ok || !(strings.HasPrefix(attachment.ContentType, "text/plain") || strings.HasSuffix(attachment.Filename, ".txt")) {
+		return handlers.ErrorEdit(s, i.Interaction, "You need to provide a text file.")
+	}
+	if attachment.Size > maxWildcardSize {
+		return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("The file can't be larger than %d KiB.", maxWildcardSize>>10))
+	}
+
+	name := strings.TrimSuffix(attachment.Filename, path.Ext(attachment.Filename))
+	if option, ok := optionMap[wildcardNameOption]; ok && option.StringValue() != "" {
+		name = strings.Trim(strings.TrimSpace(option.StringValue()), "_")
+	}
+	if !wildcardNameRegex.MatchString(name) {
+		return handlers.ErrorEdit(s, i.Interaction, fmt.Sprintf("`%s` is not a valid name, it can only contain letters, digits, _, - and /.", name))
+	}
+
+	resp, err := http.Get(attachment.URL)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error downloading attachment.", err)
+	}
+	defer resp.Body.Close()
+
+	blob, err := io.ReadAll(io.LimitReader(resp.Body, maxWildcardSize))
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error reading attachment.", err)
+	}
+
+	values := wildcardValues(string(blob))
+	if len(values) == 0 {
+		return handlers.ErrorEdit(s, i.Interaction, "The file doesn't have any values.")
+	}
+
+	wildcard, err := q.wildcardRepo.Save(context.Background(), &entities.Wildcard{
+		Name:     name,
+		GuildID:  i.GuildID,
+		Values:   values,
+		MemberID: utils.GetUser(i.Interaction).ID,
+	})
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error saving the wildcard.", err)
+	}
+	log.Printf("Uploaded wildcard %s with %d values to %s by %s", wildcard.Name, len(wildcard.Values), wildcard.GuildID, wildcard.MemberID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, "Uploaded the wildcard:", wildcardEmbed(wildcard))
+	return err
+}
+
+// wildcardValues returns the lines of the file, skipping the empty lines and the comments starting with #.
+func wildcardValues(file string) []string {
+	var values []string
+	for _, line := range strings.Split(file, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			values = append(values, line)
+		}
+	}
+	return values
+}
+
+// namedWildcard returns the wildcard of the guild named in the options.
+func (q *SDQueue) namedWildcard(i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) (*entities.Wildcard, error) {
+	option, ok := optionMap[wildcardNameOption]
+	if !ok {
+		return nil, errors.New("you need to provide a name")
+	}
+
+	wildcard, err := q.wildcardRepo.GetByName(context.Background(), i.GuildID, strings.Trim(option.StringValue(), "_"))
+	if errors.Is(err, &repositories.NotFoundError{}) {
+		return nil, fmt.Errorf("there is no wildcard named `%s`", option.StringValue())
+	}
+	return wildcard, err
+}
+
+func (q *SDQueue) showWildcard(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	wildcard, err := q.namedWildcard(i, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, wildcardEmbed(wildcard))
+	return err
+}
+
+func (q *SDQueue) deleteWildcard(s *discordgo.Session, i *discordgo.InteractionCreate, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) error {
+	if !handlers.CanManageGuild(i.Interaction) {
+		return handlers.ErrorEdit(s, i.Interaction, "Only members who can manage the server can delete wildcards.")
+	}
+
+	wildcard, err := q.namedWildcard(i, optionMap)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, err)
+	}
+
+	if err := q.wildcardRepo.Delete(context.Background(), wildcard.ID); err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error deleting the wildcard.", err)
+	}
+	log.Printf("Deleted wildcard %s from %s by %s", wildcard.Name, wildcard.GuildID, utils.GetUser(i.Interaction).ID)
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, fmt.Sprintf("Deleted the wildcard `__%s__`.", wildcard.Name))
+	return err
+}
+
+func (q *SDQueue) listWildcards(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	wildcards, err := q.wildcardRepo.List(context.Background(), i.GuildID)
+	if err != nil {
+		return handlers.ErrorEdit(s, i.Interaction, "Error retrieving the wildcards.", err)
+	}
+	if len(wildcards) == 0 {
+		_, err = handlers.EditInteractionResponse(s, i.Interaction, "There are no wildcards yet, upload one with `/wildcard upload`.")
+		return err
+	}
+
+	embed := discordgo.MessageEmbed{
+		Title:       "Wildcards",
+		Description: "Use `__name__` in a prompt for a random value of the wildcard, or `{a|b|c}` for one of the alternatives.",
+	}
+	for _, wildcard := range wildcards[:min(25, len(wildcards))] {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("%s (%d values)", wildcardLabel(wildcard.Name), len(wildcard.Values)),
+			Value: fmt.Sprintf("```\n%s\n```", truncate(strings.Join(wildcard.Values, ", "), wildcardPreviewLength)),
+		})
+	}
+	if len(wildcards) > 25 {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Showing 25 of %d wildcards", len(wildcards))}
+	}
+
+	_, err = handlers.EditInteractionResponse(s, i.Interaction, embed)
+	return err
+}
+
+// wildcardLabel escapes the underscores of the wildcard as it's used in prompts, as they would underline it otherwise.
+func wildcardLabel(name string) string {
+	return strings.ReplaceAll("__"+name+"__", "_", `\_`)
+}
+
+func wildcardEmbed(wildcard *entities.Wildcard) discordgo.MessageEmbed {
+	return discordgo.MessageEmbed{
+		Title:       wildcardLabel(wildcard.Name),
+		Description: fmt.Sprintf("```\n%s\n```", truncate(strings.Join(wildcard.Values, "\n"), 3900)),
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Values", Value: fmt.Sprintf("%d", len(wildcard.Values)), Inline: true},
+			{Name: "Uploaded by", Value: fmt.Sprintf("<@%s>", wildcard.MemberID), Inline: true},
+		},
+		Timestamp: wildcard.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -9,4 +9,5 @@ import (
 type Repository interface {
 	Upsert(ctx context.Context, setting *entities.DefaultSettings) (*entities.DefaultSettings, error)
 	GetByMemberID(ctx context.Context, memberID string) (*entities.DefaultSettings, error)
+	Delete(ctx context.Context, memberID string) error
 }
@@ -12,11 +12,21 @@ import (
 )
 
 const upsertSetting string = `
-INSERT OR REPLACE INTO default_settings (member_id, width, height, batch_count, batch_size) VALUES (?, ?, ?, ?, ?);
+INSERT OR REPLACE INTO default_settings (member_id, width, height, batch_count, batch_size,
+       sampler_name, steps, cfg_scale, negative_prompt, checkpoint, vae, clip_skip,
+       enable_hr, hr_scale, hr_upscaler, hr_steps, denoising_strength, adetailer)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 `
 
 const getSettingByMemberID string = `
-SELECT member_id, width, height, batch_count, batch_size FROM default_settings WHERE member_id = ?;
+SELECT member_id, width, height, batch_count, batch_size,
+       sampler_name, steps, cfg_scale, negative_prompt, checkpoint, vae, clip_skip,
+       enable_hr, hr_scale, hr_upscaler, hr_steps, denoising_strength, adetailer
+FROM default_settings WHERE member_id = ?;
+`
+
+const deleteSettingByMemberID string = `
+DELETE FROM default_settings WHERE member_id = ?;
 `
 
 type sqliteRepo struct {
@@ -43,7 +53,9 @@ func NewRepository(cfg *Config) (Repository, error) {
 
 func (repo *sqliteRepo) Upsert(ctx context.Context, setting *entities.DefaultSettings) (*entities.DefaultSettings, error) {
 	_, err := repo.dbConn.ExecContext(ctx, upsertSetting,
-		setting.MemberID, setting.Width, setting.Height, setting.BatchCount, setting.BatchSize)
+		setting.MemberID, setting.Width, setting.Height, setting.BatchCount, setting.BatchSize,
+		setting.SamplerName, setting.Steps, setting.CFGScale, setting.NegativePrompt, setting.Checkpoint, setting.VAE, setting.CLIPSkip,
+		setting.EnableHr, setting.HrScale, setting.HrUpscaler, setting.HrSteps, setting.DenoisingStrength, setting.ADetailer)
 	if err != nil {
 		return nil, err
 	}
@@ -55,7 +67,9 @@ func (repo *sqliteRepo) GetByMemberID(ctx context.Context, memberID string) (*en
 	var setting entities.DefaultSettings
 
 	err := repo.dbConn.QueryRowContext(ctx, getSettingByMemberID, memberID).Scan(
-		&setting.MemberID, &setting.Width, &setting.Height, &setting.BatchCount, &setting.BatchSize)
+		&setting.MemberID, &setting.Width, &setting.Height, &setting.BatchCount, &setting.BatchSize,
+		&setting.SamplerName, &setting.Steps, &setting.CFGScale, &setting.NegativePrompt, &setting.Checkpoint, &setting.VAE, &setting.CLIPSkip,
+		&setting.EnableHr, &setting.HrScale, &setting.HrUpscaler, &setting.HrSteps, &setting.DenoisingStrength, &setting.ADetailer)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -67,3 +81,8 @@ func (repo *sqliteRepo) GetByMemberID(ctx context.Context, memberID string) (*en
 
 	return &setting, nil
 }
+
+func (repo *sqliteRepo) Delete(ctx context.Context, memberID string) error {
+	_, err := repo.dbConn.ExecContext(ctx, deleteSettingByMemberID, memberID)
+	return err
+}
@@ -0,0 +1,27 @@
+package favorites
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"stable_diffusion_bot/entities"
+)
+
+// ErrAlreadyFavorite is returned by Create when the member already starred the image.
+var ErrAlreadyFavorite = errors.New("the image is already a favorite")
+
+// Filter narrows down the favorites to list. Blank fields match every favorite.
+type Filter struct {
+	MemberID   string
+	GuildID    string
+	Checkpoint string    // part of the name of the checkpoint of the generation
+	LoRA       string    // name of a LoRA used in the prompt of the generation
+	Since      time.Time // oldest creation date of the generation
+}
+
+type Repository interface {
+	Create(ctx context.Context, favorite *entities.Favorite) (*entities.Favorite, error)
+	List(ctx context.Context, filter Filter, limit, offset int) ([]*entities.Favorite, error)
+	Count(ctx context.Context, filter Filter) (int, error)
+}
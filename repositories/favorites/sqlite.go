@@ -0,0 +1,130 @@
+package favorites
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+)
+
+const insertFavoriteQuery string = `
+INSERT INTO favorites (member_id, guild_id, channel_id, message_id, sort_order, attachment_url, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING;
+`
+
+// filterFavorites matches the favorites by Filter, where each blank field is passed twice to match everything.
+const filterFavorites string = `
+FROM favorites f
+LEFT JOIN image_generations g ON g.message_id = f.message_id AND g.sort_order = f.sort_order
+WHERE (? = '' OR f.member_id = ?)
+  AND (? = '' OR f.guild_id = ?)
+  AND (? = '' OR g.checkpoint LIKE '%' || ? || '%')
+  AND (? = '' OR g.prompt LIKE '%<lora:' || ? || '%')
+  AND COALESCE(g.created_at, f.created_at) >= ?
+`
+
+const listFavorites string = `
+SELECT f.id, f.member_id, f.guild_id, f.channel_id, f.message_id, f.sort_order, f.attachment_url, f.created_at
+` + filterFavorites + `
+ORDER BY f.created_at DESC, f.id DESC LIMIT ? OFFSET ?;
+`
+
+const countFavorites string = `
+SELECT COUNT(*)
+` + filterFavorites + `;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Create(ctx context.Context, favorite *entities.Favorite) (*entities.Favorite, error) {
+	if favorite.CreatedAt.IsZero() {
+		favorite.CreatedAt = repo.clock.Now()
+	}
+
+	res, err := repo.dbConn.ExecContext(ctx, insertFavoriteQuery,
+		favorite.MemberID, favorite.GuildID, favorite.ChannelID, favorite.MessageID, favorite.SortOrder,
+		favorite.AttachmentURL, favorite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, ErrAlreadyFavorite
+	}
+
+	favorite.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return favorite, nil
+}
+
+// List returns the favorites matching the filter, starting from the most recently starred.
+func (repo *sqliteRepo) List(ctx context.Context, filter Filter, limit, offset int) ([]*entities.Favorite, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, listFavorites, append(filter.args(), limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var favorites []*entities.Favorite
+	for rows.Next() {
+		var favorite entities.Favorite
+		err := rows.Scan(&favorite.ID, &favorite.MemberID, &favorite.GuildID, &favorite.ChannelID, &favorite.MessageID,
+			&favorite.SortOrder, &favorite.AttachmentURL, &favorite.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		favorites = append(favorites, &favorite)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return favorites, nil
+}
+
+func (repo *sqliteRepo) Count(ctx context.Context, filter Filter) (int, error) {
+	var count int
+	err := repo.dbConn.QueryRowContext(ctx, countFavorites, filter.args()...).Scan(&count)
+	return count, err
+}
+
+func (filter Filter) args() []any {
+	return []any{
+		filter.MemberID, filter.MemberID,
+		filter.GuildID, filter.GuildID,
+		filter.Checkpoint, filter.Checkpoint,
+		filter.LoRA, filter.LoRA,
+		filter.Since,
+	}
+}
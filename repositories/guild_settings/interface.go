@@ -0,0 +1,13 @@
+package guild_settings
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, settings *entities.GuildSettings) (*entities.GuildSettings, error)
+	GetByGuildID(ctx context.Context, guildID string) (*entities.GuildSettings, error)
+	Delete(ctx context.Context, guildID string) error
+}
@@ -0,0 +1,86 @@
+package guild_settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const upsertSetting string = `
+INSERT OR REPLACE INTO guild_settings (guild_id, allowed_commands, default_checkpoint, max_width, max_height, nsfw_policy, queue_limit, announcement_channel_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+const getSettingByGuildID string = `
+SELECT guild_id, allowed_commands, default_checkpoint, max_width, max_height, nsfw_policy, queue_limit, announcement_channel_id FROM guild_settings WHERE guild_id = ?;
+`
+
+const deleteSettingByGuildID string = `
+DELETE FROM guild_settings WHERE guild_id = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+// Upsert stores the allowed commands as a comma separated list, as command names can't have commas.
+func (repo *sqliteRepo) Upsert(ctx context.Context, settings *entities.GuildSettings) (*entities.GuildSettings, error) {
+	_, err := repo.dbConn.ExecContext(ctx, upsertSetting,
+		settings.GuildID, strings.Join(settings.AllowedCommands, ","), settings.DefaultCheckpoint,
+		settings.MaxWidth, settings.MaxHeight, settings.NSFWPolicy, settings.QueueLimit, settings.AnnouncementChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (repo *sqliteRepo) GetByGuildID(ctx context.Context, guildID string) (*entities.GuildSettings, error) {
+	var settings entities.GuildSettings
+	var allowedCommands string
+
+	err := repo.dbConn.QueryRowContext(ctx, getSettingByGuildID, guildID).Scan(
+		&settings.GuildID, &allowedCommands, &settings.DefaultCheckpoint,
+		&settings.MaxWidth, &settings.MaxHeight, &settings.NSFWPolicy, &settings.QueueLimit, &settings.AnnouncementChannelID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("guild settings for guild ID %s", guildID))
+		}
+
+		return nil, err
+	}
+
+	if allowedCommands != "" {
+		settings.AllowedCommands = strings.Split(allowedCommands, ",")
+	}
+
+	return &settings, nil
+}
+
+func (repo *sqliteRepo) Delete(ctx context.Context, guildID string) error {
+	_, err := repo.dbConn.ExecContext(ctx, deleteSettingByGuildID, guildID)
+	return err
+}
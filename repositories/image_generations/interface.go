@@ -2,12 +2,27 @@ package image_generations
 
 import (
 	"context"
+	"time"
 
 	"stable_diffusion_bot/entities"
 )
 
+// Filter narrows down the generations to search. Blank fields match every generation.
+type Filter struct {
+	MemberID   string
+	GuildID    string
+	Checkpoint string    // part of the name of the checkpoint
+	Prompt     string    // part of the prompt
+	From       time.Time // oldest creation date
+	To         time.Time // creation date the generations are older than
+}
+
 type Repository interface {
 	Create(ctx context.Context, generation *entities.ImageGenerationRequest) (*entities.ImageGenerationRequest, error)
 	GetByMessage(ctx context.Context, messageID string) (*entities.ImageGenerationRequest, error)
 	GetByMessageAndSort(ctx context.Context, messageID string, sortOrder int) (*entities.ImageGenerationRequest, error)
+	List(ctx context.Context, memberID string, limit, offset int) ([]*entities.ImageGenerationRequest, error)
+	Search(ctx context.Context, filter Filter, limit, offset int) ([]*entities.ImageGenerationRequest, error)
+	SearchText(ctx context.Context, text string, filter Filter, limit int) ([]*entities.ImageGenerationRequest, error)
+	Count(ctx context.Context, filter Filter) (int, error)
 }
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"stable_diffusion_bot/clock"
 	"stable_diffusion_bot/entities"
@@ -17,8 +18,8 @@ INSERT INTO image_generations (interaction_id, message_id, member_id, sort_order
                                batch_count, batch_size, seed, subseed, 
                                subseed_strength, sampler_name, cfg_scale, steps, processed, created_at, 
                                always_on_scripts, 
-                               checkpoint, vae, hypernetwork) VALUES
-                            (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+                               checkpoint, vae, hypernetwork, original_prompt, parent_id, guild_id, channel_id, style) VALUES
+                            (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 `
 
 const getGenerationByMessageID string = `
@@ -28,7 +29,7 @@ SELECT id, interaction_id, message_id, member_id, sort_order, prompt,
        denoising_strength, batch_count, batch_size, seed, subseed, 
        subseed_strength, sampler_name, cfg_scale, steps, processed, created_at, 
        always_on_scripts, 
-       checkpoint, vae, hypernetwork FROM image_generations WHERE message_id = ?;
+       checkpoint, vae, hypernetwork, original_prompt, parent_id, guild_id, channel_id, style FROM image_generations WHERE message_id = ?;
 `
 
 const getGenerationByMessageIDAndSortOrder string = `
@@ -38,7 +39,48 @@ SELECT id, interaction_id, message_id, member_id, sort_order, prompt,
        denoising_strength, batch_count, batch_size, seed, subseed, 
        subseed_strength, sampler_name, cfg_scale, steps, processed, created_at, 
        always_on_scripts, 
-       checkpoint, vae, hypernetwork FROM image_generations WHERE message_id = ? AND sort_order = ?;
+       checkpoint, vae, hypernetwork, original_prompt, parent_id, guild_id, channel_id, style FROM image_generations WHERE message_id = ? AND sort_order = ?;
+`
+
+// searchGenerations lists the generations of whole batches matching Filter, where each blank field is passed twice to match everything.
+const searchGenerations string = `
+SELECT id, interaction_id, message_id, member_id, sort_order, prompt,
+       negative_prompt, width, height, restore_faces, 
+       enable_hr, hr_scale, hr_upscaler, hires_width, hires_height, 
+       denoising_strength, batch_count, batch_size, seed, subseed, 
+       subseed_strength, sampler_name, cfg_scale, steps, processed, created_at, 
+       always_on_scripts, 
+       checkpoint, vae, hypernetwork, original_prompt, parent_id, guild_id, channel_id, style FROM image_generations
+` + filterGenerations + `
+ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?;
+`
+
+const searchGenerationText string = `
+SELECT id, interaction_id, message_id, member_id, sort_order, prompt,
+       negative_prompt, width, height, restore_faces, 
+       enable_hr, hr_scale, hr_upscaler, hires_width, hires_height, 
+       denoising_strength, batch_count, batch_size, seed, subseed, 
+       subseed_strength, sampler_name, cfg_scale, steps, processed, created_at, 
+       always_on_scripts, 
+       checkpoint, vae, hypernetwork, original_prompt, parent_id, guild_id, channel_id, style FROM image_generations
+JOIN (SELECT rowid, rank FROM image_generations_fts WHERE image_generations_fts MATCH ?) AS matches ON matches.rowid = id
+` + filterGenerations + `
+ORDER BY matches.rank LIMIT ?;
+`
+
+const countGenerations string = `
+SELECT COUNT(*) FROM image_generations
+` + filterGenerations + `;
+`
+
+const filterGenerations string = `
+WHERE sort_order = 0 AND message_id != ''
+  AND (? = '' OR member_id = ?)
+  AND (? = '' OR guild_id = ?)
+  AND (? = '' OR checkpoint LIKE '%' || ? || '%')
+  AND (? = '' OR prompt LIKE '%' || ? || '%')
+  AND created_at >= ?
+  AND (? OR created_at < ?)
 `
 
 type sqliteRepo struct {
@@ -80,7 +122,7 @@ func (repo *sqliteRepo) Create(ctx context.Context, generation *entities.ImageGe
 		generation.NIter, generation.BatchSize, generation.Seed, generation.Subseed,
 		generation.SubseedStrength, generation.SamplerName, generation.CFGScale, generation.Steps, generation.Processed, generation.CreatedAt,
 		marshalAlwaysonScriptstoString,
-		generation.Checkpoint, generation.VAE, generation.Hypernetwork,
+		generation.Checkpoint, generation.VAE, generation.Hypernetwork, generation.OriginalPrompt, generation.ParentID, generation.GuildID, generation.ChannelID, generation.Style,
 	)
 	if err != nil {
 		return nil, err
@@ -107,7 +149,7 @@ func (repo *sqliteRepo) GetByMessage(ctx context.Context, messageID string) (*en
 		&generation.NIter, &generation.BatchSize, &generation.Seed, &generation.Subseed,
 		&generation.SubseedStrength, &generation.SamplerName, &generation.CFGScale, &generation.Steps, &generation.Processed, &generation.CreatedAt,
 		&alwaysonScriptsString,
-		&generation.Checkpoint, &generation.VAE, &generation.Hypernetwork,
+		&generation.Checkpoint, &generation.VAE, &generation.Hypernetwork, &generation.OriginalPrompt, &generation.ParentID, &generation.GuildID, &generation.ChannelID, &generation.Style,
 	)
 	if err != nil {
 		return nil, err
@@ -133,7 +175,7 @@ func (repo *sqliteRepo) GetByMessageAndSort(ctx context.Context, messageID strin
 		&generation.NIter, &generation.BatchSize, &generation.Seed, &generation.Subseed,
 		&generation.SubseedStrength, &generation.SamplerName, &generation.CFGScale, &generation.Steps, &generation.Processed, &generation.CreatedAt,
 		&alwaysonScriptsString,
-		&generation.Checkpoint, &generation.VAE, &generation.Hypernetwork,
+		&generation.Checkpoint, &generation.VAE, &generation.Hypernetwork, &generation.OriginalPrompt, &generation.ParentID, &generation.GuildID, &generation.ChannelID, &generation.Style,
 	)
 
 	if err != nil {
@@ -148,3 +190,100 @@ func (repo *sqliteRepo) GetByMessageAndSort(ctx context.Context, messageID strin
 
 	return &generation, nil
 }
+
+// List returns the most recent generations of the member.
+func (repo *sqliteRepo) List(ctx context.Context, memberID string, limit, offset int) ([]*entities.ImageGenerationRequest, error) {
+	return repo.Search(ctx, Filter{MemberID: memberID}, limit, offset)
+}
+
+// Search returns the generations matching the filter, starting from the most recent.
+// Only the record of the whole batch is returned for each generation, which is sort order 0.
+func (repo *sqliteRepo) Search(ctx context.Context, filter Filter, limit, offset int) ([]*entities.ImageGenerationRequest, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, searchGenerations, append(filter.args(), limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanGenerations(rows)
+}
+
+// SearchText returns the generations with the words of text in their prompt or negative prompt, starting from the best match.
+// The last word also matches the words it is the start of, to find them while they're being typed.
+func (repo *sqliteRepo) SearchText(ctx context.Context, text string, filter Filter, limit int) ([]*entities.ImageGenerationRequest, error) {
+	query := ftsQuery(text)
+	if query == "" {
+		return nil, nil
+	}
+
+	rows, err := repo.dbConn.QueryContext(ctx, searchGenerationText, append(append([]any{query}, filter.args()...), limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanGenerations(rows)
+}
+
+// ftsQuery quotes each word of text for FTS5, so that its syntax can't be used by accident.
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	if len(words) > 0 {
+		words[len(words)-1] += "*"
+	}
+	return strings.Join(words, " ")
+}
+
+func scanGenerations(rows *sql.Rows) ([]*entities.ImageGenerationRequest, error) {
+	defer rows.Close()
+
+	var generations []*entities.ImageGenerationRequest
+	for rows.Next() {
+		var generation = entities.ImageGenerationRequest{TextToImageRequest: &entities.TextToImageRequest{}}
+		var alwaysonScriptsString string
+
+		err := rows.Scan(
+			&generation.ID, &generation.InteractionID, &generation.MessageID, &generation.MemberID, &generation.SortOrder, &generation.Prompt,
+			&generation.NegativePrompt, &generation.Width, &generation.Height, &generation.RestoreFaces,
+			&generation.EnableHr, &generation.HrScale, &generation.HrUpscaler, &generation.HrResizeX, &generation.HrResizeY, &generation.DenoisingStrength,
+			&generation.NIter, &generation.BatchSize, &generation.Seed, &generation.Subseed,
+			&generation.SubseedStrength, &generation.SamplerName, &generation.CFGScale, &generation.Steps, &generation.Processed, &generation.CreatedAt,
+			&alwaysonScriptsString,
+			&generation.Checkpoint, &generation.VAE, &generation.Hypernetwork, &generation.OriginalPrompt, &generation.ParentID, &generation.GuildID, &generation.ChannelID, &generation.Style,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal([]byte(alwaysonScriptsString), &generation.Scripts)
+		if err != nil {
+			return nil, err
+		}
+
+		generations = append(generations, &generation)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return generations, nil
+}
+
+func (repo *sqliteRepo) Count(ctx context.Context, filter Filter) (int, error) {
+	var count int
+	err := repo.dbConn.QueryRowContext(ctx, countGenerations, filter.args()...).Scan(&count)
+	return count, err
+}
+
+func (filter Filter) args() []any {
+	return []any{
+		filter.MemberID, filter.MemberID,
+		filter.GuildID, filter.GuildID,
+		filter.Checkpoint, filter.Checkpoint,
+		filter.Prompt, filter.Prompt,
+		filter.From,
+		filter.To.IsZero(), filter.To,
+	}
+}
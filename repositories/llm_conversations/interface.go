@@ -0,0 +1,15 @@
+package llm_conversations
+
+import (
+	"context"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Create(ctx context.Context, conversation *entities.LLMConversation) (*entities.LLMConversation, error)
+	GetByThreadID(ctx context.Context, threadID string) (*entities.LLMConversation, error)
+	AddMessages(ctx context.Context, conversationID int64, messages ...llm.Message) error
+}
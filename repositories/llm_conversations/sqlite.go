@@ -0,0 +1,148 @@
+package llm_conversations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ellypaws/inkbunny-sd/llm"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const insertConversationQuery string = `
+INSERT INTO llm_conversations (thread_id, member_id, endpoint, model, max_tokens, temperature, tools, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+const insertMessageQuery string = `
+INSERT INTO llm_conversation_messages (conversation_id, role, content, created_at) VALUES (?, ?, ?, ?);
+`
+
+const getConversationByThreadID string = `
+SELECT id, thread_id, member_id, endpoint, model, max_tokens, temperature, tools, created_at FROM llm_conversations WHERE thread_id = ?;
+`
+
+const getConversationMessages string = `
+SELECT role, content FROM llm_conversation_messages WHERE conversation_id = ? ORDER BY id;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+// Create stores the conversation along with its initial messages.
+func (repo *sqliteRepo) Create(ctx context.Context, conversation *entities.LLMConversation) (*entities.LLMConversation, error) {
+	if conversation.CreatedAt.IsZero() {
+		conversation.CreatedAt = repo.clock.Now()
+	}
+
+	tx, err := repo.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, insertConversationQuery,
+		conversation.ThreadID, conversation.MemberID, conversation.Endpoint, conversation.Model, conversation.MaxTokens, conversation.Temperature, conversation.Tools, conversation.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	conversation.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, message := range conversation.Messages {
+		_, err = tx.ExecContext(ctx, insertMessageQuery, conversation.ID, message.Role, message.Content, conversation.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+func (repo *sqliteRepo) GetByThreadID(ctx context.Context, threadID string) (*entities.LLMConversation, error) {
+	var conversation entities.LLMConversation
+
+	err := repo.dbConn.QueryRowContext(ctx, getConversationByThreadID, threadID).Scan(
+		&conversation.ID, &conversation.ThreadID, &conversation.MemberID, &conversation.Endpoint, &conversation.Model,
+		&conversation.MaxTokens, &conversation.Temperature, &conversation.Tools, &conversation.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("llm conversation for thread %s", threadID))
+		}
+
+		return nil, err
+	}
+
+	rows, err := repo.dbConn.QueryContext(ctx, getConversationMessages, conversation.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var message llm.Message
+		if err := rows.Scan(&message.Role, &message.Content); err != nil {
+			return nil, err
+		}
+		conversation.Messages = append(conversation.Messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &conversation, nil
+}
+
+// AddMessages appends the messages to the end of the conversation.
+func (repo *sqliteRepo) AddMessages(ctx context.Context, conversationID int64, messages ...llm.Message) error {
+	tx, err := repo.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// nolint
+	defer tx.Rollback()
+
+	now := repo.clock.Now()
+	for _, message := range messages {
+		_, err = tx.ExecContext(ctx, insertMessageQuery, conversationID, message.Role, message.Content, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,17 @@
+package llm_personas
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Create(ctx context.Context, persona *entities.LLMPersona) (*entities.LLMPersona, error)
+	Update(ctx context.Context, persona *entities.LLMPersona) (*entities.LLMPersona, error)
+	GetByName(ctx context.Context, guildID, name string) (*entities.LLMPersona, error)
+	GetDefault(ctx context.Context, guildID string) (*entities.LLMPersona, error)
+	ListByGuildID(ctx context.Context, guildID string) ([]*entities.LLMPersona, error)
+	SetDefault(ctx context.Context, guildID, name string) error
+	Delete(ctx context.Context, guildID, name string) error
+}
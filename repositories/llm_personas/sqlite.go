@@ -0,0 +1,197 @@
+package llm_personas
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const insertPersonaQuery string = `
+INSERT INTO llm_personas (guild_id, name, system_prompt, temperature, max_tokens, model, is_default, created_by, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+const updatePersonaQuery string = `
+UPDATE llm_personas SET system_prompt = ?, temperature = ?, max_tokens = ?, model = ? WHERE id = ?;
+`
+
+const personaColumns string = `id, guild_id, name, system_prompt, temperature, max_tokens, model, is_default, created_by, created_at`
+
+const getPersonaByName string = `
+SELECT ` + personaColumns + ` FROM llm_personas WHERE guild_id = ? AND name = ?;
+`
+
+const getDefaultPersona string = `
+SELECT ` + personaColumns + ` FROM llm_personas WHERE guild_id = ? AND is_default = 1;
+`
+
+const listPersonasByGuildID string = `
+SELECT ` + personaColumns + ` FROM llm_personas WHERE guild_id = ? ORDER BY name;
+`
+
+const clearDefaultPersonaQuery string = `
+UPDATE llm_personas SET is_default = 0 WHERE guild_id = ?;
+`
+
+const setDefaultPersonaQuery string = `
+UPDATE llm_personas SET is_default = 1 WHERE guild_id = ? AND name = ?;
+`
+
+const deletePersonaQuery string = `
+DELETE FROM llm_personas WHERE guild_id = ? AND name = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Create(ctx context.Context, persona *entities.LLMPersona) (*entities.LLMPersona, error) {
+	if persona.CreatedAt.IsZero() {
+		persona.CreatedAt = repo.clock.Now()
+	}
+
+	res, err := repo.dbConn.ExecContext(ctx, insertPersonaQuery,
+		persona.GuildID, persona.Name, persona.SystemPrompt, persona.Temperature, persona.MaxTokens, persona.Model,
+		persona.IsDefault, persona.CreatedBy, persona.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	persona.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return persona, nil
+}
+
+// Update stores the settings of the persona. The name, guild and creator can't be changed.
+func (repo *sqliteRepo) Update(ctx context.Context, persona *entities.LLMPersona) (*entities.LLMPersona, error) {
+	_, err := repo.dbConn.ExecContext(ctx, updatePersonaQuery,
+		persona.SystemPrompt, persona.Temperature, persona.MaxTokens, persona.Model, persona.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return persona, nil
+}
+
+func (repo *sqliteRepo) GetByName(ctx context.Context, guildID, name string) (*entities.LLMPersona, error) {
+	persona, err := scanPersona(repo.dbConn.QueryRowContext(ctx, getPersonaByName, guildID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NewNotFoundError(fmt.Sprintf("persona %s", name))
+	}
+
+	return persona, err
+}
+
+func (repo *sqliteRepo) GetDefault(ctx context.Context, guildID string) (*entities.LLMPersona, error) {
+	persona, err := scanPersona(repo.dbConn.QueryRowContext(ctx, getDefaultPersona, guildID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NewNotFoundError(fmt.Sprintf("default persona for guild %s", guildID))
+	}
+
+	return persona, err
+}
+
+func (repo *sqliteRepo) ListByGuildID(ctx context.Context, guildID string) ([]*entities.LLMPersona, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, listPersonasByGuildID, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var personas []*entities.LLMPersona
+	for rows.Next() {
+		persona, err := scanPersona(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		personas = append(personas, persona)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return personas, nil
+}
+
+// SetDefault makes the persona the default of the guild, replacing the previous default.
+// An empty name only clears the default.
+func (repo *sqliteRepo) SetDefault(ctx context.Context, guildID, name string) error {
+	tx, err := repo.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// nolint
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, clearDefaultPersonaQuery, guildID)
+	if err != nil {
+		return err
+	}
+
+	if name != "" {
+		res, err := tx.ExecContext(ctx, setDefaultPersonaQuery, guildID, name)
+		if err != nil {
+			return err
+		}
+
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return repositories.NewNotFoundError(fmt.Sprintf("persona %s", name))
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (repo *sqliteRepo) Delete(ctx context.Context, guildID, name string) error {
+	_, err := repo.dbConn.ExecContext(ctx, deletePersonaQuery, guildID, name)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanPersona(row scanner) (*entities.LLMPersona, error) {
+	var persona entities.LLMPersona
+
+	err := row.Scan(&persona.ID, &persona.GuildID, &persona.Name, &persona.SystemPrompt, &persona.Temperature,
+		&persona.MaxTokens, &persona.Model, &persona.IsDefault, &persona.CreatedBy, &persona.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &persona, nil
+}
@@ -0,0 +1,13 @@
+package llm_settings
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, settings *entities.LLMSettings) (*entities.LLMSettings, error)
+	GetByMemberID(ctx context.Context, memberID string) (*entities.LLMSettings, error)
+	Delete(ctx context.Context, memberID string) error
+}
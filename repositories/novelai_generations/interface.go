@@ -0,0 +1,13 @@
+package novelai_generations
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Create(ctx context.Context, generation *entities.NovelAIGeneration) (*entities.NovelAIGeneration, error)
+	GetByMessage(ctx context.Context, messageID string) (*entities.NovelAIGeneration, error)
+	GetByMessageAndSort(ctx context.Context, messageID string, sortOrder int) (*entities.NovelAIGeneration, error)
+}
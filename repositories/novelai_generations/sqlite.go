@@ -0,0 +1,135 @@
+package novelai_generations
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const insertGenerationQuery string = `
+INSERT INTO novelai_generations (interaction_id, message_id, member_id, sort_order, request, metadata, created_at) VALUES
+                                (?, ?, ?, ?, ?, ?, ?);
+`
+
+const getGenerationByMessageID string = `
+SELECT id, interaction_id, message_id, member_id, sort_order, request, metadata, created_at
+FROM novelai_generations WHERE message_id = ? ORDER BY sort_order LIMIT 1;
+`
+
+const getGenerationByMessageIDAndSortOrder string = `
+SELECT id, interaction_id, message_id, member_id, sort_order, request, metadata, created_at
+FROM novelai_generations WHERE message_id = ? AND sort_order = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+// Create stores the generation. Image payloads are not stored, so callers should remove them from the request beforehand.
+func (repo *sqliteRepo) Create(ctx context.Context, generation *entities.NovelAIGeneration) (*entities.NovelAIGeneration, error) {
+	if generation.CreatedAt.IsZero() {
+		generation.CreatedAt = repo.clock.Now()
+	}
+
+	request, err := json.Marshal(generation.Request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling request: %w", err)
+	}
+
+	metadata, err := json.Marshal(generation.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling metadata: %w", err)
+	}
+
+	res, err := repo.dbConn.ExecContext(ctx, insertGenerationQuery,
+		generation.InteractionID, generation.MessageID, generation.MemberID, generation.SortOrder,
+		string(request), string(metadata), generation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	generation.ID = lastID
+
+	return generation, nil
+}
+
+func (repo *sqliteRepo) GetByMessage(ctx context.Context, messageID string) (*entities.NovelAIGeneration, error) {
+	generation, err := scanGeneration(repo.dbConn.QueryRowContext(ctx, getGenerationByMessageID, messageID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("novelai generation for message %s", messageID))
+		}
+
+		return nil, err
+	}
+
+	return generation, nil
+}
+
+func (repo *sqliteRepo) GetByMessageAndSort(ctx context.Context, messageID string, sortOrder int) (*entities.NovelAIGeneration, error) {
+	generation, err := scanGeneration(repo.dbConn.QueryRowContext(ctx, getGenerationByMessageIDAndSortOrder, messageID, sortOrder))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("novelai generation #%d for message %s", sortOrder, messageID))
+		}
+
+		return nil, err
+	}
+
+	return generation, nil
+}
+
+func scanGeneration(row *sql.Row) (*entities.NovelAIGeneration, error) {
+	var generation entities.NovelAIGeneration
+	var request, metadata string
+
+	err := row.Scan(
+		&generation.ID, &generation.InteractionID, &generation.MessageID, &generation.MemberID, &generation.SortOrder,
+		&request, &metadata, &generation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal([]byte(request), &generation.Request)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling request: %w", err)
+	}
+
+	err = json.Unmarshal([]byte(metadata), &generation.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling metadata: %w", err)
+	}
+
+	return &generation, nil
+}
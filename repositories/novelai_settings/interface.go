@@ -0,0 +1,13 @@
+package novelai_settings
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, settings *entities.NovelAISettings) (*entities.NovelAISettings, error)
+	GetByMemberID(ctx context.Context, memberID string) (*entities.NovelAISettings, error)
+	Delete(ctx context.Context, memberID string) error
+}
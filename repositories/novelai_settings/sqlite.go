@@ -0,0 +1,80 @@
+package novelai_settings
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const upsertSetting string = `
+INSERT OR REPLACE INTO novelai_settings (member_id, model, size, sampler, schedule, uc_preset, quality_toggle, scale, steps) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+const getSettingByMemberID string = `
+SELECT member_id, model, size, sampler, schedule, uc_preset, quality_toggle, scale, steps FROM novelai_settings WHERE member_id = ?;
+`
+
+const deleteSettingByMemberID string = `
+DELETE FROM novelai_settings WHERE member_id = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Upsert(ctx context.Context, settings *entities.NovelAISettings) (*entities.NovelAISettings, error) {
+	_, err := repo.dbConn.ExecContext(ctx, upsertSetting,
+		settings.MemberID, settings.Model, settings.Size, settings.Sampler, settings.Schedule,
+		settings.UcPreset, settings.QualityToggle, settings.Scale, settings.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (repo *sqliteRepo) GetByMemberID(ctx context.Context, memberID string) (*entities.NovelAISettings, error) {
+	var settings entities.NovelAISettings
+
+	err := repo.dbConn.QueryRowContext(ctx, getSettingByMemberID, memberID).Scan(
+		&settings.MemberID, &settings.Model, &settings.Size, &settings.Sampler, &settings.Schedule,
+		&settings.UcPreset, &settings.QualityToggle, &settings.Scale, &settings.Steps)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("novelai settings for member ID %s", memberID))
+		}
+
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (repo *sqliteRepo) Delete(ctx context.Context, memberID string) error {
+	_, err := repo.dbConn.ExecContext(ctx, deleteSettingByMemberID, memberID)
+	return err
+}
@@ -0,0 +1,17 @@
+package styles
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Create(ctx context.Context, style *entities.Style) (*entities.Style, error)
+	Update(ctx context.Context, style *entities.Style) (*entities.Style, error)
+	// GetByName returns the style the member can use by that name: their own first, then the one shared in the guild.
+	GetByName(ctx context.Context, guildID, memberID, name string) (*entities.Style, error)
+	// List returns the styles of the member, followed by the ones shared in the guild.
+	List(ctx context.Context, guildID, memberID string) ([]*entities.Style, error)
+	Delete(ctx context.Context, id int64) error
+}
@@ -0,0 +1,147 @@
+package styles
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const insertStyleQuery string = `
+INSERT INTO styles (name, member_id, guild_id, prompt, negative_prompt, sampler_name, steps, cfg_scale, width, height, loras, created_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+`
+
+const updateStyleQuery string = `
+UPDATE styles SET prompt = ?, negative_prompt = ?, sampler_name = ?, steps = ?, cfg_scale = ?, width = ?, height = ?, loras = ? WHERE id = ?;
+`
+
+const styleColumns string = `id, name, member_id, guild_id, prompt, negative_prompt, sampler_name, steps, cfg_scale, width, height, loras, created_at`
+
+// visibleStyles matches the personal styles of the member and the styles shared in the guild, by member and guild.
+const visibleStyles string = `((guild_id = '' AND member_id = ?) OR (guild_id != '' AND guild_id = ?))`
+
+const getStyleByName string = `
+SELECT ` + styleColumns + ` FROM styles WHERE name = ? AND ` + visibleStyles + `
+ORDER BY guild_id = '' DESC LIMIT 1;
+`
+
+const listStyles string = `
+SELECT ` + styleColumns + ` FROM styles WHERE ` + visibleStyles + `
+ORDER BY guild_id = '' DESC, name;
+`
+
+const deleteStyleQuery string = `
+DELETE FROM styles WHERE id = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Create(ctx context.Context, style *entities.Style) (*entities.Style, error) {
+	if style.CreatedAt.IsZero() {
+		style.CreatedAt = repo.clock.Now()
+	}
+
+	res, err := repo.dbConn.ExecContext(ctx, insertStyleQuery,
+		style.Name, style.MemberID, style.GuildID, style.Prompt, style.NegativePrompt,
+		style.SamplerName, style.Steps, style.CFGScale, style.Width, style.Height, style.Loras, style.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	style.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return style, nil
+}
+
+// Update stores the templates and settings of the style. The name, guild and creator can't be changed.
+func (repo *sqliteRepo) Update(ctx context.Context, style *entities.Style) (*entities.Style, error) {
+	_, err := repo.dbConn.ExecContext(ctx, updateStyleQuery,
+		style.Prompt, style.NegativePrompt, style.SamplerName, style.Steps, style.CFGScale, style.Width, style.Height, style.Loras, style.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return style, nil
+}
+
+func (repo *sqliteRepo) GetByName(ctx context.Context, guildID, memberID, name string) (*entities.Style, error) {
+	style, err := scanStyle(repo.dbConn.QueryRowContext(ctx, getStyleByName, name, memberID, guildID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NewNotFoundError(fmt.Sprintf("style %s", name))
+	}
+
+	return style, err
+}
+
+func (repo *sqliteRepo) List(ctx context.Context, guildID, memberID string) ([]*entities.Style, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, listStyles, memberID, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var styles []*entities.Style
+	for rows.Next() {
+		style, err := scanStyle(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		styles = append(styles, style)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return styles, nil
+}
+
+func (repo *sqliteRepo) Delete(ctx context.Context, id int64) error {
+	_, err := repo.dbConn.ExecContext(ctx, deleteStyleQuery, id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanStyle(row scanner) (*entities.Style, error) {
+	var style entities.Style
+
+	err := row.Scan(&style.ID, &style.Name, &style.MemberID, &style.GuildID, &style.Prompt, &style.NegativePrompt,
+		&style.SamplerName, &style.Steps, &style.CFGScale, &style.Width, &style.Height, &style.Loras, &style.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &style, nil
+}
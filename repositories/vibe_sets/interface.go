@@ -0,0 +1,13 @@
+package vibe_sets
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	Upsert(ctx context.Context, set *entities.VibeSet) (*entities.VibeSet, error)
+	GetByName(ctx context.Context, memberID, name string) (*entities.VibeSet, error)
+	ListByMemberID(ctx context.Context, memberID string) ([]*entities.VibeSet, error)
+}
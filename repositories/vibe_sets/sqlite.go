@@ -0,0 +1,162 @@
+package vibe_sets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const upsertVibeSetQuery string = `
+INSERT INTO vibe_sets (member_id, name, created_at) VALUES (?, ?, ?)
+ON CONFLICT (member_id, name) DO UPDATE SET created_at = excluded.created_at
+RETURNING id;
+`
+
+const deleteVibeSetImagesQuery string = `
+DELETE FROM vibe_set_images WHERE vibe_set_id = ?;
+`
+
+const insertVibeSetImageQuery string = `
+INSERT INTO vibe_set_images (vibe_set_id, sort_order, image, information_extracted, reference_strength) VALUES (?, ?, ?, ?, ?);
+`
+
+const getVibeSetByName string = `
+SELECT id, member_id, name, created_at FROM vibe_sets WHERE member_id = ? AND name = ?;
+`
+
+const getVibeSetImages string = `
+SELECT image, information_extracted, reference_strength FROM vibe_set_images WHERE vibe_set_id = ? ORDER BY sort_order;
+`
+
+const listVibeSetsByMemberID string = `
+SELECT id, member_id, name, created_at FROM vibe_sets WHERE member_id = ? ORDER BY created_at DESC;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+// Upsert creates the vibe set, or replaces all references of an existing set with the same member and name.
+func (repo *sqliteRepo) Upsert(ctx context.Context, set *entities.VibeSet) (*entities.VibeSet, error) {
+	if set.CreatedAt.IsZero() {
+		set.CreatedAt = repo.clock.Now()
+	}
+
+	tx, err := repo.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, upsertVibeSetQuery, set.MemberID, set.Name, set.CreatedAt).Scan(&set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, deleteVibeSetImagesQuery, set.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, reference := range set.References {
+		_, err = tx.ExecContext(ctx, insertVibeSetImageQuery,
+			set.ID, idx, reference.Image, reference.InformationExtracted, reference.ReferenceStrength)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+func (repo *sqliteRepo) GetByName(ctx context.Context, memberID, name string) (*entities.VibeSet, error) {
+	var set entities.VibeSet
+
+	err := repo.dbConn.QueryRowContext(ctx, getVibeSetByName, memberID, name).Scan(
+		&set.ID, &set.MemberID, &set.Name, &set.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repositories.NewNotFoundError(fmt.Sprintf("vibe set %s", name))
+		}
+
+		return nil, err
+	}
+
+	rows, err := repo.dbConn.QueryContext(ctx, getVibeSetImages, set.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reference entities.VibeReference
+		err = rows.Scan(&reference.Image, &reference.InformationExtracted, &reference.ReferenceStrength)
+		if err != nil {
+			return nil, err
+		}
+
+		set.References = append(set.References, reference)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+// ListByMemberID returns the member's vibe sets without their images.
+func (repo *sqliteRepo) ListByMemberID(ctx context.Context, memberID string) ([]*entities.VibeSet, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, listVibeSetsByMemberID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sets []*entities.VibeSet
+	for rows.Next() {
+		var set entities.VibeSet
+		err = rows.Scan(&set.ID, &set.MemberID, &set.Name, &set.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		sets = append(sets, &set)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
@@ -0,0 +1,16 @@
+package wildcards
+
+import (
+	"context"
+
+	"stable_diffusion_bot/entities"
+)
+
+type Repository interface {
+	// Save creates the wildcard, or replaces the values of the wildcard of the same name in the guild.
+	Save(ctx context.Context, wildcard *entities.Wildcard) (*entities.Wildcard, error)
+	GetByName(ctx context.Context, guildID, name string) (*entities.Wildcard, error)
+	// List returns the wildcards of the guild by name.
+	List(ctx context.Context, guildID string) ([]*entities.Wildcard, error)
+	Delete(ctx context.Context, id int64) error
+}
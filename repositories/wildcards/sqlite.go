@@ -0,0 +1,125 @@
+package wildcards
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"stable_diffusion_bot/clock"
+	"stable_diffusion_bot/entities"
+	"stable_diffusion_bot/repositories"
+)
+
+const upsertWildcardQuery string = `
+INSERT INTO wildcards (name, guild_id, wildcard_values, member_id, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (guild_id, name) DO UPDATE SET
+wildcard_values = excluded.wildcard_values, member_id = excluded.member_id, updated_at = excluded.updated_at
+RETURNING id, created_at;
+`
+
+const wildcardColumns string = `id, name, guild_id, wildcard_values, member_id, created_at, updated_at`
+
+const getWildcardByName string = `
+SELECT ` + wildcardColumns + ` FROM wildcards WHERE guild_id = ? AND name = ?;
+`
+
+const listWildcards string = `
+SELECT ` + wildcardColumns + ` FROM wildcards WHERE guild_id = ? ORDER BY name;
+`
+
+const deleteWildcardQuery string = `
+DELETE FROM wildcards WHERE id = ?;
+`
+
+type sqliteRepo struct {
+	dbConn *sql.DB
+	clock  clock.Clock
+}
+
+type Config struct {
+	DB *sql.DB
+}
+
+func NewRepository(cfg *Config) (Repository, error) {
+	if cfg.DB == nil {
+		return nil, errors.New("missing DB parameter")
+	}
+
+	newRepo := &sqliteRepo{
+		dbConn: cfg.DB,
+		clock:  clock.NewClock(),
+	}
+
+	return newRepo, nil
+}
+
+func (repo *sqliteRepo) Save(ctx context.Context, wildcard *entities.Wildcard) (*entities.Wildcard, error) {
+	wildcard.UpdatedAt = repo.clock.Now()
+
+	err := repo.dbConn.QueryRowContext(ctx, upsertWildcardQuery,
+		wildcard.Name, wildcard.GuildID, strings.Join(wildcard.Values, "\n"), wildcard.MemberID, wildcard.UpdatedAt, wildcard.UpdatedAt,
+	).Scan(&wildcard.ID, &wildcard.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return wildcard, nil
+}
+
+func (repo *sqliteRepo) GetByName(ctx context.Context, guildID, name string) (*entities.Wildcard, error) {
+	wildcard, err := scanWildcard(repo.dbConn.QueryRowContext(ctx, getWildcardByName, guildID, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repositories.NewNotFoundError(fmt.Sprintf("wildcard %s", name))
+	}
+
+	return wildcard, err
+}
+
+func (repo *sqliteRepo) List(ctx context.Context, guildID string) ([]*entities.Wildcard, error) {
+	rows, err := repo.dbConn.QueryContext(ctx, listWildcards, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wildcards []*entities.Wildcard
+	for rows.Next() {
+		wildcard, err := scanWildcard(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		wildcards = append(wildcards, wildcard)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return wildcards, nil
+}
+
+func (repo *sqliteRepo) Delete(ctx context.Context, id int64) error {
+	_, err := repo.dbConn.ExecContext(ctx, deleteWildcardQuery, id)
+	return err
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWildcard(row scanner) (*entities.Wildcard, error) {
+	var wildcard entities.Wildcard
+	var values string
+
+	err := row.Scan(&wildcard.ID, &wildcard.Name, &wildcard.GuildID, &values, &wildcard.MemberID, &wildcard.CreatedAt, &wildcard.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	wildcard.Values = strings.Split(values, "\n")
+
+	return &wildcard, nil
+}
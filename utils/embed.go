@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -101,3 +104,42 @@ func EmbedImages(webhook *discordgo.WebhookEdit, embed *discordgo.MessageEmbed,
 	webhook.Files = files
 	return nil
 }
+
+// DescribeImages describes each image attached by EmbedImages with describe, and returns the descriptions by file name.
+// The images are read to be described, so their readers are replaced to still be uploaded. The thumbnail is left out.
+// Images that could not be described are left out as well, so that they're posted without a description.
+func DescribeImages(webhook *discordgo.WebhookEdit, describe func(image []byte) (string, error)) map[string]string {
+	descriptions := make(map[string]string, len(webhook.Files))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, file := range webhook.Files {
+		if file.Name == "thumbnail.png" || file.Reader == nil {
+			continue
+		}
+
+		image, err := io.ReadAll(file.Reader)
+		if err != nil {
+			// the file is posted without a description, with what was already read put back in front of the rest
+			log.Printf("Error reading %s to describe it: %v", file.Name, err)
+			file.Reader = io.MultiReader(bytes.NewReader(image), file.Reader)
+			continue
+		}
+		file.Reader = bytes.NewReader(image)
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			description, err := describe(image)
+			if err != nil {
+				log.Printf("Error describing %s: %v", name, err)
+				return
+			}
+			mu.Lock()
+			descriptions[name] = description
+			mu.Unlock()
+		}(file.Name)
+	}
+	wg.Wait()
+
+	return descriptions
+}
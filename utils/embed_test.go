@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestDescribeImagesReadError(t *testing.T) {
+	broken := io.MultiReader(bytes.NewReader([]byte("half")), iotest.ErrReader(errors.New("connection reset")))
+	webhook := &discordgo.WebhookEdit{Files: []*discordgo.File{
+		{Name: "first.png", Reader: bytes.NewReader([]byte("first"))},
+		{Name: "broken.png", Reader: broken},
+		{Name: "last.png", Reader: bytes.NewReader([]byte("last"))},
+	}}
+
+	descriptions := DescribeImages(webhook, func(image []byte) (string, error) {
+		return "an image of " + string(image), nil
+	})
+
+	if len(descriptions) != 2 || descriptions["first.png"] != "an image of first" || descriptions["last.png"] != "an image of last" {
+		t.Errorf("Expected the other images to be described, got %v", descriptions)
+	}
+
+	read, _ := io.ReadAll(webhook.Files[1].Reader)
+	if string(read) != "half" {
+		t.Errorf("Expected the broken image to keep what was read, got %q", read)
+	}
+}
@@ -35,6 +35,16 @@ func AsyncImage(url string) *Image {
 	return result
 }
 
+// ImageFromBytes returns an *Image that already holds data, for images that don't need to be downloaded (e.g. from the database).
+func ImageFromBytes(data []byte) *Image {
+	result := asyncPool.Get()
+	result.reset()
+	result.buffer.Write(data)
+	close(result.ch)
+
+	return result
+}
+
 // Download starts the download of the image from the given URL.
 // It resets any previous buffered data to overwrite it with the new data.
 func (r *Image) Download(url string) {
@@ -95,12 +105,15 @@ func (r *Image) Base64() (string, error) {
 
 	out := bytes.NewBuffer(make([]byte, 0, r.buffer.Len()))
 	encoder := base64.NewEncoder(base64.StdEncoding, out)
-	defer encoder.Close()
 
 	_, err := encoder.Write(r.buffer.Bytes())
 	if err != nil {
 		return "", err
 	}
+	// the encoder holds on to the last partial block until it's closed, which also writes the padding
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
 	return out.String(), nil
 }
 
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// PNGTextChunks returns the keyword and text of every tEXt, zTXt and iTXt chunk in a PNG.
+// Stable Diffusion stores its parameters in the "parameters" keyword, while NovelAI uses "Comment", "Description", "Software" and "Source".
+func PNGTextChunks(data []byte) (map[string]string, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("not a PNG image")
+	}
+
+	chunks := make(map[string]string)
+	data = data[len(pngSignature):]
+	for len(data) >= 12 {
+		length := binary.BigEndian.Uint32(data[:4])
+		if uint64(len(data)) < 12+uint64(length) {
+			return chunks, errors.New("truncated PNG chunk")
+		}
+
+		chunkType := string(data[4:8])
+		body := data[8 : 8+length]
+		data = data[12+length:]
+
+		keyword, rest, found := bytes.Cut(body, []byte{0})
+		if !found {
+			continue
+		}
+
+		switch chunkType {
+		case "tEXt":
+			chunks[string(keyword)] = string(rest)
+		case "zTXt":
+			if len(rest) < 1 {
+				continue
+			}
+			text, err := inflate(rest[1:])
+			if err != nil {
+				continue
+			}
+			chunks[string(keyword)] = text
+		case "iTXt":
+			if len(rest) < 2 {
+				continue
+			}
+			compressed := rest[0] == 1
+			// skip the language tag and translated keyword
+			_, rest, _ = bytes.Cut(rest[2:], []byte{0})
+			_, rest, _ = bytes.Cut(rest, []byte{0})
+			if !compressed {
+				chunks[string(keyword)] = string(rest)
+				continue
+			}
+			text, err := inflate(rest)
+			if err != nil {
+				continue
+			}
+			chunks[string(keyword)] = text
+		case "IEND":
+			return chunks, nil
+		}
+	}
+
+	return chunks, nil
+}
+
+func inflate(data []byte) (string, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	text, err := io.ReadAll(reader)
+	return string(text), err
+}
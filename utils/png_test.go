@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPNGTextChunks(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/text_chunks.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cut in the middle of the IDAT chunk, after the text chunks
+	truncated := fixture[:len(fixture)-20]
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "tEXt, zTXt and iTXt",
+			data: fixture,
+			want: map[string]string{
+				"Software":    "NovelAI",
+				"Comment":     `{"prompt": "1girl"}`,
+				"parameters":  "a cat\nSteps: 20, Sampler: Euler a",
+				"Description": "café, ñ",
+			},
+		},
+		{
+			name: "truncated",
+			data: truncated,
+			want: map[string]string{
+				"Software":    "NovelAI",
+				"Comment":     `{"prompt": "1girl"}`,
+				"parameters":  "a cat\nSteps: 20, Sampler: Euler a",
+				"Description": "café, ñ",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "not a PNG",
+			data:    []byte("GIF89a"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := PNGTextChunks(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(chunks) != len(tt.want) {
+				t.Errorf("Expected %d chunks, got %d: %q", len(tt.want), len(chunks), chunks)
+			}
+			for keyword, text := range tt.want {
+				if chunks[keyword] != text {
+					t.Errorf("Expected %s to be %q, got %q", keyword, text, chunks[keyword])
+				}
+			}
+		})
+	}
+}